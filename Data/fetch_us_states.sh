@@ -0,0 +1,16 @@
+#!/bin/sh
+# Rebuilds us_states.geojson from the US Census Bureau's cartographic boundary file of the states (1:5,000,000, 2023),
+# keeping the lower 48 states and DC. Census cartographic boundary files are in the public domain.
+# Needs curl and GDAL's ogr2ogr.
+set -e
+
+url=https://www2.census.gov/geo/tiger/GENZ2023/shp/cb_2023_us_state_5m.zip
+dir=$(dirname "$0")
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+curl -sSfL -o "$tmp/states.zip" "$url"
+ogr2ogr -f GeoJSON -t_srs EPSG:4326 -lco COORDINATE_PRECISION=4 \
+	-select NAME,STUSPS \
+	-where "STUSPS NOT IN ('AK','HI','PR','VI','GU','MP','AS')" \
+	"$dir/us_states.geojson" "/vsizip/$tmp/states.zip"
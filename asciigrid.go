@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReadASCIIGrid reads an ESRI ASCII grid (.asc), the plain-text raster format written by PRISM and by GDAL's AAIGrid driver.
+// The header gives ncols, nrows, the lower left corner (xllcorner/yllcorner, or xllcenter/yllcenter for the centre of the
+// lower left cell), cellsize and optionally NODATA_value; the values follow row by row from north to south.
+// The grid must be in longitude/latitude degrees.
+func ReadASCIIGrid(filePath string) (Raster, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return Raster{}, fmt.Errorf("error opening grid: %v", err)
+	}
+	defer file.Close()
+
+	r, err := ParseASCIIGrid(file)
+	if err != nil {
+		return Raster{}, fmt.Errorf("%s: %v", filePath, err)
+	}
+	return r, nil
+}
+
+// ParseASCIIGrid parses ESRI ASCII grid data (see ReadASCIIGrid).
+func ParseASCIIGrid(reader io.Reader) (Raster, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	scanner.Split(bufio.ScanWords)
+
+	r, first, err := parseASCIIGridHeader(scanner)
+	if err != nil {
+		return Raster{}, err
+	}
+
+	for i := range r.values {
+		word := first
+		if i > 0 {
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return Raster{}, err
+				}
+				return Raster{}, fmt.Errorf("expected %d values, found %d", len(r.values), i)
+			}
+			word = scanner.Text()
+		}
+		v, err := strconv.ParseFloat(word, 64)
+		if err != nil {
+			return Raster{}, fmt.Errorf("invalid value %q in row %d", word, i/r.cols+1)
+		}
+		r.values[i] = v
+	}
+	return r, nil
+}
+
+// parseASCIIGridHeader reads the header of an ESRI ASCII grid and returns an empty raster of its size,
+// together with the first value of the grid, which has already been read from the scanner.
+func parseASCIIGridHeader(scanner *bufio.Scanner) (Raster, string, error) {
+	header := make(map[string]float64)
+	var first string
+	for scanner.Scan() {
+		key := strings.ToLower(scanner.Text())
+		if _, err := strconv.ParseFloat(key, 64); err == nil {
+			first = key
+			break
+		}
+		if !scanner.Scan() {
+			break
+		}
+		value, err := strconv.ParseFloat(scanner.Text(), 64)
+		if err != nil {
+			return Raster{}, "", fmt.Errorf("invalid %s %q in the header", key, scanner.Text())
+		}
+		header[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return Raster{}, "", err
+	}
+	if first == "" {
+		return Raster{}, "", fmt.Errorf("the grid has no values")
+	}
+
+	for _, key := range []string{"ncols", "nrows", "cellsize"} {
+		if header[key] <= 0 {
+			return Raster{}, "", fmt.Errorf("the header has no positive %s", key)
+		}
+	}
+	cellSize := header["cellsize"]
+	cols, rows := int(header["ncols"]), int(header["nrows"])
+
+	west, okX := header["xllcorner"]
+	south, okY := header["yllcorner"]
+	if x, ok := header["xllcenter"]; ok {
+		west, okX = x-cellSize/2, true
+	}
+	if y, ok := header["yllcenter"]; ok {
+		south, okY = y-cellSize/2, true
+	}
+	if !okX || !okY {
+		return Raster{}, "", fmt.Errorf("the header does not give the lower left corner (xllcorner and yllcorner)")
+	}
+
+	r := Raster{
+		bounds:   Bounds{minLon: west, minLat: south, maxLon: west + float64(cols)*cellSize, maxLat: south + float64(rows)*cellSize},
+		cellSize: cellSize,
+		cols:     cols,
+		rows:     rows,
+		values:   make([]float64, cols*rows),
+		noData:   math.NaN(),
+	}
+	if noData, ok := header["nodata_value"]; ok {
+		r.noData = noData
+	}
+	return r, first, nil
+}
+
+// gridFilePattern matches the variable and date in the name of a daily temperature grid, e.g. tmax_20230501.asc or
+// PRISM_tmin_stable_4kmD2_20230501_asc.asc.
+var gridFilePattern = regexp.MustCompile(`(?i)(tmin|tmax).*?(\d{8})`)
+
+// dailyGrids is a folder of ESRI ASCII grids holding some variables for each day, one file per variable and day, with the
+// variable and the date as YYYYMMDD in the file name (matched by pattern).
+// Grids are read when a day is first asked for, and only those of the two most recent days are kept in memory.
+// A day without grids uses the same day of the latest year that has them, so one year of grids can drive a run of several years.
+type dailyGrids struct {
+	kind      string                       // what the grids hold, for messages
+	variables []string                     // variables every day needs
+	files     map[string]map[string]string // variable -> YYYYMMDD -> file
+	days      []string                     // YYYYMMDD of the days with every variable, in order
+	latest    map[string]string            // MMDD -> latest of those days
+
+	mu     sync.Mutex
+	cache  map[string]Raster
+	loaded []string // files in the cache, oldest first
+	failed map[string]bool
+}
+
+// newDailyGrids finds the daily grids in a folder. It fails if the folder has no day with a grid of every variable.
+func newDailyGrids(folder, kind string, pattern *regexp.Regexp, variables ...string) (*dailyGrids, error) {
+	paths, err := filepath.Glob(filepath.Join(folder, "*.asc"))
+	if err != nil {
+		return nil, err
+	}
+
+	g := &dailyGrids{
+		kind:      kind,
+		variables: variables,
+		files:     make(map[string]map[string]string),
+		latest:    make(map[string]string),
+		cache:     make(map[string]Raster),
+		failed:    make(map[string]bool),
+	}
+	for _, variable := range variables {
+		g.files[variable] = make(map[string]string)
+	}
+	for _, path := range paths {
+		match := pattern.FindStringSubmatch(filepath.Base(path))
+		if match == nil {
+			continue
+		}
+		variable, day := strings.ToLower(match[1]), match[2]
+		if _, err := time.Parse("20060102", day); err != nil {
+			return nil, fmt.Errorf("%s: invalid date %s in the file name", path, day)
+		}
+		if other, dup := g.files[variable][day]; dup {
+			return nil, fmt.Errorf("%s and %s are both %s grids for %s", other, path, variable, day)
+		}
+		g.files[variable][day] = path
+	}
+
+	for day := range g.files[variables[0]] {
+		if g.hasDay(day) {
+			g.days = append(g.days, day)
+		}
+	}
+	if len(g.days) == 0 {
+		if _, err := os.Stat(folder); err != nil {
+			return nil, fmt.Errorf("error reading grid folder: %v", err)
+		}
+		return nil, fmt.Errorf("grid folder %s has no day with both a %s grid (*.asc)", folder, strings.Join(variables, " and a "))
+	}
+	sort.Strings(g.days)
+	for _, day := range g.days {
+		g.latest[day[4:]] = day
+	}
+	return g, nil
+}
+
+// hasDay reports whether the grids of every variable exist for a day (YYYYMMDD).
+func (g *dailyGrids) hasDay(day string) bool {
+	for _, variable := range g.variables {
+		if g.files[variable][day] == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Days returns the number of days with every grid, and the first and last of them.
+func (g *dailyGrids) Days() (int, time.Time, time.Time) {
+	first, _ := time.Parse("20060102", g.days[0])
+	last, _ := time.Parse("20060102", g.days[len(g.days)-1])
+	return len(g.days), first, last
+}
+
+// sample returns the values of the variables at the position on a date, in the order of g.variables.
+func (g *dailyGrids) sample(position OrderedPair, date time.Time) ([]float64, bool) {
+	day := date.Format("20060102")
+	if !g.hasDay(day) {
+		latest, ok := g.latest[date.Format("0102")]
+		if !ok {
+			return nil, false
+		}
+		day = latest
+	}
+
+	values := make([]float64, len(g.variables))
+	for i, variable := range g.variables {
+		grid, ok := g.grid(g.files[variable][day])
+		if !ok {
+			return nil, false
+		}
+		if values[i], ok = grid.ValueAt(position.x, position.y); !ok {
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+// grid returns the grid in a file, reading it if it is not in the cache.
+// A file that cannot be read is reported once and then treated as having no data.
+func (g *dailyGrids) grid(path string) (Raster, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if r, ok := g.cache[path]; ok {
+		return r, true
+	}
+	if g.failed[path] {
+		return Raster{}, false
+	}
+
+	r, err := ReadASCIIGrid(path)
+	if err != nil {
+		fmt.Printf("Error reading %s grid: %v\n", g.kind, err)
+		g.failed[path] = true
+		return Raster{}, false
+	}
+
+	if len(g.loaded) == 2*len(g.variables) {
+		delete(g.cache, g.loaded[0])
+		g.loaded = g.loaded[1:]
+	}
+	g.cache[path] = r
+	g.loaded = append(g.loaded, path)
+	return r, true
+}
+
+// GridTemperatures is a TemperatureProvider reading daily minimum and maximum temperatures, in °C, from a folder of
+// ESRI ASCII grids such as those of PRISM or Daymet (NetCDF and GeoTIFF grids can be converted with gdal_translate -of AAIGrid).
+// Each file holds one variable for one day, named with tmin or tmax and the date as YYYYMMDD (see dailyGrids).
+type GridTemperatures struct {
+	*dailyGrids
+}
+
+// NewGridTemperatures finds the daily grids in a folder. It fails if the folder has no day with both a tmin and a tmax grid.
+func NewGridTemperatures(folder string) (*GridTemperatures, error) {
+	g, err := newDailyGrids(folder, "temperature", gridFilePattern, "tmin", "tmax")
+	if err != nil {
+		return nil, err
+	}
+	return &GridTemperatures{g}, nil
+}
+
+// DailyTemperature samples the grids of the day at the position.
+func (g *GridTemperatures) DailyTemperature(position OrderedPair, date time.Time) (float64, float64, bool) {
+	values, ok := g.sample(position, date)
+	if !ok {
+		return 0, 0, false
+	}
+	return values[0], values[1], true
+}
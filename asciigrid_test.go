@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type ParseASCIIGridTest struct {
+	name   string
+	input  string
+	bounds Bounds
+	values []float64
+	fails  bool
+}
+
+type GridTemperaturesTest struct {
+	position   OrderedPair
+	day        string // YYYY-MM-DD
+	tmin, tmax float64
+	ok         bool
+}
+
+// formatASCIIGrid writes a raster as an ESRI ASCII grid, the way gdal_translate -of AAIGrid does.
+func formatASCIIGrid(r Raster, noData float64) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "ncols %d\nnrows %d\nxllcorner %v\nyllcorner %v\ncellsize %v\nNODATA_value %v\n",
+		r.cols, r.rows, r.bounds.minLon, r.bounds.minLat, r.cellSize, noData)
+	for row := 0; row < r.rows; row++ {
+		for col := 0; col < r.cols; col++ {
+			v := r.At(col, row)
+			if r.IsNoData(v) {
+				v = noData
+			}
+			if col > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func TestParseASCIIGrid(t *testing.T) {
+	tests := []ParseASCIIGridTest{
+		{
+			name:   "corner",
+			input:  "ncols 3\nnrows 2\nxllcorner -80\nyllcorner 40\ncellsize 0.5\n1 2 3\n4 5 6\n",
+			bounds: Bounds{minLon: -80, minLat: 40, maxLon: -78.5, maxLat: 41},
+			values: []float64{1, 2, 3, 4, 5, 6},
+		},
+		{
+			name:   "centre, upper case keys, values wrapped over lines",
+			input:  "NCOLS 2\nNROWS 2\nXLLCENTER -79.75\nYLLCENTER 40.25\nCELLSIZE 0.5\nNODATA_VALUE -9999\n1.5\n-9999 3e1\n-4\n",
+			bounds: Bounds{minLon: -80, minLat: 40, maxLon: -79, maxLat: 41},
+			values: []float64{1.5, -9999, 30, -4},
+		},
+		{name: "too few values", input: "ncols 2\nnrows 2\nxllcorner 0\nyllcorner 0\ncellsize 1\n1 2 3\n", fails: true},
+		{name: "bad value", input: "ncols 1\nnrows 2\nxllcorner 0\nyllcorner 0\ncellsize 1\n1 x\n", fails: true},
+		{name: "no corner", input: "ncols 1\nnrows 1\ncellsize 1\n1\n", fails: true},
+		{name: "no cell size", input: "ncols 1\nnrows 1\nxllcorner 0\nyllcorner 0\n1\n", fails: true},
+		{name: "bad header value", input: "ncols one\nnrows 1\nxllcorner 0\nyllcorner 0\ncellsize 1\n1\n", fails: true},
+		{name: "no values", input: "ncols 1\nnrows 1\nxllcorner 0\nyllcorner 0\ncellsize 1\n", fails: true},
+		{name: "empty", input: "", fails: true},
+	}
+
+	for _, test := range tests {
+		r, err := ParseASCIIGrid(strings.NewReader(test.input))
+		if (err != nil) != test.fails {
+			t.Errorf("ParseASCIIGrid(%s) error = %v, want error %v", test.name, err, test.fails)
+			continue
+		}
+		if test.fails {
+			continue
+		}
+		if r.bounds != test.bounds {
+			t.Errorf("ParseASCIIGrid(%s) bounds = %+v, want %+v", test.name, r.bounds, test.bounds)
+		}
+		if len(r.values) != len(test.values) {
+			t.Errorf("ParseASCIIGrid(%s) read %d values, want %d", test.name, len(r.values), len(test.values))
+			continue
+		}
+		for i := range test.values {
+			if r.values[i] != test.values[i] {
+				t.Errorf("ParseASCIIGrid(%s) values = %v, want %v", test.name, r.values, test.values)
+				break
+			}
+		}
+	}
+}
+
+// TestASCIIGridRoundTrip writes a raster as an ASCII grid, reads it back and compares every cell.
+func TestASCIIGridRoundTrip(t *testing.T) {
+	r := NewRaster(Bounds{minLon: -80.25, minLat: 39.5, maxLon: -77, maxLat: 42.25}, 0.25, 0)
+	for i := range r.values {
+		r.values[i] = math.Round(100*math.Sin(float64(i))) / 10
+	}
+	r.Set(3, 2, math.NaN())
+
+	path := writeTestFile(t, "grid.asc", formatASCIIGrid(r, -9999))
+	back, err := ReadASCIIGrid(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.cols != r.cols || back.rows != r.rows || back.bounds != r.bounds || back.cellSize != r.cellSize {
+		t.Fatalf("read back a %d x %d grid of %v over %+v, want %d x %d of %v over %+v",
+			back.cols, back.rows, back.cellSize, back.bounds, r.cols, r.rows, r.cellSize, r.bounds)
+	}
+	for row := 0; row < r.rows; row++ {
+		for col := 0; col < r.cols; col++ {
+			lon, lat := r.CellCenter(col, row)
+			want, wantOK := r.ValueAt(lon, lat)
+			got, ok := back.ValueAt(lon, lat)
+			if ok != wantOK || (ok && got != want) {
+				t.Errorf("cell %d, %d read back as %v (%v), want %v (%v)", col, row, got, ok, want, wantOK)
+			}
+		}
+	}
+
+	if _, err := ReadASCIIGrid(filepath.Join(t.TempDir(), "missing.asc")); err == nil {
+		t.Errorf("ReadASCIIGrid of a missing file did not fail")
+	}
+}
+
+func TestGridTemperatures(t *testing.T) {
+	dir := t.TempDir()
+	grid := func(value string) string {
+		return "ncols 2\nnrows 1\nxllcorner -80\nyllcorner 40\ncellsize 1\nNODATA_value -9999\n" + value + " -9999\n"
+	}
+	files := map[string]string{
+		"tmin_20220601.asc":                    grid("10"),
+		"tmax_20220601.asc":                    grid("25"),
+		"PRISM_tmin_stable_4kmD2_20230601.asc": grid("12"),
+		"PRISM_tmax_stable_4kmD2_20230601.asc": grid("28"),
+		"tmin_20230602.asc":                    grid("11"), // no tmax for this day
+		"readme.txt":                           "",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g, err := NewGridTemperatures(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, first, last := g.Days(); n != 2 || !first.Equal(date(2022, 6, 1)) || !last.Equal(date(2023, 6, 1)) {
+		t.Errorf("Days() = %d from %v to %v, want 2 from 2022-06-01 to 2023-06-01", n, first, last)
+	}
+
+	tests := []GridTemperaturesTest{
+		{position: OrderedPair{-79.5, 40.5}, day: "2022-06-01", tmin: 10, tmax: 25, ok: true},
+		{position: OrderedPair{-79.5, 40.5}, day: "2023-06-01", tmin: 12, tmax: 28, ok: true},
+		// a year without grids uses the latest year that has the day
+		{position: OrderedPair{-79.5, 40.5}, day: "2025-06-01", tmin: 12, tmax: 28, ok: true},
+		{position: OrderedPair{-79.5, 40.5}, day: "2023-06-02", ok: false},
+		{position: OrderedPair{-78.5, 40.5}, day: "2022-06-01", ok: false}, // no data
+		{position: OrderedPair{-81, 40.5}, day: "2022-06-01", ok: false},   // outside the grid
+	}
+	for _, test := range tests {
+		day, err := ParseDate(test.day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmin, tmax, ok := g.DailyTemperature(test.position, day)
+		if ok != test.ok || (ok && (tmin != test.tmin || tmax != test.tmax)) {
+			t.Errorf("DailyTemperature(%v, %s) = %v, %v, %v, want %v, %v, %v", test.position, test.day, tmin, tmax, ok, test.tmin, test.tmax, test.ok)
+		}
+	}
+
+	// folders that cannot be used
+	empty := t.TempDir()
+	if _, err := NewGridTemperatures(empty); err == nil {
+		t.Errorf("NewGridTemperatures of a folder without grids did not fail")
+	}
+	duplicate := t.TempDir()
+	for _, name := range []string{"tmin_20220601.asc", "tmax_20220601.asc", "a_tmax_20220601.asc"} {
+		if err := os.WriteFile(filepath.Join(duplicate, name), []byte(grid("1")), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := NewGridTemperatures(duplicate); err == nil {
+		t.Errorf("NewGridTemperatures with two tmax grids for a day did not fail")
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"canvas"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+)
+
+// Boundary is an administrative area (a state) read from a boundary file.
+// Every polygon is a list of rings; the first ring is the outline and any further rings are holes.
+type Boundary struct {
+	name     string
+	postal   string
+	polygons [][][]OrderedPair
+}
+
+// Basemap holds everything drawn underneath the flies: the state outlines, the grid cells of the weather quadrants and a lat/lon graticule.
+// It also carries the title printed on every frame.
+type Basemap struct {
+	title      string
+	bounds     Bounds
+	projection string
+	states     []Boundary
+	quadrants  []Quadrant
+	graticule  float64 // spacing of the graticule lines in degrees, 0 disables the graticule
+}
+
+// geoJSONFile is the subset of a GeoJSON FeatureCollection read by LoadBoundaries.
+type geoJSONFile struct {
+	Features []struct {
+		Properties map[string]interface{} `json:"properties"`
+		Geometry   struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		} `json:"geometry"`
+	} `json:"features"`
+}
+
+// LoadBoundaries reads state outlines from a GeoJSON FeatureCollection of Polygon and MultiPolygon features.
+// The state name is taken from the "name" (or "NAME") property and the postal code from "postal" (or "STUSPS"),
+// so Census cartographic boundary files converted to GeoJSON can be used in place of the bundled Data/us_states.geojson.
+func LoadBoundaries(filePath string) ([]Boundary, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading boundary file: %v", err)
+	}
+
+	var file geoJSONFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error parsing boundary file %s: %v", filePath, err)
+	}
+
+	var boundaries []Boundary
+	for i, feature := range file.Features {
+		boundary := Boundary{
+			name:   propertyString(feature.Properties, "name", "NAME"),
+			postal: propertyString(feature.Properties, "postal", "STUSPS"),
+		}
+
+		switch feature.Geometry.Type {
+		case "Polygon":
+			var polygon [][][2]float64
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &polygon); err != nil {
+				return nil, fmt.Errorf("error parsing feature %d (%s) in %s: %v", i, boundary.name, filePath, err)
+			}
+			boundary.polygons = append(boundary.polygons, toRings(polygon))
+		case "MultiPolygon":
+			var polygons [][][][2]float64
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &polygons); err != nil {
+				return nil, fmt.Errorf("error parsing feature %d (%s) in %s: %v", i, boundary.name, filePath, err)
+			}
+			for _, polygon := range polygons {
+				boundary.polygons = append(boundary.polygons, toRings(polygon))
+			}
+		default:
+			return nil, fmt.Errorf("feature %d (%s) in %s has unsupported geometry type %q", i, boundary.name, filePath, feature.Geometry.Type)
+		}
+
+		boundaries = append(boundaries, boundary)
+	}
+
+	return boundaries, nil
+}
+
+// propertyString returns the first of the given feature properties that is a string.
+func propertyString(properties map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if s, ok := properties[key].(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// toRings converts GeoJSON [longitude, latitude] coordinates into rings of OrderedPairs.
+func toRings(polygon [][][2]float64) [][]OrderedPair {
+	rings := make([][]OrderedPair, len(polygon))
+	for i, ring := range polygon {
+		rings[i] = make([]OrderedPair, len(ring))
+		for j, point := range ring {
+			rings[i][j] = OrderedPair{x: point[0], y: point[1]}
+		}
+	}
+	return rings
+}
+
+// NewBasemap loads the state outlines from boundaryFile and sets up a basemap covering bounds.
+func NewBasemap(title, boundaryFile string, bounds Bounds, projection string, quadrants []Quadrant, graticule float64) (Basemap, error) {
+	states, err := LoadBoundaries(boundaryFile)
+	if err != nil {
+		return Basemap{}, err
+	}
+
+	return Basemap{
+		title:      title,
+		bounds:     bounds,
+		projection: projection,
+		states:     states,
+		quadrants:  quadrants,
+		graticule:  graticule,
+	}, nil
+}
+
+// Projection fits the basemap's projection to a canvas of the given size.
+func (b Basemap) Projection(canvasWidth, canvasHeight int) (MapProjection, error) {
+	margin := 0.02 * float64(canvasWidth)
+	return NewProjection(b.projection, b.bounds, canvasWidth, canvasHeight, margin)
+}
+
+// DrawBasemap draws the basemap on the canvas: filled state outlines first, then the graticule and the quadrant borders on top.
+// Line widths are proportional to the canvas width so the map looks the same at any resolution.
+func DrawBasemap(c canvas.Drawer, basemap Basemap, projection Projection) {
+	lineWidth := float64(c.Width()) / 2000
+
+	// states
+	c.SetLineWidth(lineWidth)
+	c.SetStrokeColor(canvas.MakeColor(130, 130, 130))
+	c.SetFillColor(canvas.MakeColor(35, 35, 35))
+	for _, state := range basemap.states {
+		// the rings of a polygon are painted as one path, so its holes stay empty under the even-odd rule
+		for _, polygon := range state.polygons {
+			for _, ring := range polygon {
+				tracePath(c, ring, projection)
+			}
+			c.FillStroke()
+		}
+	}
+
+	// lat/lon graticule
+	if basemap.graticule > 0 {
+		c.SetLineWidth(lineWidth / 2)
+		c.SetStrokeColor(canvas.MakeColor(70, 70, 70))
+		b := basemap.bounds
+		for lon := math.Ceil(b.minLon/basemap.graticule) * basemap.graticule; lon <= b.maxLon; lon += basemap.graticule {
+			traceLine(c, OrderedPair{lon, b.minLat}, OrderedPair{lon, b.maxLat}, projection)
+			c.Stroke()
+		}
+		for lat := math.Ceil(b.minLat/basemap.graticule) * basemap.graticule; lat <= b.maxLat; lat += basemap.graticule {
+			traceLine(c, OrderedPair{b.minLon, lat}, OrderedPair{b.maxLon, lat}, projection)
+			c.Stroke()
+		}
+	}
+
+	// grid cell borders
+	c.SetLineWidth(lineWidth)
+	c.SetStrokeColor(canvas.MakeColor(110, 110, 40))
+	for _, q := range basemap.quadrants {
+		corners := []OrderedPair{{q.x, q.y}, {q.x + q.width, q.y}, {q.x + q.width, q.y + q.height}, {q.x, q.y + q.height}, {q.x, q.y}}
+		for i := 0; i+1 < len(corners); i++ {
+			traceLine(c, corners[i], corners[i+1], projection)
+			c.Stroke()
+		}
+	}
+}
+
+// tracePath adds a closed path through the projected points of a ring to the canvas.
+func tracePath(c canvas.Drawer, ring []OrderedPair, projection Projection) {
+	for i, point := range ring {
+		x, y := projection.Project(point.x, point.y)
+		if i == 0 {
+			c.MoveTo(x, y)
+		} else {
+			c.LineTo(x, y)
+		}
+	}
+	c.ClosePath()
+}
+
+// traceLine adds the line between two positions to the canvas.
+// The line is cut into short segments so that it follows the curvature of parallels in conic projections.
+func traceLine(c canvas.Drawer, from, to OrderedPair, projection Projection) {
+	const segments = 32
+	for i := 0; i <= segments; i++ {
+		t := float64(i) / segments
+		x, y := projection.Project(from.x+t*(to.x-from.x), from.y+t*(to.y-from.y))
+		if i == 0 {
+			c.MoveTo(x, y)
+		} else {
+			c.LineTo(x, y)
+		}
+	}
+}
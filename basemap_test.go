@@ -0,0 +1,162 @@
+package main
+
+import (
+	"canvas"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type LoadBoundariesTest struct {
+	geojson string
+	names   []string
+	postals []string
+	rings   [][]int // rings of every polygon of every boundary
+	fails   bool
+}
+
+type ProjectionTest struct {
+	name  string
+	fails bool
+}
+
+// writeTestFile writes contents to a file called name in a temporary folder and returns its path.
+func writeTestFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadBoundaries(t *testing.T) {
+	tests := []LoadBoundariesTest{
+		// a polygon with a hole
+		{geojson: `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{"name":"Ring","postal":"RG"},"geometry":{"type":"Polygon",
+			"coordinates":[[[-80,40],[-78,40],[-78,42],[-80,42],[-80,40]],[[-79.5,40.5],[-79.5,41.5],[-78.5,41.5],[-78.5,40.5],[-79.5,40.5]]]}}]}`,
+			names: []string{"Ring"}, postals: []string{"RG"}, rings: [][]int{{2}}},
+		// Census property names and a multipolygon
+		{geojson: `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{"NAME":"Michigan","STUSPS":"MI"},"geometry":{"type":"MultiPolygon",
+			"coordinates":[[[[-87,45],[-84,45],[-84,46],[-87,45]]],[[[-86,42],[-83,42],[-83,44],[-86,42]]]]}}]}`,
+			names: []string{"Michigan"}, postals: []string{"MI"}, rings: [][]int{{1, 1}}},
+		// no name at all
+		{geojson: `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{},"geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}}]}`,
+			names: []string{""}, postals: []string{""}, rings: [][]int{{1}}},
+		{geojson: `{"type":"FeatureCollection","features":[]}`},
+		{geojson: `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{},"geometry":{"type":"Point","coordinates":[0,0]}}]}`, fails: true},
+		{geojson: `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{},"geometry":{"type":"Polygon","coordinates":[0,0]}}]}`, fails: true},
+		{geojson: `not json`, fails: true},
+	}
+
+	for i, test := range tests {
+		boundaries, err := LoadBoundaries(writeTestFile(t, "states.geojson", test.geojson))
+		if (err != nil) != test.fails {
+			t.Errorf("LoadBoundaries(test %d) error = %v, want error %v", i, err, test.fails)
+			continue
+		}
+		if len(boundaries) != len(test.names) {
+			t.Errorf("LoadBoundaries(test %d) read %d boundaries, want %d", i, len(boundaries), len(test.names))
+			continue
+		}
+		for j, b := range boundaries {
+			if b.name != test.names[j] || b.postal != test.postals[j] {
+				t.Errorf("LoadBoundaries(test %d) boundary %d is %q (%q), want %q (%q)", i, j, b.name, b.postal, test.names[j], test.postals[j])
+			}
+			if len(b.polygons) != len(test.rings[j]) {
+				t.Errorf("LoadBoundaries(test %d) boundary %d has %d polygons, want %d", i, j, len(b.polygons), len(test.rings[j]))
+				continue
+			}
+			for k, polygon := range b.polygons {
+				if len(polygon) != test.rings[j][k] {
+					t.Errorf("LoadBoundaries(test %d) polygon %d has %d rings, want %d", i, k, len(polygon), test.rings[j][k])
+				}
+			}
+		}
+	}
+
+	if _, err := LoadBoundaries(filepath.Join(t.TempDir(), "missing.geojson")); err == nil {
+		t.Errorf("LoadBoundaries of a missing file did not fail")
+	}
+}
+
+// TestDrawBasemapHole checks that the outline and the hole of a polygon are painted as one path filled with the even-odd rule,
+// so that the hole is left empty.
+func TestDrawBasemapHole(t *testing.T) {
+	path := writeTestFile(t, "states.geojson", `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{"name":"Ring"},"geometry":{"type":"Polygon",
+		"coordinates":[[[-80,40],[-78,40],[-78,42],[-80,42],[-80,40]],[[-79.5,40.5],[-79.5,41.5],[-78.5,41.5],[-78.5,40.5],[-79.5,40.5]]]}}]}`)
+	bounds := Bounds{minLon: -81, minLat: 39, maxLon: -77, maxLat: 43}
+	basemap, err := NewBasemap("", path, bounds, "equirectangular", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	projection, err := basemap.Projection(200, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := canvas.CreateNewSVGCanvas(200, 200)
+	DrawBasemap(c, basemap, projection)
+	svgFile := filepath.Join(t.TempDir(), "map.svg")
+	if err := c.SaveToSVG(svgFile); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(svgFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var filled []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "<path") && !strings.Contains(line, `fill="none"`) {
+			filled = append(filled, line)
+		}
+	}
+	if len(filled) != 1 {
+		t.Fatalf("the polygon was painted as %d filled paths, want 1:\n%s", len(filled), data)
+	}
+	if strings.Count(filled[0], "M") != 2 || !strings.Contains(filled[0], `fill-rule="evenodd"`) {
+		t.Errorf("the polygon path does not hold both rings filled with the even-odd rule: %s", filled[0])
+	}
+}
+
+func TestNewProjection(t *testing.T) {
+	tests := []ProjectionTest{
+		{name: "equirectangular"},
+		{name: ""},
+		{name: "Albers"},
+		{name: "mercator", fails: true},
+	}
+
+	bounds := ContiguousUS()
+	const width, height, margin = 800, 500, 10
+	for _, test := range tests {
+		p, err := NewProjection(test.name, bounds, width, height, margin)
+		if (err != nil) != test.fails {
+			t.Errorf("NewProjection(%q) error = %v, want error %v", test.name, err, test.fails)
+			continue
+		}
+		if test.fails {
+			continue
+		}
+
+		// the bounding box fits inside the margins
+		for _, corner := range [][2]float64{{bounds.minLon, bounds.minLat}, {bounds.maxLon, bounds.minLat}, {bounds.minLon, bounds.maxLat}, {bounds.maxLon, bounds.maxLat}} {
+			x, y := p.Project(corner[0], corner[1])
+			if x < margin-1e-6 || x > width-margin+1e-6 || y < margin-1e-6 || y > height-margin+1e-6 {
+				t.Errorf("%s projection puts %v at (%.1f, %.1f), outside the margins", p.name, corner, x, y)
+			}
+		}
+
+		// north is up and east is right
+		centerLon, centerLat := (bounds.minLon+bounds.maxLon)/2, (bounds.minLat+bounds.maxLat)/2
+		x0, y0 := p.Project(centerLon, centerLat)
+		xn, yn := p.Project(centerLon, centerLat+1)
+		xe, _ := p.Project(centerLon+1, centerLat)
+		if yn >= y0 || math.Abs(xn-x0) > 1e-6 || xe <= x0 {
+			t.Errorf("%s projection is not north up and east right at the centre", p.name)
+		}
+	}
+}
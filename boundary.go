@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Boundary policies, selectable with -boundary. They decide what happens to a fly that moves out of the bounding box of the
+// study region, where the weather quadrants end.
+const (
+	BoundaryAbsorb  = "absorb"  // the fly is removed and counted as emigrated
+	BoundaryReflect = "reflect" // the fly is mirrored back in at the edge it crossed
+	BoundaryClamp   = "clamp"   // the fly stops at the edge
+	BoundaryWrap    = "wrap"    // the fly comes back in at the opposite edge; for testing, as the region has no real neighbours
+)
+
+// BoundaryPolicy keeps the flies of a run inside the bounding box of its study region.
+// The zero value does nothing, so flies may drift outside every quadrant.
+type BoundaryPolicy struct {
+	mode   string
+	bounds Bounds
+}
+
+// NewBoundaryPolicy checks the name of a boundary policy for the given bounding box.
+func NewBoundaryPolicy(mode string, bounds Bounds) (BoundaryPolicy, error) {
+	b := BoundaryPolicy{mode: strings.ToLower(mode), bounds: bounds}
+	switch b.mode {
+	case BoundaryAbsorb, BoundaryReflect, BoundaryClamp, BoundaryWrap:
+		return b, nil
+	}
+	return BoundaryPolicy{}, fmt.Errorf("unknown boundary policy %q (want absorb, reflect, clamp or wrap)", mode)
+}
+
+// Apply returns where a fly that moved to position ends up, and false if it has left the region under the absorbing policy.
+func (b BoundaryPolicy) Apply(position OrderedPair) (OrderedPair, bool) {
+	switch b.mode {
+	case BoundaryAbsorb:
+		return position, b.bounds.Contains(position)
+	case BoundaryReflect:
+		return OrderedPair{x: reflectInto(position.x, b.bounds.minLon, b.bounds.maxLon), y: reflectInto(position.y, b.bounds.minLat, b.bounds.maxLat)}, true
+	case BoundaryClamp:
+		return OrderedPair{x: math.Min(math.Max(position.x, b.bounds.minLon), b.bounds.maxLon), y: math.Min(math.Max(position.y, b.bounds.minLat), b.bounds.maxLat)}, true
+	case BoundaryWrap:
+		return OrderedPair{x: wrapInto(position.x, b.bounds.minLon, b.bounds.maxLon), y: wrapInto(position.y, b.bounds.minLat, b.bounds.maxLat)}, true
+	}
+	return position, true
+}
+
+// reflectInto mirrors v at the edges lo and hi until it lies between them, so a jump longer than the interval folds back and forth.
+func reflectInto(v, lo, hi float64) float64 {
+	width := hi - lo
+	t := math.Mod(v-lo, 2*width)
+	if t < 0 {
+		t += 2 * width
+	}
+	if t > width {
+		t = 2*width - t
+	}
+	return lo + t
+}
+
+// wrapInto moves v by whole intervals until it lies between lo and hi.
+func wrapInto(v, lo, hi float64) float64 {
+	width := hi - lo
+	t := math.Mod(v-lo, width)
+	if t < 0 {
+		t += width
+	}
+	return lo + t
+}
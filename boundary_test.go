@@ -0,0 +1,134 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+type IntervalTest struct {
+	v      float64
+	result float64
+}
+
+type BoundaryPolicyTest struct {
+	mode     string
+	position OrderedPair
+	result   OrderedPair
+	inside   bool
+}
+
+// TestReflectInto includes jumps longer than the interval, which fold back and forth more than once.
+func TestReflectInto(t *testing.T) {
+	// the interval 10 to 14
+	tests := []IntervalTest{
+		{v: 12, result: 12},
+		{v: 10, result: 10},
+		{v: 14, result: 14},
+		{v: 15, result: 13},
+		{v: 9, result: 11},
+		{v: 18, result: 10},
+		{v: 19, result: 11}, // past hi, back past lo, and in again
+		{v: 23.5, result: 12.5},
+		{v: 1, result: 11}, // 9 below lo: across the interval twice and 1 back in
+		{v: -30, result: 10},
+	}
+	for _, test := range tests {
+		if result := reflectInto(test.v, 10, 14); math.Abs(result-test.result) > 1e-9 {
+			t.Errorf("reflectInto(%v, 10, 14) = %v, want %v", test.v, result, test.result)
+		}
+	}
+}
+
+func TestWrapInto(t *testing.T) {
+	tests := []IntervalTest{
+		{v: 12, result: 12},
+		{v: 10, result: 10},
+		{v: 15, result: 11},
+		{v: 9, result: 13},
+		{v: 23, result: 11}, // three intervals past
+		{v: -30.5, result: 13.5},
+	}
+	for _, test := range tests {
+		if result := wrapInto(test.v, 10, 14); math.Abs(result-test.result) > 1e-9 {
+			t.Errorf("wrapInto(%v, 10, 14) = %v, want %v", test.v, result, test.result)
+		}
+	}
+}
+
+func TestBoundaryPolicy(t *testing.T) {
+	bounds := Bounds{minLon: -80, minLat: 40, maxLon: -76, maxLat: 42}
+	tests := []BoundaryPolicyTest{
+		{mode: BoundaryAbsorb, position: OrderedPair{-78, 41}, result: OrderedPair{-78, 41}, inside: true},
+		{mode: BoundaryAbsorb, position: OrderedPair{-75, 41}, result: OrderedPair{-75, 41}, inside: false},
+		{mode: BoundaryReflect, position: OrderedPair{-75, 39.5}, result: OrderedPair{-77, 40.5}, inside: true},
+		{mode: BoundaryReflect, position: OrderedPair{-90, 47}, result: OrderedPair{-78, 41}, inside: true},
+		{mode: BoundaryClamp, position: OrderedPair{-90, 47}, result: OrderedPair{-80, 42}, inside: true},
+		{mode: BoundaryClamp, position: OrderedPair{-78, 41}, result: OrderedPair{-78, 41}, inside: true},
+		{mode: BoundaryWrap, position: OrderedPair{-75, 39.5}, result: OrderedPair{-79, 41.5}, inside: true},
+		{mode: BoundaryWrap, position: OrderedPair{-91, 47}, result: OrderedPair{-79, 41}, inside: true},
+	}
+
+	for _, test := range tests {
+		b, err := NewBoundaryPolicy(test.mode, bounds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, inside := b.Apply(test.position)
+		if math.Abs(result.x-test.result.x) > 1e-9 || math.Abs(result.y-test.result.y) > 1e-9 || inside != test.inside {
+			t.Errorf("Apply(%s, %v) = %v, %v, want %v, %v", test.mode, test.position, result, inside, test.result, test.inside)
+		}
+	}
+
+	if _, err := NewBoundaryPolicy("Reflect", bounds); err != nil {
+		t.Errorf("NewBoundaryPolicy(Reflect) error = %v", err)
+	}
+	if _, err := NewBoundaryPolicy("bounce", bounds); err == nil {
+		t.Errorf("NewBoundaryPolicy(bounce) did not fail")
+	}
+	if result, inside := (BoundaryPolicy{}).Apply(OrderedPair{-100, 50}); result != (OrderedPair{-100, 50}) || !inside {
+		t.Errorf("the zero policy moved a fly to %v, %v", result, inside)
+	}
+}
+
+// TestDefaultSeasonLaysEggs runs a season of an introduction with the default movement, mortality and reproduction in the
+// contiguous US with the absorbing boundary, and checks that adults stay in the region long enough to mate and lay eggs.
+func TestDefaultSeasonLaysEggs(t *testing.T) {
+	region := Region{name: "contiguous US", bounds: ContiguousUS()}
+	boundary, err := NewBoundaryPolicy(BoundaryAbsorb, region.bounds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeding, err := NewSeeding(SeedIntroduction, 0, 0, BerksCounty, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a mild summer everywhere, and host trees around the introduction point
+	weather := Weather{Quadrants: []Quadrant{{x: region.bounds.minLon, y: region.bounds.minLat, width: region.bounds.maxLon - region.bounds.minLon,
+		height: region.bounds.maxLat - region.bounds.minLat, id: 0, temp: 26, minTemp: 12}}}
+	var trees []Tree
+	for i := 0; i < 20; i++ {
+		trees = append(trees, Tree{position: offsetKm(BerksCounty, 0.05*float64(i%5), float64(i))})
+	}
+
+	country, err := InitializeCountry(region, boundary, StageMovement{}, Reproduction{}, Mortality{}, weather, trees, nil, nil, seeding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	country.date = date(2021, 5, 1)
+	clock := NewClock(country.date, Daily)
+	RegisterLifecycleEvents(clock)
+	for clock.Date().Before(date(2021, 11, 30)) {
+		country = StepSimulation(country, weather, clock)
+	}
+
+	adults := 0
+	for _, fly := range country.flies {
+		if fly.energy >= instar4ToAdultThreshold && !fly.emigrated {
+			adults++
+		}
+	}
+	if len(country.eggs) == 0 || country.emigrated > adults {
+		t.Errorf("a default season laid %d eggs; %d of %d flies emigrated, and %d reached adulthood in the region",
+			len(country.eggs), country.emigrated, len(country.flies), adults)
+	}
+}
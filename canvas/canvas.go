@@ -17,9 +17,19 @@ import (
 	"math"
 	"os"
 
+	"github.com/golang/freetype/truetype"
+	"github.com/llgcode/draw2d"
 	"github.com/llgcode/draw2d/draw2dimg"
 )
 
+// fontData names the font loaded with LoadFont; fontLoaded is false until a font has been loaded
+var fontData = draw2d.FontData{Name: "canvas", Family: draw2d.FontFamilySans, Style: draw2d.FontStyleNormal}
+var fontLoaded bool
+
+// loadedFont and fontFile are the parsed font and its TrueType file, used by the vector backends to measure and embed text
+var loadedFont *truetype.Font
+var fontFile []byte
+
 type Canvas struct {
 	gc     *draw2dimg.GraphicContext
 	img    image.Image
@@ -38,6 +48,7 @@ func CreateNewCanvas(w, h int) Canvas {
 
 	gc.SetStrokeColor(image.Black)
 	gc.SetFillColor(image.White)
+	gc.SetFillRule(draw2d.FillRuleEvenOdd)
 	// fill the background
 	gc.Clear()
 	gc.SetFillColor(image.Black)
@@ -55,6 +66,7 @@ func CreateNewPalettedCanvas(w, h int, cp color.Palette) Canvas {
 
 	gc.SetStrokeColor(image.Black)
 	gc.SetFillColor(image.White)
+	gc.SetFillRule(draw2d.FillRuleEvenOdd)
 	// fill the background
 	gc.Clear()
 	gc.SetFillColor(image.Black)
@@ -83,6 +95,11 @@ func (c *Canvas) ArcTo(x, y, radiusX, radiusY, degStart, degEnd float64) {
 	c.gc.ArcTo(x, y, radiusX, radiusY, degStart, degEnd)
 }
 
+// Close the current path by drawing a line back to its first point
+func (c *Canvas) ClosePath() {
+	c.gc.Close()
+}
+
 // Set the line color
 func (c *Canvas) SetStrokeColor(col color.Color) {
 	c.gc.SetStrokeColor(col)
@@ -140,6 +157,57 @@ func (c *Canvas) Ellipse(cx, cy, rx, ry float64) {
 	c.gc.Close()
 }
 
+// Add a rectangle with top left corner (x,y) to the current path
+func (c *Canvas) Rect(x, y, w, h float64) {
+	c.gc.MoveTo(x, y)
+	c.gc.LineTo(x+w, y)
+	c.gc.LineTo(x+w, y+h)
+	c.gc.LineTo(x, y+h)
+	c.gc.Close()
+}
+
+// Load a TrueType font from a file and use it for all text drawn on canvases
+func LoadFont(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("error reading font: %v", err)
+	}
+	font, err := truetype.Parse(data)
+	if err != nil {
+		return fmt.Errorf("error parsing font %s: %v", filename, err)
+	}
+	draw2d.RegisterFont(fontData, font)
+	fontLoaded = true
+	loadedFont = font
+	fontFile = data
+	return nil
+}
+
+// Set the font size in pixels
+func (c *Canvas) SetFontSize(size float64) {
+	c.gc.SetFontData(fontData)
+	c.gc.SetDPI(72) // at 72 dpi one point is one pixel
+	c.gc.SetFontSize(size)
+}
+
+// Fill the text with the fill color, with the left end of its baseline at (x,y)
+// Returns the width of the text. Does nothing if no font has been loaded.
+func (c *Canvas) FillText(text string, x, y float64) float64 {
+	if !fontLoaded {
+		return 0
+	}
+	return c.gc.FillStringAt(text, x, y)
+}
+
+// Return the width and height of the text in the current font size
+func (c *Canvas) TextSize(text string) (float64, float64) {
+	if !fontLoaded {
+		return 0, 0
+	}
+	left, top, right, bottom := c.gc.GetStringBounds(text)
+	return right - left, bottom - top
+}
+
 // Save the current canvas to a PNG file
 func (c *Canvas) SaveToPNG(filename string) {
 	f, err := os.Create(filename)
@@ -0,0 +1,75 @@
+package canvas
+
+import "image/color"
+
+// Drawer is a drawing backend. Canvas rasterises into an image; SVGCanvas and PDFCanvas record resolution-independent vector files.
+// Paths are built with MoveTo, LineTo, ArcTo, Circle, Ellipse and Rect and painted (and cleared) by Stroke, Fill or FillStroke.
+// Fills use the even-odd rule, so a closed subpath inside another, such as the hole of a polygon, is left unpainted.
+// Coordinates are in pixels (points for PDF) with y growing downwards.
+type Drawer interface {
+	MoveTo(x, y float64)
+	LineTo(x, y float64)
+	ArcTo(x, y, radiusX, radiusY, startAngle, angle float64)
+	ClosePath()
+	Circle(cx, cy, r float64)
+	Ellipse(cx, cy, rx, ry float64)
+	Rect(x, y, w, h float64)
+
+	SetStrokeColor(col color.Color)
+	SetFillColor(col color.Color)
+	SetLineWidth(w float64)
+	Stroke()
+	Fill()
+	FillStroke()
+
+	SetFontSize(size float64)
+	FillText(text string, x, y float64) float64
+	TextSize(text string) (float64, float64)
+
+	Width() int
+	Height() int
+}
+
+// Panel is a rectangular part of another Drawer with its own origin, used to draw several maps side by side.
+// Everything drawn on the panel is shifted by the panel's top left corner; nothing is clipped.
+type Panel struct {
+	d      Drawer
+	x, y   float64
+	width  int
+	height int
+}
+
+// Create a panel of size w x h whose top left corner is at (x,y) on d
+func NewPanel(d Drawer, x, y float64, w, h int) *Panel {
+	return &Panel{d: d, x: x, y: y, width: w, height: h}
+}
+
+func (p *Panel) MoveTo(x, y float64) { p.d.MoveTo(p.x+x, p.y+y) }
+func (p *Panel) LineTo(x, y float64) { p.d.LineTo(p.x+x, p.y+y) }
+func (p *Panel) ArcTo(x, y, radiusX, radiusY, startAngle, angle float64) {
+	p.d.ArcTo(p.x+x, p.y+y, radiusX, radiusY, startAngle, angle)
+}
+func (p *Panel) ClosePath()                     { p.d.ClosePath() }
+func (p *Panel) Circle(cx, cy, r float64)       { p.d.Circle(p.x+cx, p.y+cy, r) }
+func (p *Panel) Ellipse(cx, cy, rx, ry float64) { p.d.Ellipse(p.x+cx, p.y+cy, rx, ry) }
+func (p *Panel) Rect(x, y, w, h float64)        { p.d.Rect(p.x+x, p.y+y, w, h) }
+func (p *Panel) SetStrokeColor(col color.Color) { p.d.SetStrokeColor(col) }
+func (p *Panel) SetFillColor(col color.Color)   { p.d.SetFillColor(col) }
+func (p *Panel) SetLineWidth(w float64)         { p.d.SetLineWidth(w) }
+func (p *Panel) Stroke()                        { p.d.Stroke() }
+func (p *Panel) Fill()                          { p.d.Fill() }
+func (p *Panel) FillStroke()                    { p.d.FillStroke() }
+func (p *Panel) SetFontSize(size float64)       { p.d.SetFontSize(size) }
+func (p *Panel) FillText(text string, x, y float64) float64 {
+	return p.d.FillText(text, p.x+x, p.y+y)
+}
+func (p *Panel) TextSize(text string) (float64, float64) { return p.d.TextSize(text) }
+func (p *Panel) Width() int                              { return p.width }
+func (p *Panel) Height() int                             { return p.height }
+
+var (
+	_ Drawer = (*Canvas)(nil)
+	_ Drawer = (*SVGCanvas)(nil)
+	_ Drawer = (*PDFCanvas)(nil)
+	_ Drawer = (*Panel)(nil)
+)
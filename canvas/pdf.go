@@ -0,0 +1,190 @@
+package canvas
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/math/fixed"
+)
+
+// PDFCanvas records drawing as a single-page PDF, one point per pixel.
+// If a font has been loaded, it is embedded as a TrueType font with WinAnsi encoding; characters outside Latin-1 are drawn as '?'.
+// Colours are drawn opaque.
+type PDFCanvas struct {
+	vectorState
+	content bytes.Buffer
+}
+
+// Create a new PDF canvas of w x h points
+func CreateNewPDFCanvas(w, h int) *PDFCanvas {
+	c := &PDFCanvas{vectorState: newVectorState(w, h)}
+	// flip the y axis so that, as on the other canvases, y grows downwards
+	fmt.Fprintf(&c.content, "1 0 0 -1 0 %d cm\n", h)
+	return c
+}
+
+// Actually draw the lines you've set up with LineTo
+func (c *PDFCanvas) Stroke() {
+	c.writePath("S")
+}
+
+// Fill the area inside the lines you've set up with LineTo, but don't
+// draw the lines
+func (c *PDFCanvas) Fill() {
+	c.writePath("f*")
+}
+
+// Fill the area inside the lines you've set up with LineTo
+func (c *PDFCanvas) FillStroke() {
+	c.writePath("B*")
+}
+
+// Fill the text with the fill color, with the left end of its baseline at (x,y)
+// Returns the width of the text. Does nothing if no font has been loaded.
+func (c *PDFCanvas) FillText(text string, x, y float64) float64 {
+	if !fontLoaded {
+		return 0
+	}
+	c.usedFont = true
+
+	var encoded strings.Builder
+	for _, r := range text {
+		if r < 32 || (r > 126 && r < 160) || r > 255 {
+			r = '?'
+		}
+		fmt.Fprintf(&encoded, "%02X", r)
+	}
+	r, g, b, _ := rgb8(c.fillColor)
+	// the text matrix flips y back, so the glyphs are upright on the flipped page
+	fmt.Fprintf(&c.content, "%s %s %s rg BT /F1 %s Tf 1 0 0 -1 %s %s Tm <%s> Tj ET\n",
+		pdfColor(r), pdfColor(g), pdfColor(b), svgNumber(c.fontSize), svgNumber(x), svgNumber(y), encoded.String())
+	return textWidth(text, c.fontSize)
+}
+
+// writePath paints the current path with a PDF painting operator and clears it
+func (c *PDFCanvas) writePath(operator string) {
+	if len(c.path) == 0 {
+		return
+	}
+
+	r, g, b, _ := rgb8(c.fillColor)
+	fmt.Fprintf(&c.content, "%s %s %s rg ", pdfColor(r), pdfColor(g), pdfColor(b))
+	r, g, b, _ = rgb8(c.strokeCol)
+	fmt.Fprintf(&c.content, "%s %s %s RG %s w\n", pdfColor(r), pdfColor(g), pdfColor(b), svgNumber(c.lineWidth))
+
+	for _, s := range c.path {
+		switch s.kind {
+		case 'M':
+			fmt.Fprintf(&c.content, "%s %s m\n", svgNumber(s.pts[0][0]), svgNumber(s.pts[0][1]))
+		case 'L':
+			fmt.Fprintf(&c.content, "%s %s l\n", svgNumber(s.pts[0][0]), svgNumber(s.pts[0][1]))
+		case 'C':
+			fmt.Fprintf(&c.content, "%s %s %s %s %s %s c\n",
+				svgNumber(s.pts[0][0]), svgNumber(s.pts[0][1]), svgNumber(s.pts[1][0]), svgNumber(s.pts[1][1]), svgNumber(s.pts[2][0]), svgNumber(s.pts[2][1]))
+		case 'Z':
+			c.content.WriteString("h\n")
+		}
+	}
+	c.content.WriteString(operator + "\n")
+	c.clearPath()
+}
+
+// Save the drawing to a PDF file
+func (c *PDFCanvas) SaveToPDF(filename string) error {
+	var objects []string
+
+	fonts := ""
+	if c.usedFont {
+		fontObjects := pdfFontObjects(4)
+		objects = append(objects, fontObjects...)
+		fonts = " /Font << /F1 4 0 R >>"
+	}
+
+	// objects 1 to 3 are the catalog, the page tree and the page; the content stream comes last
+	contentID := 4 + len(objects)
+	objects = append([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources <<%s >> /Contents %d 0 R >>", c.width, c.height, fonts, contentID),
+	}, objects...)
+	objects = append(objects, pdfStream(c.content.Bytes(), ""))
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", filename, err)
+	}
+	defer f.Close()
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	b := bufio.NewWriter(f)
+	b.Write(out.Bytes())
+	if err := b.Flush(); err != nil {
+		return fmt.Errorf("error writing %s: %v", filename, err)
+	}
+	return nil
+}
+
+// pdfFontObjects returns the font, font descriptor and font file objects of the loaded font, numbered from first
+func pdfFontObjects(first int) []string {
+	unitsPerEm := loadedFont.FUnitsPerEm()
+	scale := fixed.Int26_6(unitsPerEm)
+	toPDF := func(units fixed.Int26_6) int {
+		return int(units) * 1000 / int(unitsPerEm) // PDF glyph space has 1000 units per em
+	}
+
+	var widths strings.Builder
+	for code := 32; code <= 255; code++ {
+		w := 0
+		if code < 127 || code >= 160 {
+			w = toPDF(loadedFont.HMetric(scale, loadedFont.Index(rune(code))).AdvanceWidth)
+		}
+		fmt.Fprintf(&widths, "%d ", w)
+	}
+
+	name := strings.ReplaceAll(loadedFont.Name(truetype.NameIDPostscriptName), " ", "")
+	if name == "" {
+		name = "CanvasFont"
+	}
+	bounds := loadedFont.Bounds(scale)
+	box := fmt.Sprintf("[%d %d %d %d]", toPDF(bounds.Min.X), toPDF(bounds.Min.Y), toPDF(bounds.Max.X), toPDF(bounds.Max.Y))
+
+	return []string{
+		fmt.Sprintf("<< /Type /Font /Subtype /TrueType /BaseFont /%s /FirstChar 32 /LastChar 255 /Widths [%s] /Encoding /WinAnsiEncoding /FontDescriptor %d 0 R >>",
+			name, strings.TrimSpace(widths.String()), first+1),
+		fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox %s /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+			name, box, toPDF(bounds.Max.Y), toPDF(bounds.Min.Y), toPDF(bounds.Max.Y), first+2),
+		pdfStream(fontFile, fmt.Sprintf(" /Length1 %d", len(fontFile))),
+	}
+}
+
+// pdfStream returns a Flate-compressed stream object with optional extra dictionary entries
+func pdfStream(data []byte, entries string) string {
+	var compressed bytes.Buffer
+	z := zlib.NewWriter(&compressed)
+	z.Write(data)
+	z.Close()
+	return fmt.Sprintf("<< /Length %d /Filter /FlateDecode%s >>\nstream\n%s\nendstream", compressed.Len(), entries, compressed.String())
+}
+
+// pdfColor formats an 8 bit colour component as a PDF colour value between 0 and 1
+func pdfColor(v uint8) string {
+	return svgNumber(float64(v) / 255)
+}
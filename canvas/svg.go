@@ -0,0 +1,148 @@
+package canvas
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"image/color"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// SVGCanvas records drawing as SVG elements, one <path> per Stroke, Fill or FillStroke and one <text> per FillText.
+// If a font has been loaded, it is embedded in the file so the text looks the same in every viewer.
+type SVGCanvas struct {
+	vectorState
+	body strings.Builder
+}
+
+// Create a new SVG canvas of w x h pixels
+func CreateNewSVGCanvas(w, h int) *SVGCanvas {
+	return &SVGCanvas{vectorState: newVectorState(w, h)}
+}
+
+// Actually draw the lines you've set up with LineTo
+func (c *SVGCanvas) Stroke() {
+	c.writePath(false, true)
+}
+
+// Fill the area inside the lines you've set up with LineTo, but don't
+// draw the lines
+func (c *SVGCanvas) Fill() {
+	c.writePath(true, false)
+}
+
+// Fill the area inside the lines you've set up with LineTo
+func (c *SVGCanvas) FillStroke() {
+	c.writePath(true, true)
+}
+
+// Fill the text with the fill color, with the left end of its baseline at (x,y)
+// Returns the width of the text. Does nothing if no font has been loaded.
+func (c *SVGCanvas) FillText(text string, x, y float64) float64 {
+	if !fontLoaded {
+		return 0
+	}
+	c.usedFont = true
+
+	var escaped strings.Builder
+	for _, r := range text {
+		switch r {
+		case '<':
+			escaped.WriteString("&lt;")
+		case '>':
+			escaped.WriteString("&gt;")
+		case '&':
+			escaped.WriteString("&amp;")
+		default:
+			escaped.WriteRune(r)
+		}
+	}
+	fmt.Fprintf(&c.body, "<text x=\"%s\" y=\"%s\" font-size=\"%s\"%s>%s</text>\n",
+		svgNumber(x), svgNumber(y), svgNumber(c.fontSize), svgPaint("fill", c.fillColor), escaped.String())
+	return textWidth(text, c.fontSize)
+}
+
+// writePath writes the current path as a <path> element and clears it
+func (c *SVGCanvas) writePath(fill, stroke bool) {
+	if len(c.path) == 0 {
+		return
+	}
+
+	var d strings.Builder
+	for _, s := range c.path {
+		d.WriteByte(s.kind)
+		points := 0
+		switch s.kind {
+		case 'M', 'L':
+			points = 1
+		case 'C':
+			points = 3
+		}
+		for i := 0; i < points; i++ {
+			if i > 0 {
+				d.WriteByte(' ')
+			}
+			d.WriteString(svgNumber(s.pts[i][0]))
+			d.WriteByte(',')
+			d.WriteString(svgNumber(s.pts[i][1]))
+		}
+	}
+
+	attributes := ""
+	if fill {
+		attributes += svgPaint("fill", c.fillColor) + ` fill-rule="evenodd"`
+	} else {
+		attributes += ` fill="none"`
+	}
+	if stroke {
+		attributes += svgPaint("stroke", c.strokeCol) + ` stroke-width="` + svgNumber(c.lineWidth) + `"`
+	}
+	fmt.Fprintf(&c.body, "<path d=\"%s\"%s/>\n", d.String(), attributes)
+	c.clearPath()
+}
+
+// Save the drawing to an SVG file
+func (c *SVGCanvas) SaveToSVG(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", filename, err)
+	}
+	defer f.Close()
+
+	b := bufio.NewWriter(f)
+	fmt.Fprintf(b, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", c.width, c.height, c.width, c.height)
+	if c.usedFont {
+		fmt.Fprintf(b, "<style>@font-face{font-family:\"canvas\";src:url(data:font/ttf;base64,%s)} text{font-family:\"canvas\",sans-serif}</style>\n",
+			base64.StdEncoding.EncodeToString(fontFile))
+	}
+	b.WriteString(c.body.String())
+	b.WriteString("</svg>\n")
+
+	if err := b.Flush(); err != nil {
+		return fmt.Errorf("error writing %s: %v", filename, err)
+	}
+	return nil
+}
+
+// svgPaint returns a fill or stroke attribute for a colour, with an opacity attribute if it is translucent
+func svgPaint(attribute string, col color.Color) string {
+	r, g, b, a := rgb8(col)
+	paint := fmt.Sprintf(` %s="#%02x%02x%02x"`, attribute, r, g, b)
+	if a < 255 {
+		paint += fmt.Sprintf(` %s-opacity="%s"`, attribute, svgNumber(float64(a)/255))
+	}
+	return paint
+}
+
+// svgNumber formats a coordinate with at most two decimals
+func svgNumber(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" || s == "" {
+		return "0"
+	}
+	return s
+}
@@ -0,0 +1,182 @@
+package canvas
+
+import (
+	"image/color"
+	"math"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// segment is one piece of a vector path: a move, a straight line, a cubic Bézier curve or a close.
+// Lines and moves use the first point; curves use all three (two control points and the end point).
+type segment struct {
+	kind byte // 'M', 'L', 'C' or 'Z'
+	pts  [3][2]float64
+}
+
+// vectorState is the drawing state shared by the vector backends: the current path, colours, line width and font size.
+type vectorState struct {
+	width     int
+	height    int
+	path      []segment
+	current   bool // whether the path has a current point
+	fillColor color.Color
+	strokeCol color.Color
+	lineWidth float64
+	fontSize  float64
+	usedFont  bool // whether any text was drawn, so the font has to be included in the file
+}
+
+func newVectorState(w, h int) vectorState {
+	return vectorState{width: w, height: h, fillColor: color.Black, strokeCol: color.Black, lineWidth: 1, fontSize: 10}
+}
+
+// Move the current point to (x,y)
+func (v *vectorState) MoveTo(x, y float64) {
+	v.path = append(v.path, segment{kind: 'M', pts: [3][2]float64{{x, y}}})
+	v.current = true
+}
+
+// Draw a line from the current point to (x,y), and set the current point to (x,y)
+func (v *vectorState) LineTo(x, y float64) {
+	if !v.current {
+		v.MoveTo(x, y)
+		return
+	}
+	v.path = append(v.path, segment{kind: 'L', pts: [3][2]float64{{x, y}}})
+}
+
+// Draw an arc of the ellipse centred on (x,y), starting at startAngle and sweeping angle (both in radians)
+// The arc is joined to the current point with a straight line, as with draw2d.
+func (v *vectorState) ArcTo(x, y, radiusX, radiusY, startAngle, angle float64) {
+	sx, sy := x+radiusX*math.Cos(startAngle), y+radiusY*math.Sin(startAngle)
+	if v.current {
+		v.LineTo(sx, sy)
+	} else {
+		v.MoveTo(sx, sy)
+	}
+
+	// approximate the arc with cubic Béziers of at most a quarter turn each
+	pieces := int(math.Ceil(math.Abs(angle) / (math.Pi / 2)))
+	if pieces == 0 {
+		return
+	}
+	step := angle / float64(pieces)
+	k := 4.0 / 3.0 * math.Tan(step/4)
+	a := startAngle
+	for i := 0; i < pieces; i++ {
+		b := a + step
+		cosA, sinA := math.Cos(a), math.Sin(a)
+		cosB, sinB := math.Cos(b), math.Sin(b)
+		v.path = append(v.path, segment{kind: 'C', pts: [3][2]float64{
+			{x + radiusX*(cosA-k*sinA), y + radiusY*(sinA+k*cosA)},
+			{x + radiusX*(cosB+k*sinB), y + radiusY*(sinB-k*cosB)},
+			{x + radiusX*cosB, y + radiusY*sinB},
+		}})
+		a = b
+	}
+}
+
+// Close the current path by drawing a line back to its first point
+func (v *vectorState) ClosePath() {
+	if !v.current {
+		return
+	}
+	v.path = append(v.path, segment{kind: 'Z'})
+	v.current = false
+}
+
+// Add a circle to the current path
+func (v *vectorState) Circle(cx, cy, r float64) {
+	v.Ellipse(cx, cy, r, r)
+}
+
+// Add an ellipse to the current path
+func (v *vectorState) Ellipse(cx, cy, rx, ry float64) {
+	v.current = false
+	v.ArcTo(cx, cy, rx, ry, 0, -math.Pi*2)
+	v.ClosePath()
+}
+
+// Add a rectangle with top left corner (x,y) to the current path
+func (v *vectorState) Rect(x, y, w, h float64) {
+	v.MoveTo(x, y)
+	v.LineTo(x+w, y)
+	v.LineTo(x+w, y+h)
+	v.LineTo(x, y+h)
+	v.ClosePath()
+}
+
+// Set the line color
+func (v *vectorState) SetStrokeColor(col color.Color) {
+	v.strokeCol = col
+}
+
+// Set the fill color
+func (v *vectorState) SetFillColor(col color.Color) {
+	v.fillColor = col
+}
+
+// Set the line width
+func (v *vectorState) SetLineWidth(w float64) {
+	v.lineWidth = w
+}
+
+// Set the font size in pixels
+func (v *vectorState) SetFontSize(size float64) {
+	v.fontSize = size
+}
+
+// Return the width and height of the text in the current font size
+func (v *vectorState) TextSize(text string) (float64, float64) {
+	if !fontLoaded {
+		return 0, 0
+	}
+	return textWidth(text, v.fontSize), v.fontSize * fontHeight()
+}
+
+// Return the width of the canvas
+func (v *vectorState) Width() int {
+	return v.width
+}
+
+// Return the height of the canvas
+func (v *vectorState) Height() int {
+	return v.height
+}
+
+// clearPath empties the current path after it has been painted
+func (v *vectorState) clearPath() {
+	v.path = v.path[:0]
+	v.current = false
+}
+
+// textWidth measures text in the loaded font, including kerning
+func textWidth(text string, size float64) float64 {
+	unitsPerEm := loadedFont.FUnitsPerEm()
+	scale := fixed.Int26_6(unitsPerEm) // scaling by units per em leaves the metrics in font units
+	units := 0
+	prev, hasPrev := loadedFont.Index(0), false
+	for _, r := range text {
+		i := loadedFont.Index(r)
+		if hasPrev {
+			units += int(loadedFont.Kern(scale, prev, i))
+		}
+		units += int(loadedFont.HMetric(scale, i).AdvanceWidth)
+		prev, hasPrev = i, true
+	}
+	return float64(units) * size / float64(unitsPerEm)
+}
+
+// fontHeight returns the height of the loaded font's bounding box as a multiple of the font size
+func fontHeight() float64 {
+	unitsPerEm := loadedFont.FUnitsPerEm()
+	b := loadedFont.Bounds(fixed.Int26_6(unitsPerEm))
+	return float64(b.Max.Y-b.Min.Y) / float64(unitsPerEm)
+}
+
+// rgb8 returns the 8 bit red, green, blue and alpha components of a colour, without alpha premultiplication
+func rgb8(col color.Color) (uint8, uint8, uint8, uint8) {
+	n := color.NRGBAModel.Convert(col).(color.NRGBA)
+	return n.R, n.G, n.B, n.A
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// stageNames are the display names of the fly stages, indexed by Fly.stage. Index 6 is used for dead flies.
+var stageNames = []string{"egg", "instar 1", "instar 2", "instar 3", "instar 4", "adult", "dead"}
+
+// Census counts the flies of a country on one date.
+type Census struct {
+	date        time.Time
+	stages      [7]int // living flies per stage, dead flies are counted at index 6
+	alive       int
+	eggsPending int            // eggs laid this season that have not hatched yet
+	emigrated   int            // flies that have left the study region since the start of the run
+	deaths      [numCauses]int // flies and pending eggs that have died since the start of the run, by cause
+}
+
+// TakeCensus counts the flies of the country by stage.
+func TakeCensus(country Country) Census {
+	census := Census{
+		date:        country.date,
+		eggsPending: len(country.eggs),
+		emigrated:   country.emigrated,
+		deaths:      country.deaths,
+	}
+
+	for _, fly := range country.flies {
+		if !fly.isAlive || fly.stage < 0 || fly.stage > 5 {
+			census.stages[6]++
+			continue
+		}
+		census.stages[fly.stage]++
+		census.alive++
+	}
+
+	return census
+}
+
+// PrintDeaths prints the number of flies that have died of each cause, with their share of all deaths.
+func PrintDeaths(census Census) {
+	total := 0
+	for _, n := range census.deaths {
+		total += n
+	}
+	for cause, n := range census.deaths {
+		if n == 0 {
+			continue
+		}
+		fmt.Printf("  %-14s %8d %6.1f%%\n", DeathCause(cause), n, 100*float64(n)/float64(total))
+	}
+	if total == 0 {
+		fmt.Println("  none")
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Timestep is the number of calendar days covered by a single tick of the simulation clock.
+type Timestep int
+
+const (
+	Daily  Timestep = 1
+	Weekly Timestep = 7
+)
+
+// dateLayout is the layout used for dates given on the command line and printed in reports.
+const dateLayout = "2006-01-02"
+
+// EventHook is called when a phenology event fires.
+// It receives the country at the end of the tick in which the event fell, and the calendar date of the event.
+type EventHook func(country *Country, date time.Time)
+
+// PhenologyEvent is a named event that fires once a year on a fixed month and day, e.g. hatch on May 1.
+// An event on February 29 only fires in leap years.
+type PhenologyEvent struct {
+	name  string
+	month time.Month
+	day   int
+	hook  EventHook
+}
+
+// FiredEvent is a phenology event together with the calendar date on which it fired.
+type FiredEvent struct {
+	event PhenologyEvent
+	date  time.Time
+}
+
+// Clock keeps track of the calendar date of the simulation.
+// It starts at a configurable date, moves forward one Timestep per tick, and reports the phenology events that fall within each tick.
+// All dates are kept in UTC at midnight so that day arithmetic is never affected by daylight saving time.
+type Clock struct {
+	start   time.Time
+	current time.Time
+	step    Timestep
+	events  []PhenologyEvent
+}
+
+// NewClock creates a clock that starts on the given date and advances by step.
+func NewClock(start time.Time, step Timestep) *Clock {
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	return &Clock{
+		start:   start,
+		current: start,
+		step:    step,
+	}
+}
+
+// ParseDate parses a date written as YYYY-MM-DD.
+func ParseDate(s string) (time.Time, error) {
+	date, err := time.Parse(dateLayout, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error parsing date %q (want YYYY-MM-DD): %v", s, err)
+	}
+	return date, nil
+}
+
+// ParseTimestep converts the name of a timestep ("daily" or "weekly") into a Timestep.
+func ParseTimestep(s string) (Timestep, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "daily", "day", "1":
+		return Daily, nil
+	case "weekly", "week", "7":
+		return Weekly, nil
+	default:
+		return 0, fmt.Errorf("unknown timestep %q (want daily or weekly)", s)
+	}
+}
+
+// On registers a hook that fires every year on the given month and day.
+func (c *Clock) On(name string, month time.Month, day int, hook EventHook) {
+	c.events = append(c.events, PhenologyEvent{name: name, month: month, day: day, hook: hook})
+}
+
+// Date returns the current calendar date of the clock.
+func (c *Clock) Date() time.Time {
+	return c.current
+}
+
+// Start returns the calendar date on which the clock started.
+func (c *Clock) Start() time.Time {
+	return c.start
+}
+
+// StepDays returns the number of days covered by one tick.
+func (c *Clock) StepDays() int {
+	return int(c.step)
+}
+
+// DaysElapsed returns the number of days between the start date and the current date.
+func (c *Clock) DaysElapsed() int {
+	return int(c.current.Sub(c.start).Hours() / 24)
+}
+
+// Advance moves the clock forward by one timestep.
+// It walks through every day in the tick, so that no event is skipped when the timestep is longer than a day,
+// and returns the events whose date fell in (previous date, new date], in calendar order.
+func (c *Clock) Advance() []FiredEvent {
+	var fired []FiredEvent
+	for d := 0; d < c.StepDays(); d++ {
+		c.current = c.current.AddDate(0, 0, 1)
+		for _, e := range c.events {
+			if c.current.Month() == e.month && c.current.Day() == e.day {
+				fired = append(fired, FiredEvent{event: e, date: c.current})
+			}
+		}
+	}
+	return fired
+}
+
+// Season returns the meteorological season of a date in the northern hemisphere.
+func Season(date time.Time) string {
+	switch date.Month() {
+	case time.December, time.January, time.February:
+		return "winter"
+	case time.March, time.April, time.May:
+		return "spring"
+	case time.June, time.July, time.August:
+		return "summer"
+	default:
+		return "autumn"
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+type ParseDateTest struct {
+	input  string
+	result time.Time
+	fails  bool
+}
+
+type ParseTimestepTest struct {
+	input  string
+	result Timestep
+	fails  bool
+}
+
+type AdvanceTest struct {
+	start  time.Time
+	step   Timestep
+	ticks  int
+	date   time.Time
+	fired  []string // names of the events fired over all ticks, in order
+	events []PhenologyEvent
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []ParseDateTest{
+		{input: "2021-05-01", result: date(2021, time.May, 1)},
+		{input: " 2020-02-29 ", result: date(2020, time.February, 29)},
+		{input: "2021-02-29", fails: true},
+		{input: "05/01/2021", fails: true},
+		{input: "", fails: true},
+	}
+
+	for _, test := range tests {
+		result, err := ParseDate(test.input)
+		if (err != nil) != test.fails {
+			t.Errorf("ParseDate(%q) error = %v, want error %v", test.input, err, test.fails)
+			continue
+		}
+		if !test.fails && !result.Equal(test.result) {
+			t.Errorf("ParseDate(%q) = %v, want %v", test.input, result, test.result)
+		}
+	}
+}
+
+func TestParseTimestep(t *testing.T) {
+	tests := []ParseTimestepTest{
+		{input: "daily", result: Daily},
+		{input: "Weekly", result: Weekly},
+		{input: " 7 ", result: Weekly},
+		{input: "day", result: Daily},
+		{input: "monthly", fails: true},
+	}
+
+	for _, test := range tests {
+		result, err := ParseTimestep(test.input)
+		if (err != nil) != test.fails {
+			t.Errorf("ParseTimestep(%q) error = %v, want error %v", test.input, err, test.fails)
+			continue
+		}
+		if result != test.result {
+			t.Errorf("ParseTimestep(%q) = %v, want %v", test.input, result, test.result)
+		}
+	}
+}
+
+func TestAdvance(t *testing.T) {
+	hatch := PhenologyEvent{name: "hatch", month: time.May, day: 1}
+	leap := PhenologyEvent{name: "leap", month: time.February, day: 29}
+	newYear := PhenologyEvent{name: "new year", month: time.January, day: 1}
+
+	tests := []AdvanceTest{
+		// a daily clock fires the event on its day
+		{start: date(2021, time.April, 29), step: Daily, ticks: 3, date: date(2021, time.May, 2), fired: []string{"hatch"}, events: []PhenologyEvent{hatch}},
+		// a weekly clock does not skip an event in the middle of a tick
+		{start: date(2021, time.April, 28), step: Weekly, ticks: 1, date: date(2021, time.May, 5), fired: []string{"hatch"}, events: []PhenologyEvent{hatch}},
+		// the start date itself is not in the first tick
+		{start: date(2021, time.May, 1), step: Weekly, ticks: 1, date: date(2021, time.May, 8), events: []PhenologyEvent{hatch}},
+		// February 29 only fires in leap years
+		{start: date(2019, time.February, 20), step: Weekly, ticks: 54, date: date(2020, time.March, 4), fired: []string{"new year", "leap"}, events: []PhenologyEvent{leap, newYear}},
+		// events of a year fire once a year, in calendar order
+		{start: date(2020, time.December, 30), step: Weekly, ticks: 69, date: date(2022, time.April, 27), fired: []string{"new year", "hatch", "new year"}, events: []PhenologyEvent{hatch, newYear}},
+	}
+
+	for _, test := range tests {
+		clock := NewClock(test.start, test.step)
+		for _, e := range test.events {
+			clock.On(e.name, e.month, e.day, nil)
+		}
+		var fired []string
+		for i := 0; i < test.ticks; i++ {
+			for _, f := range clock.Advance() {
+				if f.date.Month() != f.event.month || f.date.Day() != f.event.day {
+					t.Errorf("event %s fired on %v", f.event.name, f.date)
+				}
+				fired = append(fired, f.event.name)
+			}
+		}
+		if !clock.Date().Equal(test.date) {
+			t.Errorf("clock from %v after %d ticks of %d days is on %v, want %v", test.start, test.ticks, test.step, clock.Date(), test.date)
+		}
+		if clock.DaysElapsed() != test.ticks*int(test.step) {
+			t.Errorf("DaysElapsed() = %d, want %d", clock.DaysElapsed(), test.ticks*int(test.step))
+		}
+		if len(fired) != len(test.fired) {
+			t.Errorf("clock from %v fired %v, want %v", test.start, fired, test.fired)
+			continue
+		}
+		for i := range fired {
+			if fired[i] != test.fired[i] {
+				t.Errorf("clock from %v fired %v, want %v", test.start, fired, test.fired)
+				break
+			}
+		}
+	}
+}
+
+func TestNewClockDropsTimeOfDay(t *testing.T) {
+	start := time.Date(2021, time.March, 14, 2, 30, 0, 0, time.FixedZone("EST", -5*3600))
+	clock := NewClock(start, Daily)
+	if !clock.Start().Equal(date(2021, time.March, 14)) {
+		t.Errorf("NewClock(%v).Start() = %v, want 2021-03-14 UTC", start, clock.Start())
+	}
+}
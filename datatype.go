@@ -1,23 +1,51 @@
 package main
 
+import "time"
+
 type Country struct {
 	width  float64
 	height float64
 	flies  []Fly
 	trees  []Tree
+	date   time.Time // calendar date of this snapshot
+	eggs   []Fly     // eggs laid this season, waiting to hatch
+	hosts  HostLayer // where the hosts are, shared by all snapshots; nil for the trees as points
+
+	boundary     BoundaryPolicy // what happens to flies leaving the study region, shared by all snapshots
+	movement     StageMovement  // how each stage moves, shared by all snapshots; the zero value for the defaults (see Country.Movement)
+	reproduction Reproduction   // which adults lay eggs and how many, shared by all snapshots; the zero value for DefaultReproduction
+	mortality    Mortality      // what kills flies, shared by all snapshots; the zero value for DefaultMortality
+	emigrated    int            // flies that have left the study region since the start of the run
+	deaths       [numCauses]int // flies and pending eggs that have died since the start of the run, by cause
+	moves        [6][]float64   // km each living fly moved on each day of the tick that ended in this snapshot, by stage (see StepSimulation)
 }
 
 type Tree struct {
-	position OrderedPair
+	position  OrderedPair
+	species   string    // scientific or common name as written in the host file, "" if not recorded
+	abundance float64   // trees, stems or other count the record stands for; 1 if not recorded
+	date      time.Time // observation or inventory date, zero if not recorded
+	source    string    // dataset the record came from
 }
 
 type Fly struct {
 	position   OrderedPair
 	stage      int     // 0 = egg, 1 = instar1, 2 = instar2, 3 = instar3, 4 = instar4, 5 = adult, 6= dead
-	energy     float64 // Degree-days
+	energy     float64 // Degree-days accumulated since the egg was laid
 	isAlive    bool
 	locationID int
-	color      Color // color to show on scatter plot (red, orange, yellow, green, blue, purple, black) neon colors
+	eggMasses  int        // egg masses a female has laid this season
+	adultDays  int        // days since the fly became an adult
+	female     bool       // only females lay eggs
+	mated      bool       // true once an adult female has found a mate (see MateFlies)
+	age        int        // days since the egg was laid
+	emigrated  bool       // true once the fly has left the study region; it is then no longer alive
+	heading    float64    // direction of the fly's last move, radians counterclockwise from east
+	moved      float64    // distance the fly moved on its last day, km
+	movedStage int        // stage the fly was in when it made that move
+	cause      DeathCause // what killed the fly, CauseNone while it lives
+	died       time.Time  // day the fly died, zero while it lives
+	color      Color      // color to show on scatter plot (red, orange, yellow, green, blue, purple, black) neon colors
 
 }
 
@@ -25,6 +53,11 @@ type Weather struct {
 	x         float64 // Bottom left corner x coordinate (Longitude)
 	y         float64 // Bottom left corner y coordinate (Latitude)
 	Quadrants []Quadrant
+	provider  TemperatureProvider    // daily temperatures, e.g. gridded data; the quadrants are used where it has none
+	date      time.Time              // day the temperatures are taken for (see Weather.On)
+	scenario  *Scenario              // climate scenario applied to every temperature, nil for the baseline
+	wind      WindProvider           // daily wind drifting flying adults, nil for none
+	seasons   []SeasonalTemperatures // station temperatures of particular seasons, used instead of the quadrants' on their days
 }
 
 type Color struct {
@@ -45,12 +78,13 @@ type OrderedPair struct {
 
 // Quadrant is an object representing a sub-square within a larger universe.
 type Quadrant struct {
-	x      float64 //bottom left corner x coordinate
-	y      float64 //bottom right corner y coordinate
-	width  float64
-	height float64
-	id     int
-	temp   float64
+	x       float64 //bottom left corner x coordinate
+	y       float64 //bottom right corner y coordinate
+	width   float64
+	height  float64
+	id      int
+	temp    float64 // maximum temperature, °C
+	minTemp float64 // minimum temperature, °C
 }
 
 // SampleData represents the structure of the data in the file
@@ -69,6 +103,7 @@ type SampleData struct {
 	PointID          string
 	RoundedLongitude float64
 	RoundedLatitude  float64
+	establishedKnown bool // false when the row does not record LydeEstablished
 }
 
 const (
@@ -87,20 +122,9 @@ const (
 
 	earthRadius float64 = 6371 // km
 
+	// bounding box of the default study region (see ContiguousUSRegion); -region sets another
 	minLat = 31.33   // Southernmost point in the US
 	maxLat = 45.71   // Northernmost point in the contiguous US
 	minLon = -123.27 // Westernmost point in the contiguous US
 	maxLon = -68.93
-
-	longtitude1 float64 = -123.27
-	longtitude2 float64 = -112.402
-	longtitude3 float64 = -101.534
-	longtitude4 float64 = -90.666
-	longtitude5 float64 = -79.798
-	longitude6  float64 = -68.93
-	latitude1   float64 = 31.33
-	latitude2   float64 = 34.206
-	latitude3   float64 = 37.082
-	latitude4   float64 = 39.958
-	latitude5   float64 = 42.834
 )
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// detectionColumns lists the columns of the SLF detection dataset (the lyde data of the lydemapr package) with the names
+// each may have in the header, compared ignoring case, spaces and underscores.
+var detectionColumns = []tableColumn{
+	{"source", nil, false},
+	{"year", nil, false},
+	{"bio_year", []string{"bioyear"}, true},
+	{"latitude", []string{"lat"}, true},
+	{"longitude", []string{"lon", "long"}, true},
+	{"state", nil, false},
+	{"lyde_present", []string{"present"}, true},
+	{"lyde_established", []string{"established"}, false},
+	{"lyde_density", []string{"density"}, false},
+	{"source_agency", nil, false},
+	{"collection_method", nil, false},
+	{"pointid", []string{"point_id"}, false},
+	{"rounded_longitude", nil, false},
+	{"rounded_latitude", nil, false},
+}
+
+// DetectionFilter selects the records of the detection dataset that are kept. Zero values keep everything.
+type DetectionFilter struct {
+	minYear, maxYear int      // range of bio years, 0 for no limit
+	states           []string // states as written in the state column, e.g. "PA"
+	established      string   // "yes" or "no" to keep only established or unestablished populations, "" for both
+	densities        []string // density classes, e.g. "Low", "High"
+	region           *Region  // study region, nil for anywhere
+}
+
+// ParseDetectionFilter builds a filter from command line values: a bio year or range of bio years ("2021", "2019-2021" or ""),
+// comma-separated lists of states and density classes, and an establishment status (any, yes or no).
+func ParseDetectionFilter(years, states, established, densities string) (DetectionFilter, error) {
+	var filter DetectionFilter
+
+	if years = strings.TrimSpace(years); years != "" {
+		first, last, isRange := strings.Cut(years, "-")
+		var err1, err2 error
+		filter.minYear, err1 = strconv.Atoi(strings.TrimSpace(first))
+		filter.maxYear, err2 = filter.minYear, nil
+		if isRange {
+			filter.maxYear, err2 = strconv.Atoi(strings.TrimSpace(last))
+		}
+		if err1 != nil || err2 != nil || filter.maxYear < filter.minYear {
+			return DetectionFilter{}, fmt.Errorf("invalid years %q, expected YYYY or YYYY-YYYY", years)
+		}
+	}
+
+	switch strings.ToLower(strings.TrimSpace(established)) {
+	case "", "any":
+	case "yes", "true":
+		filter.established = "yes"
+	case "no", "false":
+		filter.established = "no"
+	default:
+		return DetectionFilter{}, fmt.Errorf("invalid establishment status %q (want any, yes or no)", established)
+	}
+
+	filter.states = splitList(states)
+	filter.densities = splitList(densities)
+	return filter, nil
+}
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// RowError is a record of the detection dataset that could not be read.
+type RowError struct {
+	line   int
+	column string
+	value  string
+	reason string
+}
+
+// Error describes the row error with its line number.
+func (e RowError) Error() string {
+	if e.column == "" {
+		return fmt.Sprintf("line %d: %s", e.line, e.reason)
+	}
+	return fmt.Sprintf("line %d: %s %q: %s", e.line, e.column, e.value, e.reason)
+}
+
+// DetectionReport summarises the reading of a detection file.
+type DetectionReport struct {
+	file      string
+	rows      int            // data rows in the file
+	kept      int            // rows returned, presences and absences
+	absences  int            // rows returned that recorded no lanternflies
+	dropped   map[string]int // rows left out by each filter, and rows without coordinates or presence
+	errors    []RowError     // rows that could not be read
+	byState   map[string]int // rows returned per state
+	firstYear int            // smallest bio year returned
+	lastYear  int            // largest bio year returned
+}
+
+// String formats the report for printing. Only the first few row errors are listed.
+func (r DetectionReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d rows, %d kept (%d presences, %d absences)", r.file, r.rows, r.kept, r.kept-r.absences, r.absences)
+	if r.kept > 0 {
+		fmt.Fprintf(&b, ", bio years %d to %d", r.firstYear, r.lastYear)
+	}
+	b.WriteString("\n")
+
+	reasons := make([]string, 0, len(r.dropped))
+	for reason := range r.dropped {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(&b, "  %d dropped: %s\n", r.dropped[reason], reason)
+	}
+
+	states := make([]string, 0, len(r.byState))
+	for state := range r.byState {
+		states = append(states, state)
+	}
+	sort.Strings(states)
+	if len(states) > 0 {
+		b.WriteString("  kept per state:")
+		for _, state := range states {
+			fmt.Fprintf(&b, " %s %d", state, r.byState[state])
+		}
+		b.WriteString("\n")
+	}
+
+	if len(r.errors) > 0 {
+		fmt.Fprintf(&b, "  %d rows could not be read:\n", len(r.errors))
+		for i, e := range r.errors {
+			if i == 10 {
+				fmt.Fprintf(&b, "  ... and %d more\n", len(r.errors)-10)
+				break
+			}
+			fmt.Fprintf(&b, "  %v\n", e)
+		}
+	}
+	return b.String()
+}
+
+// ReadSampleDataFromFile reads the SLF detection dataset from a CSV or tab-separated file, finding the columns by their header
+// (see detectionColumns); the separator is taken from the header line. The header must have the bio year, the coordinates
+// and the presence column. "NA" and empty fields are missing values. Rows without coordinates or presence are dropped;
+// rows that cannot be parsed are listed in the report instead of stopping the read.
+// Every other survey is returned, whether it found lanternflies or not, so the file is read once for both seeding and
+// evaluating a run; DetectionFilter.Select picks the surveys each needs.
+// The error is only set if the file cannot be opened or its header does not match the schema.
+func ReadSampleDataFromFile(filename string) ([]SampleData, DetectionReport, error) {
+	report := DetectionReport{file: filename, dropped: make(map[string]int), byState: make(map[string]int)}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, report, fmt.Errorf("error opening detection file: %v", err)
+	}
+	defer file.Close()
+
+	reader := newTableReader(file)
+	header, err := readHeader(reader, detectionColumns)
+	if err != nil {
+		return nil, report, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	var surveys []SampleData
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			parseErr, ok := err.(*csv.ParseError)
+			if !ok {
+				return nil, report, fmt.Errorf("%s: %v", filename, err)
+			}
+			report.rows++
+			report.errors = append(report.errors, RowError{line: parseErr.StartLine, reason: parseErr.Err.Error()})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		if isEmptyRecord(record) {
+			continue
+		}
+		report.rows++
+
+		data, dropReason, rowErr := parseDetection(record, header)
+		if rowErr != nil {
+			rowErr.line = line
+			report.errors = append(report.errors, *rowErr)
+			continue
+		}
+		if dropReason != "" {
+			report.dropped[dropReason]++
+			continue
+		}
+		surveys = append(surveys, data)
+	}
+
+	return surveys, report, nil
+}
+
+// Select returns the surveys the filter keeps: those that found lanternflies as presences, and those that found none as absences.
+// If report is not nil, the kept rows and the rows left out by each filter are counted in it.
+func (f DetectionFilter) Select(surveys []SampleData, report *DetectionReport) ([]SampleData, []SampleData) {
+	var presences, absences []SampleData
+	for _, data := range surveys {
+		dropReason := f.reject(data)
+		if dropReason != "" {
+			if report != nil {
+				report.dropped[dropReason]++
+			}
+			continue
+		}
+
+		if data.LydePresent {
+			presences = append(presences, data)
+		} else {
+			absences = append(absences, data)
+		}
+		if report == nil {
+			continue
+		}
+		if !data.LydePresent {
+			report.absences++
+		}
+		report.kept++
+		report.byState[data.State]++
+		if report.kept == 1 || data.BioYear < report.firstYear {
+			report.firstYear = data.BioYear
+		}
+		if report.kept == 1 || data.BioYear > report.lastYear {
+			report.lastYear = data.BioYear
+		}
+	}
+	return presences, absences
+}
+
+// tableColumn is a column of an input table, with the other names it may have in the header.
+type tableColumn struct {
+	name     string
+	aliases  []string
+	required bool
+}
+
+// newTableReader returns a lenient CSV reader of a table whose separator is whichever of tab and comma its header line has more of.
+func newTableReader(file io.Reader) *csv.Reader {
+	buffered := bufio.NewReader(file)
+	start, _ := buffered.Peek(4096)
+	firstLine, _, _ := strings.Cut(string(start), "\n")
+
+	reader := csv.NewReader(buffered)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	if strings.Count(firstLine, "\t") > strings.Count(firstLine, ",") {
+		reader.Comma = '\t'
+	}
+	return reader
+}
+
+// readHeader reads the header line and maps each known column to its index. Names are compared ignoring case, spaces,
+// underscores and dots, and the first of a column's names found in the header is used.
+func readHeader(reader *csv.Reader, columns []tableColumn) (map[string]int, error) {
+	fields, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading header: %v", err)
+	}
+	normalize := func(name string) string {
+		name = strings.ToLower(strings.Trim(strings.TrimSpace(name), "\ufeff\""))
+		return strings.NewReplacer("_", "", " ", "", ".", "").Replace(name)
+	}
+	positions := make(map[string]int)
+	for i, field := range fields {
+		positions[normalize(field)] = i
+	}
+
+	header := make(map[string]int)
+	var missing []string
+	for _, column := range columns {
+		for _, name := range append([]string{column.name}, column.aliases...) {
+			if i, ok := positions[normalize(name)]; ok {
+				header[column.name] = i
+				break
+			}
+		}
+		if _, ok := header[column.name]; !ok && column.required {
+			missing = append(missing, column.name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("the header lacks the required columns %s (found %s)", strings.Join(missing, ", "), strings.Join(fields, ", "))
+	}
+	return header, nil
+}
+
+// parseDetection reads one record. It returns the row, the reason for dropping a row that lacks coordinates or presence,
+// and an error for a value that cannot be parsed.
+func parseDetection(record []string, header map[string]int) (SampleData, string, *RowError) {
+	field := func(column string) string {
+		i, ok := header[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		value := strings.TrimSpace(record[i])
+		if strings.EqualFold(value, "NA") {
+			return ""
+		}
+		return value
+	}
+	rowError := func(column, reason string) *RowError {
+		return &RowError{column: column, value: field(column), reason: reason}
+	}
+
+	data := SampleData{
+		Source:           field("source"),
+		State:            field("state"),
+		LydeDensity:      field("lyde_density"),
+		SourceAgency:     field("source_agency"),
+		CollectionMethod: field("collection_method"),
+		PointID:          field("pointid"),
+	}
+
+	if field("latitude") == "" || field("longitude") == "" {
+		return data, "no coordinates", nil
+	}
+	if field("lyde_present") == "" {
+		return data, "presence not recorded", nil
+	}
+
+	var err error
+	if data.BioYear, err = parseInteger(field("bio_year")); err != nil {
+		return data, "", rowError("bio_year", "not a year")
+	}
+	data.Year = data.BioYear
+	if field("year") != "" {
+		if data.Year, err = parseInteger(field("year")); err != nil {
+			return data, "", rowError("year", "not a year")
+		}
+	}
+
+	if data.Latitude, err = parseFloat(field("latitude")); err != nil || data.Latitude < -90 || data.Latitude > 90 {
+		return data, "", rowError("latitude", "not a latitude")
+	}
+	if data.Longitude, err = parseFloat(field("longitude")); err != nil || data.Longitude < -180 || data.Longitude > 180 {
+		return data, "", rowError("longitude", "not a longitude")
+	}
+	data.RoundedLatitude, data.RoundedLongitude = data.Latitude, data.Longitude
+	if field("rounded_latitude") != "" && field("rounded_longitude") != "" {
+		if data.RoundedLatitude, err = parseFloat(field("rounded_latitude")); err != nil || math.Abs(data.RoundedLatitude) > 90 {
+			return data, "", rowError("rounded_latitude", "not a latitude")
+		}
+		if data.RoundedLongitude, err = parseFloat(field("rounded_longitude")); err != nil || math.Abs(data.RoundedLongitude) > 180 {
+			return data, "", rowError("rounded_longitude", "not a longitude")
+		}
+	}
+
+	if data.LydePresent, err = parseDetectionBool(field("lyde_present")); err != nil {
+		return data, "", rowError("lyde_present", "not TRUE or FALSE")
+	}
+	data.establishedKnown = field("lyde_established") != ""
+	if data.establishedKnown {
+		if data.LydeEstablished, err = parseDetectionBool(field("lyde_established")); err != nil {
+			return data, "", rowError("lyde_established", "not TRUE or FALSE")
+		}
+	}
+
+	return data, "", nil
+}
+
+// parseDetectionBool parses the logical columns of the dataset, which are written TRUE/FALSE, but also accepts yes/no.
+func parseDetectionBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "yes", "y":
+		return true, nil
+	case "no", "n":
+		return false, nil
+	}
+	return parseBool(s)
+}
+
+// reject returns the reason the filter drops a row, or "" if the row is kept.
+// A row whose establishment is not recorded is dropped when the filter asks for an establishment status.
+func (f DetectionFilter) reject(data SampleData) string {
+	switch {
+	case f.minYear != 0 && (data.BioYear < f.minYear || data.BioYear > f.maxYear):
+		return "outside the bio years"
+	case len(f.states) > 0 && !containsFold(f.states, data.State):
+		return "outside the states"
+	case f.established != "" && !data.establishedKnown:
+		return "establishment not recorded"
+	case f.established == "yes" && !data.LydeEstablished, f.established == "no" && data.LydeEstablished:
+		return "establishment status"
+	case len(f.densities) > 0 && !containsFold(f.densities, data.LydeDensity):
+		return "density class"
+	case f.region != nil && !f.region.Contains(OrderedPair{x: data.Longitude, y: data.Latitude}):
+		return "outside the study region"
+	}
+	return ""
+}
+
+// containsFold reports whether the list contains s, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
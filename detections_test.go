@@ -0,0 +1,203 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type ReadSampleDataTest struct {
+	name    string
+	input   string
+	surveys []SampleData // only the fields checked by the test are set
+	dropped map[string]int
+	errors  []string // columns of the row errors, "" for a row the CSV reader rejected
+	fails   bool
+}
+
+type ParseDetectionFilterTest struct {
+	years, states, established, densities string
+	filter                                DetectionFilter
+	fails                                 bool
+}
+
+type SelectTest struct {
+	filter    DetectionFilter
+	presences []string // point IDs
+	absences  []string
+}
+
+func TestReadSampleDataFromFile(t *testing.T) {
+	lyde := "source,year,bio_year,latitude,longitude,state,lyde_present,lyde_established,lyde_density,source_agency,collection_method,pointid,rounded_longitude,rounded_latitude\n"
+	tests := []ReadSampleDataTest{
+		{
+			name: "lyde schema",
+			input: lyde +
+				"PDA,2021,2021,40.5,-79.5,PA,TRUE,TRUE,High,PDA,visual,p1,-79.5,40.5\n" +
+				"PDA,2022,2021,40.25,-79.25,PA,FALSE,NA,NA,PDA,trap,p2,NA,NA\n" +
+				"\n" +
+				"PDA,2021,2021,NA,-79.5,PA,TRUE,TRUE,High,PDA,visual,p3,NA,NA\n" +
+				"PDA,2021,2021,40.5,-79.5,PA,NA,NA,NA,PDA,visual,p4,NA,NA\n",
+			surveys: []SampleData{
+				{Year: 2021, BioYear: 2021, Latitude: 40.5, Longitude: -79.5, State: "PA", LydePresent: true, LydeEstablished: true, LydeDensity: "High", PointID: "p1", establishedKnown: true},
+				{Year: 2022, BioYear: 2021, Latitude: 40.25, Longitude: -79.25, State: "PA", PointID: "p2"},
+			},
+			dropped: map[string]int{"no coordinates": 1, "presence not recorded": 1},
+		},
+		{
+			name:  "tab separated with aliases",
+			input: "\ufeffBioYear\tLat\tLong\tPresent\tEstablished\n2020\t39.9\t-75.1\tyes\tn\n",
+			surveys: []SampleData{
+				{Year: 2020, BioYear: 2020, Latitude: 39.9, Longitude: -75.1, LydePresent: true, establishedKnown: true},
+			},
+		},
+		{
+			name: "row errors do not stop the read",
+			input: "bio_year,latitude,longitude,lyde_present,lyde_established\n" +
+				"2021,40,-79,TRUE,TRUE\n" +
+				"twenty,40,-79,TRUE,TRUE\n" +
+				"2021,95,-79,TRUE,TRUE\n" +
+				"2021,40,west,TRUE,TRUE\n" +
+				"2021,40,-79,maybe,TRUE\n" +
+				"2021,40,-79,TRUE,perhaps\n",
+			surveys: []SampleData{{Year: 2021, BioYear: 2021, Latitude: 40, Longitude: -79, LydePresent: true, LydeEstablished: true, establishedKnown: true}},
+			errors:  []string{"bio_year", "latitude", "longitude", "lyde_present", "lyde_established"},
+		},
+		{name: "no presence column", input: "bio_year,latitude,longitude\n2021,40,-79\n", fails: true},
+		{name: "no coordinates", input: "bio_year,lyde_present\n2021,TRUE\n", fails: true},
+		{name: "empty file", input: "", fails: true},
+	}
+
+	for _, test := range tests {
+		path := writeTestFile(t, "detections.csv", test.input)
+		surveys, report, err := ReadSampleDataFromFile(path)
+		if (err != nil) != test.fails {
+			t.Errorf("ReadSampleDataFromFile(%s) error = %v, want error %v", test.name, err, test.fails)
+			continue
+		}
+		if test.fails {
+			continue
+		}
+		if len(surveys) != len(test.surveys) {
+			t.Errorf("ReadSampleDataFromFile(%s) read %d surveys, want %d", test.name, len(surveys), len(test.surveys))
+			continue
+		}
+		for i, want := range test.surveys {
+			got := surveys[i]
+			got.Source, got.SourceAgency, got.CollectionMethod = "", "", ""
+			got.RoundedLatitude, got.RoundedLongitude = 0, 0
+			if got != want {
+				t.Errorf("ReadSampleDataFromFile(%s) survey %d = %+v, want %+v", test.name, i, got, want)
+			}
+		}
+		for reason, n := range test.dropped {
+			if report.dropped[reason] != n {
+				t.Errorf("ReadSampleDataFromFile(%s) dropped %d rows for %q, want %d", test.name, report.dropped[reason], reason, n)
+			}
+		}
+		if len(report.errors) != len(test.errors) {
+			t.Errorf("ReadSampleDataFromFile(%s) row errors = %v, want errors in %v", test.name, report.errors, test.errors)
+			continue
+		}
+		for i, e := range report.errors {
+			if e.column != test.errors[i] || e.line != i+3 {
+				t.Errorf("ReadSampleDataFromFile(%s) row error %d = %v, want one in %s on line %d", test.name, i, e, test.errors[i], i+3)
+			}
+		}
+	}
+
+	if _, _, err := ReadSampleDataFromFile(filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Errorf("ReadSampleDataFromFile of a missing file did not fail")
+	}
+}
+
+func TestReadSampleDataRoundedCoordinates(t *testing.T) {
+	path := writeTestFile(t, "detections.csv", "bio_year,latitude,longitude,lyde_present,rounded_latitude,rounded_longitude\n"+
+		"2021,40.123,-79.456,TRUE,40.1,-79.5\n2021,40.123,-79.456,TRUE,NA,NA\n")
+	surveys, _, err := ReadSampleDataFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(surveys) != 2 {
+		t.Fatalf("read %d surveys, want 2", len(surveys))
+	}
+	if surveys[0].RoundedLatitude != 40.1 || surveys[0].RoundedLongitude != -79.5 {
+		t.Errorf("rounded coordinates = %v, %v, want 40.1, -79.5", surveys[0].RoundedLatitude, surveys[0].RoundedLongitude)
+	}
+	if surveys[1].RoundedLatitude != 40.123 || surveys[1].RoundedLongitude != -79.456 {
+		t.Errorf("missing rounded coordinates = %v, %v, want the exact ones", surveys[1].RoundedLatitude, surveys[1].RoundedLongitude)
+	}
+}
+
+func TestParseDetectionFilter(t *testing.T) {
+	tests := []ParseDetectionFilterTest{
+		{filter: DetectionFilter{}},
+		{years: "2021", established: "any", filter: DetectionFilter{minYear: 2021, maxYear: 2021}},
+		{years: " 2019 - 2021 ", states: "PA, NJ,,", established: "Yes", densities: "High",
+			filter: DetectionFilter{minYear: 2019, maxYear: 2021, states: []string{"PA", "NJ"}, established: "yes", densities: []string{"High"}}},
+		{established: "false", filter: DetectionFilter{established: "no"}},
+		{years: "2021-2019", fails: true},
+		{years: "2021-", fails: true},
+		{years: "last year", fails: true},
+		{established: "sometimes", fails: true},
+	}
+
+	for _, test := range tests {
+		filter, err := ParseDetectionFilter(test.years, test.states, test.established, test.densities)
+		if (err != nil) != test.fails {
+			t.Errorf("ParseDetectionFilter(%q, %q, %q, %q) error = %v, want error %v", test.years, test.states, test.established, test.densities, err, test.fails)
+			continue
+		}
+		if filter.minYear != test.filter.minYear || filter.maxYear != test.filter.maxYear || filter.established != test.filter.established ||
+			strings.Join(filter.states, ",") != strings.Join(test.filter.states, ",") ||
+			strings.Join(filter.densities, ",") != strings.Join(test.filter.densities, ",") {
+			t.Errorf("ParseDetectionFilter(%q, %q, %q, %q) = %+v, want %+v", test.years, test.states, test.established, test.densities, filter, test.filter)
+		}
+	}
+}
+
+func TestDetectionFilterSelect(t *testing.T) {
+	surveys := []SampleData{
+		{PointID: "a", BioYear: 2019, State: "PA", LydePresent: true, LydeEstablished: true, establishedKnown: true, LydeDensity: "High"},
+		{PointID: "b", BioYear: 2020, State: "NJ", LydePresent: true, LydeDensity: "Low"},
+		{PointID: "c", BioYear: 2021, State: "pa", LydePresent: false, establishedKnown: true},
+		{PointID: "d", BioYear: 2022, State: "DE", LydePresent: true, establishedKnown: true},
+	}
+	tests := []SelectTest{
+		{filter: DetectionFilter{}, presences: []string{"a", "b", "d"}, absences: []string{"c"}},
+		{filter: DetectionFilter{minYear: 2020, maxYear: 2021}, presences: []string{"b"}, absences: []string{"c"}},
+		{filter: DetectionFilter{states: []string{"PA"}}, presences: []string{"a"}, absences: []string{"c"}},
+		// b does not record its establishment
+		{filter: DetectionFilter{established: "no"}, presences: []string{"d"}, absences: []string{"c"}},
+		{filter: DetectionFilter{established: "yes"}, presences: []string{"a"}},
+		{filter: DetectionFilter{densities: []string{"low", "high"}}, presences: []string{"a", "b"}},
+	}
+
+	ids := func(surveys []SampleData) string {
+		var ids []string
+		for _, s := range surveys {
+			ids = append(ids, s.PointID)
+		}
+		return strings.Join(ids, ",")
+	}
+	for _, test := range tests {
+		report := DetectionReport{dropped: make(map[string]int), byState: make(map[string]int)}
+		presences, absences := test.filter.Select(surveys, &report)
+		if ids(presences) != strings.Join(test.presences, ",") || ids(absences) != strings.Join(test.absences, ",") {
+			t.Errorf("Select(%+v) = %s and %s, want %s and %s", test.filter, ids(presences), ids(absences), strings.Join(test.presences, ","), strings.Join(test.absences, ","))
+		}
+		dropped := 0
+		for _, n := range report.dropped {
+			dropped += n
+		}
+		if report.kept != len(presences)+len(absences) || report.absences != len(absences) || dropped+report.kept != len(surveys) {
+			t.Errorf("Select(%+v) report counts %d kept, %d absences and %d dropped of %d", test.filter, report.kept, report.absences, dropped, len(surveys))
+		}
+	}
+
+	report := DetectionReport{dropped: make(map[string]int), byState: make(map[string]int)}
+	DetectionFilter{}.Select(surveys, &report)
+	if report.firstYear != 2019 || report.lastYear != 2022 || report.byState["PA"] != 1 || report.byState["pa"] != 1 {
+		t.Errorf("report = %+v, want bio years 2019 to 2022 and states as written", report)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Displacement summarises how far the living flies of one stage moved in a day, in metres, for checking the movement rules
+// against field studies of nymph and adult dispersal. Every day a fly was alive counts once (a fly-day), in the stage
+// the fly was in when it moved.
+type Displacement struct {
+	stage  int
+	flies  int // fly-days counted
+	moving int // fly-days on which the fly moved at all
+	mean   float64
+	median float64
+	p95    float64
+	max    float64
+}
+
+// MeasureDisplacement returns the daily displacement of the living flies of each stage, from eggs to adults,
+// over the given snapshots. Each snapshot contributes every day of the tick that ended in it, so weekly runs are measured on all seven days.
+func MeasureDisplacement(countries []Country) []Displacement {
+	moves := make([][]float64, 6)
+	for _, country := range countries {
+		for stage, m := range country.moves {
+			for _, km := range m {
+				moves[stage] = append(moves[stage], km*1000)
+			}
+		}
+	}
+
+	stats := make([]Displacement, len(moves))
+	for stage, m := range moves {
+		stats[stage] = summarizeDisplacement(stage, m)
+	}
+	return stats
+}
+
+// summarizeDisplacement returns the statistics of the daily moves of one stage, in metres.
+func summarizeDisplacement(stage int, moves []float64) Displacement {
+	d := Displacement{stage: stage, flies: len(moves)}
+	if len(moves) == 0 {
+		return d
+	}
+	sort.Float64s(moves)
+	sum := 0.0
+	for _, m := range moves {
+		sum += m
+		if m > 0 {
+			d.moving++
+		}
+	}
+	d.mean = sum / float64(len(moves))
+	d.median = moves[len(moves)/2]
+	d.p95 = moves[int(math.Ceil(0.95*float64(len(moves))))-1]
+	d.max = moves[len(moves)-1]
+	return d
+}
+
+// PrintDisplacement prints a table of the daily displacement of each stage that had living flies.
+func PrintDisplacement(stats []Displacement) {
+	fmt.Printf("%-10s %10s %8s %12s %12s %12s %12s\n", "stage", "fly-days", "moving", "mean (m)", "median (m)", "95% (m)", "max (m)")
+	for _, d := range stats {
+		if d.flies == 0 {
+			continue
+		}
+		fmt.Printf("%-10s %10d %7.1f%% %12.1f %12.1f %12.1f %12.1f\n",
+			stageNames[d.stage], d.flies, 100*float64(d.moving)/float64(d.flies), d.mean, d.median, d.p95, d.max)
+	}
+}
+
+// WriteDisplacementCSV writes one row per snapshot and stage with living flies: date, stage, the fly-days and those with any move
+// (fly_days and moving), and the mean_m, median_m, p95_m and max_m distance moved in a day over the days of the tick that ended in the snapshot.
+func WriteDisplacementCSV(filename string, countries []Country) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", filename, err)
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	w.Write([]string{"date", "stage", "fly_days", "moving", "mean_m", "median_m", "p95_m", "max_m"})
+	for _, country := range countries {
+		for _, d := range MeasureDisplacement([]Country{country}) {
+			if d.flies == 0 {
+				continue
+			}
+			w.Write([]string{
+				country.date.Format(dateLayout),
+				stageNames[d.stage],
+				strconv.Itoa(d.flies),
+				strconv.Itoa(d.moving),
+				strconv.FormatFloat(d.mean, 'f', 2, 64),
+				strconv.FormatFloat(d.median, 'f', 2, 64),
+				strconv.FormatFloat(d.p95, 'f', 2, 64),
+				strconv.FormatFloat(d.max, 'f', 2, 64),
+			})
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("error writing %s: %v", filename, err)
+	}
+	return nil
+}
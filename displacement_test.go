@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type SummarizeDisplacementTest struct {
+	moves  []float64
+	result Displacement
+}
+
+func TestSummarizeDisplacement(t *testing.T) {
+	twenty := make([]float64, 20)
+	for i := range twenty {
+		twenty[i] = float64(20 - i) // unsorted
+	}
+	tests := []SummarizeDisplacementTest{
+		{moves: nil, result: Displacement{stage: 1}},
+		{moves: []float64{5}, result: Displacement{stage: 1, flies: 1, moving: 1, mean: 5, median: 5, p95: 5, max: 5}},
+		{moves: []float64{0, 0, 9, 3}, result: Displacement{stage: 1, flies: 4, moving: 2, mean: 3, median: 3, p95: 9, max: 9}},
+		{moves: []float64{0, 0, 0}, result: Displacement{stage: 1, flies: 3}},
+		// the 95th percentile of 20 moves is the 19th smallest
+		{moves: twenty, result: Displacement{stage: 1, flies: 20, moving: 20, mean: 10.5, median: 11, p95: 19, max: 20}},
+	}
+
+	for _, test := range tests {
+		if result := summarizeDisplacement(1, test.moves); result != test.result {
+			t.Errorf("summarizeDisplacement(%v) = %+v, want %+v", test.moves, result, test.result)
+		}
+	}
+}
+
+func TestMeasureDisplacement(t *testing.T) {
+	var first, second Country
+	first.moves[1] = []float64{0.01, 0.01}
+	first.moves[5] = []float64{2}
+	second.moves[1] = []float64{0.03}
+	stats := MeasureDisplacement([]Country{first, second})
+	if len(stats) != 6 {
+		t.Fatalf("MeasureDisplacement returned %d stages, want 6", len(stats))
+	}
+	if d := stats[1]; d.flies != 3 || !closeTo(d.mean, 50.0/3) || !closeTo(d.max, 30) {
+		t.Errorf("instar 1 displacement = %+v, want 3 fly-days of mean 16.7 m and max 30 m", d)
+	}
+	if d := stats[5]; d.flies != 1 || d.mean != 2000 {
+		t.Errorf("adult displacement = %+v, want 1 fly-day of 2000 m", d)
+	}
+	if stats[0].flies != 0 {
+		t.Errorf("egg displacement = %+v, want none", stats[0])
+	}
+
+	first.date, second.date = date(2021, 7, 1), date(2021, 7, 8)
+	filename := filepath.Join(t.TempDir(), "displacement.csv")
+	if err := WriteDisplacementCSV(filename, []Country{first, second}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "date,stage,fly_days,moving,mean_m,median_m,p95_m,max_m\n" +
+		"2021-07-01," + stageNames[1] + ",2,2,10.00,10.00,10.00,10.00\n" +
+		"2021-07-01," + stageNames[5] + ",1,1,2000.00,2000.00,2000.00,2000.00\n" +
+		"2021-07-08," + stageNames[1] + ",1,1,30.00,30.00,30.00,30.00\n"
+	if string(data) != want {
+		t.Errorf("WriteDisplacementCSV wrote %q, want %q", data, want)
+	}
+}
+
+// TestStepSimulationMeasuresEveryDay checks that a weekly tick records the moves of all seven days, not only the last.
+func TestStepSimulationMeasuresEveryDay(t *testing.T) {
+	// too cold to develop, fly or die; nymphs find no host and walk 10 m a day
+	weather := Weather{Quadrants: []Quadrant{{x: -80, y: 40, width: 2, height: 2, id: 0, temp: 5, minTemp: 0}}}
+	country := Country{date: date(2021, 7, 1), flies: []Fly{
+		{position: OrderedPair{-79, 41}, stage: 1, isAlive: true},
+		{position: OrderedPair{-79, 41}, stage: 5, energy: instar4ToAdultThreshold, isAlive: true},
+		{position: OrderedPair{-79, 41}, stage: 3, isAlive: false},
+	}}
+
+	next := StepSimulation(country, weather, NewClock(country.date, Weekly))
+	if !next.date.Equal(date(2021, 7, 8)) {
+		t.Errorf("StepSimulation ended on %v, want 2021-07-08", next.date)
+	}
+	stats := MeasureDisplacement([]Country{next})
+	if d := stats[1]; d.flies != 7 || d.moving != 7 || !closeTo(d.mean, 10) {
+		t.Errorf("instar 1 displacement over a week = %+v, want 7 fly-days of 10 m", d)
+	}
+	if d := stats[5]; d.flies != 7 || d.moving != 0 {
+		t.Errorf("adult displacement over a cold week = %+v, want 7 fly-days without a move", d)
+	}
+	if stats[3].flies != 0 {
+		t.Errorf("a dead fly was measured: %+v", stats[3])
+	}
+}
+
+// TestStepSimulationMeasuresMovingStage checks that a nymph that becomes an adult during the day has its move counted as a nymph's.
+func TestStepSimulationMeasuresMovingStage(t *testing.T) {
+	weather := Weather{Quadrants: []Quadrant{{x: -80, y: 40, width: 2, height: 2, id: 0, temp: 25, minTemp: 15}}}
+	country := Country{date: date(2021, 7, 1)}
+	for i := 0; i < 20; i++ {
+		country.flies = append(country.flies, Fly{position: OrderedPair{-79, 41}, stage: 4, energy: instar4ToAdultThreshold - 1, isAlive: true})
+	}
+
+	next := StepSimulation(country, weather, NewClock(country.date, Daily))
+	alive := 0
+	for _, fly := range next.flies {
+		if fly.isAlive {
+			alive++
+			if fly.stage != 5 {
+				t.Fatalf("a nymph of %v degree-days stayed in stage %d, want 5", instar4ToAdultThreshold-1, fly.stage)
+			}
+		}
+	}
+	stats := MeasureDisplacement([]Country{next})
+	if d := stats[4]; d.flies != alive {
+		t.Errorf("instar 4 displacement = %+v, want the %d moves of the nymphs that became adults", d, alive)
+	}
+	if d := stats[5]; d.flies != 0 {
+		t.Errorf("adult displacement = %+v, want none on the day the nymphs became adults", d)
+	}
+}
@@ -2,24 +2,30 @@ package main
 
 import (
 	"canvas"
+	"fmt"
 	"image"
 	"image/color"
+	"math"
+	"strconv"
 )
 
-// AnimateSystem takes a slice of Country objects along with a canvas width
-// parameter and generates a slice of images corresponding to drawing each Country
-// It then creates an empty slice called images to store the resulting animation frames.
-// The function iterates through the daily time points, and only appends a new frame to the images slice if the current index is divisible by the frequency.
-// Finally, the function returns the slice of images representing the animated system.
-func AnimateSystem(dailyTimePoints []Country, canvasWidth, canvasHeight int, frequency int) []image.Image {
-	images := make([]image.Image, 0)
+// WriteAnimation takes a slice of Country objects along with a basemap, render settings and canvas size parameters,
+// and draws every frequency-th Country, recording every one of them in the render history (needed by the occupancy and arrival modes).
+// Each frame is handed to the encoder as soon as it is drawn instead of keeping them all in memory. The encoder is closed after the last frame.
+func WriteAnimation(dailyTimePoints []Country, basemap Basemap, settings RenderSettings, canvasWidth, canvasHeight int, frequency int, encoder FrameEncoder) error {
+	history := NewRenderHistory(settings, basemap.quadrants, basemap.bounds)
 
 	for i := range dailyTimePoints {
+		history.Add(dailyTimePoints[i])
 		if i%frequency == 0 {
-			images = append(images, DrawToCanvas(dailyTimePoints[i], canvasWidth, canvasHeight))
+			frame := DrawToCanvas(dailyTimePoints[i], basemap, settings, history, canvasWidth, canvasHeight)
+			if err := encoder.WriteFrame(frame); err != nil {
+				encoder.Close()
+				return err
+			}
 		}
 	}
-	return images
+	return encoder.Close()
 }
 
 // GetFlyColor returns the color for a fly based on its stage
@@ -50,22 +56,62 @@ func GetFlyColor(fly Fly) color.Color {
 	}
 }
 
-// DrawToCanvas generates the image corresponding to a canvas after drawing a Quadrant
-// object's flies on a square canvas that is canvasWidth pixels x canvasWidth pixels
-// takes a Country, canvas width, and canvas height as input and returns an image.Image.
-// It creates a new canvas and sets a black background.
-// It then draws the flies and trees in the country.
+// DrawToCanvas generates the image corresponding to a canvas after drawing a Country
+// object's trees and flies on top of a basemap on a canvas that is canvasWidth pixels x canvasHeight pixels
+// takes a Country, a Basemap, render settings and history, canvas width, and canvas height as input and returns an image.Image.
+// It creates a new canvas and draws the frame on it with DrawFrame.
 // The function returns the drawn image.
-func DrawToCanvas(country Country, canvasWidth, canvasHeight int) image.Image {
-	// set a new square canvas
+func DrawToCanvas(country Country, basemap Basemap, settings RenderSettings, history *RenderHistory, canvasWidth, canvasHeight int) image.Image {
+	// set a new canvas
 	c := canvas.CreateNewCanvas(canvasWidth, canvasHeight)
 
+	if err := DrawFrame(&c, country, basemap, settings, history); err != nil {
+		fmt.Println("Error drawing frame:", err)
+	}
+
+	// we want to return an image!
+	return c.GetImage()
+}
+
+// DrawFrame draws a Country on any drawing backend: a raster canvas, an SVG or PDF canvas, or a panel of a larger figure.
+// It sets a black background and draws the basemap.
+// Trees and flies are then placed on the map with the basemap's projection, so their positions line up with the state outlines.
+// In the points mode every fly is drawn in its stage colour; the other render modes draw a heatmap, choropleth or arrival map instead (see DrawRenderLayer).
+// Finally the title, date, legend, population bar and scale bar are drawn on top (see DrawOverlay).
+func DrawFrame(c canvas.Drawer, country Country, basemap Basemap, settings RenderSettings, history *RenderHistory) error {
+	canvasWidth, canvasHeight := c.Width(), c.Height()
+
 	// create a black background
 	c.SetFillColor(canvas.MakeColor(0, 0, 0))
-	c.ClearRect(0, 0, canvasWidth, canvasHeight)
+	c.Rect(0, 0, float64(canvasWidth), float64(canvasHeight))
 	c.Fill()
 
+	projection, err := basemap.Projection(canvasWidth, canvasHeight)
+	if err != nil {
+		return fmt.Errorf("error setting up map projection: %v", err)
+	}
+
+	DrawBasemap(c, basemap, projection)
+
+	//draw trees
+	c.SetFillColor(canvas.MakeColor(0, 175, 0))
+	treeRadius := float64(canvasWidth) / 4000
+	for _, tree := range country.trees {
+		cx, cy := projection.Project(tree.position.x, tree.position.y)
+		c.Circle(cx, cy, treeRadius)
+		c.Fill()
+	}
+
+	if settings.mode != RenderPoints {
+		lo, hi := DrawRenderLayer(c, country, basemap, settings, history, projection)
+		DrawOverlay(c, country, basemap, projection)
+		fontSize := float64(canvasWidth) / 60
+		DrawRampLegend(c, settings.ramp, lo, hi, float64(canvasWidth)*0.98, float64(canvasWidth)*0.02, fontSize)
+		return nil
+	}
+
 	// range over all the flies and draw them.
+	flyRadius := float64(canvasWidth) / 1000
 	for _, fly := range country.flies {
 		// Get the color based on the fly's stage and alive status
 		color := GetFlyColor(fly)
@@ -73,23 +119,126 @@ func DrawToCanvas(country Country, canvasWidth, canvasHeight int) image.Image {
 		// Set the fly color
 		c.SetFillColor(color)
 
-		cx := (fly.position.x / float64(country.width)) * float64(canvasHeight)
-		cy := (fly.position.y / float64(country.width)) * float64(canvasHeight)
-		r := 10
-		c.Circle(cx, cy, float64(r))
+		cx, cy := projection.Project(fly.position.x, fly.position.y)
+		c.Circle(cx, cy, flyRadius)
 		c.Fill()
 	}
 
-	//draw trees
-	for _, tree := range country.trees {
-		c.SetFillColor(canvas.MakeColor(0, 175, 0))
-		cx := (tree.position.x / float64(country.width)) * float64(canvasHeight)
-		cy := (tree.position.y / float64(country.width)) * float64(canvasHeight)
-		r := 100
-		c.Circle(cx, cy, float64(r))
+	DrawOverlay(c, country, basemap, projection)
+	return nil
+}
+
+// DrawOverlay draws the annotations of a frame on top of the map: the title, the simulation date,
+// a legend of the stage colours with the number of flies in each stage, a population bar and a scale bar.
+// Sizes are proportional to the canvas width. Text is skipped if no font has been loaded with canvas.LoadFont.
+func DrawOverlay(c canvas.Drawer, country Country, basemap Basemap, projection Projection) {
+	width := float64(c.Width())
+	height := float64(c.Height())
+	margin := 0.02 * width
+	fontSize := width / 60
+
+	census := TakeCensus(country)
+	white := canvas.MakeColor(255, 255, 255)
+	grey := canvas.MakeColor(130, 130, 130)
+
+	// title and date
+	c.SetFillColor(white)
+	c.SetFontSize(fontSize * 1.5)
+	c.FillText(basemap.title, margin, margin+fontSize*1.5)
+	c.SetFontSize(fontSize)
+	if !country.date.IsZero() {
+		c.FillText(country.date.Format("January 2, 2006")+" ("+Season(country.date)+")", margin, margin+fontSize*3)
+	}
+
+	// stage legend, bottom left
+	rowHeight := fontSize * 1.4
+	legendTop := height - margin - rowHeight*float64(len(stageNames))
+	for i, name := range stageNames {
+		y := legendTop + rowHeight*float64(i)
+		c.SetFillColor(stageColor(i))
+		c.SetStrokeColor(grey)
+		c.SetLineWidth(width / 4000)
+		c.Rect(margin, y, fontSize, fontSize)
+		c.FillStroke()
+
+		c.SetFillColor(white)
+		c.FillText(name+": "+strconv.Itoa(census.stages[i]), margin+fontSize*1.5, y+fontSize*0.9)
+	}
+
+	// population bar, bottom centre: the share of each living stage in the population
+	barWidth := width * 0.4
+	barHeight := fontSize
+	barX := (width - barWidth) / 2
+	barY := height - margin - barHeight
+	c.SetFillColor(white)
+	label := "living flies: " + strconv.Itoa(census.alive)
+	if census.emigrated > 0 {
+		label += ", emigrated: " + strconv.Itoa(census.emigrated)
+	}
+	c.FillText(label, barX, barY-fontSize*0.4)
+	x := barX
+	for i := 0; i < 6; i++ {
+		if census.alive == 0 || census.stages[i] == 0 {
+			continue
+		}
+		w := barWidth * float64(census.stages[i]) / float64(census.alive)
+		c.SetFillColor(stageColor(i))
+		c.Rect(x, barY, w, barHeight)
 		c.Fill()
+		x += w
 	}
+	c.SetStrokeColor(grey)
+	c.Rect(barX, barY, barWidth, barHeight)
+	c.Stroke()
 
-	// we want to return an image!
-	return c.GetImage()
+	DrawScaleBar(c, basemap, projection, width-margin, height-margin, fontSize)
+}
+
+// DrawScaleBar draws a scale bar whose right end is at (right, bottom).
+// The length of the bar is a round number of kilometres close to a sixth of the canvas width, measured along the middle parallel of the map.
+func DrawScaleBar(c canvas.Drawer, basemap Basemap, projection Projection, right, bottom, fontSize float64) {
+	lat := (basemap.bounds.minLat + basemap.bounds.maxLat) / 2
+	lon := (basemap.bounds.minLon + basemap.bounds.maxLon) / 2
+
+	// pixels per kilometre along the middle parallel
+	kmPerDegree := math.Pi / 180 * earthRadius * math.Cos(lat*math.Pi/180)
+	x0, _ := projection.Project(lon, lat)
+	x1, _ := projection.Project(lon+1, lat)
+	pixelsPerKm := math.Abs(x1-x0) / kmPerDegree
+	if pixelsPerKm == 0 {
+		return
+	}
+
+	// pick a round length: 1, 2 or 5 times a power of ten
+	target := float64(c.Width()) / 6 / pixelsPerKm
+	magnitude := math.Pow(10, math.Floor(math.Log10(target)))
+	km := magnitude
+	for _, m := range []float64{2, 5, 10} {
+		if m*magnitude <= target {
+			km = m * magnitude
+		}
+	}
+
+	length := km * pixelsPerKm
+	left := right - length
+	c.SetStrokeColor(canvas.MakeColor(255, 255, 255))
+	c.SetLineWidth(fontSize / 8)
+	c.MoveTo(left, bottom-fontSize/2)
+	c.LineTo(left, bottom)
+	c.LineTo(right, bottom)
+	c.LineTo(right, bottom-fontSize/2)
+	c.Stroke()
+
+	label := strconv.FormatFloat(km, 'f', -1, 64) + " km"
+	w, _ := c.TextSize(label)
+	c.SetFillColor(canvas.MakeColor(255, 255, 255))
+	c.FillText(label, left+(length-w)/2, bottom-fontSize*0.6)
+}
+
+// stageColor returns the colour of a stage as drawn by GetFlyColor. Index 6 is the colour of dead flies.
+func stageColor(stage int) color.Color {
+	if stage == 6 {
+		return GetFlyColor(Fly{isAlive: false})
+	}
+	return GetFlyColor(Fly{stage: stage, isAlive: true})
 }
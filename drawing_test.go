@@ -0,0 +1,132 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type TakeCensusTest struct {
+	country Country
+	result  Census
+}
+
+// recordingDrawer is a canvas.Drawer that keeps the text it is asked to draw and the points of its paths.
+type recordingDrawer struct {
+	width, height int
+	texts         []string
+	path          [][2]float64
+	strokes       [][][2]float64 // the paths painted with Stroke
+}
+
+func (d *recordingDrawer) MoveTo(x, y float64)                                     { d.path = append(d.path, [2]float64{x, y}) }
+func (d *recordingDrawer) LineTo(x, y float64)                                     { d.path = append(d.path, [2]float64{x, y}) }
+func (d *recordingDrawer) ArcTo(x, y, radiusX, radiusY, startAngle, angle float64) {}
+func (d *recordingDrawer) ClosePath()                                              {}
+func (d *recordingDrawer) Circle(cx, cy, r float64)                                {}
+func (d *recordingDrawer) Ellipse(cx, cy, rx, ry float64)                          {}
+func (d *recordingDrawer) Rect(x, y, w, h float64)                                 {}
+func (d *recordingDrawer) SetStrokeColor(col color.Color)                          {}
+func (d *recordingDrawer) SetFillColor(col color.Color)                            {}
+func (d *recordingDrawer) SetLineWidth(w float64)                                  {}
+func (d *recordingDrawer) Stroke()                                                 { d.strokes = append(d.strokes, d.path); d.path = nil }
+func (d *recordingDrawer) Fill()                                                   { d.path = nil }
+func (d *recordingDrawer) FillStroke()                                             { d.path = nil }
+func (d *recordingDrawer) SetFontSize(size float64)                                {}
+func (d *recordingDrawer) TextSize(text string) (float64, float64)                 { return 0, 0 }
+func (d *recordingDrawer) Width() int                                              { return d.width }
+func (d *recordingDrawer) Height() int                                             { return d.height }
+func (d *recordingDrawer) FillText(text string, x, y float64) float64 {
+	d.texts = append(d.texts, text)
+	return 0
+}
+
+func TestTakeCensus(t *testing.T) {
+	tests := []TakeCensusTest{
+		{country: Country{}, result: Census{}},
+		{
+			country: Country{
+				flies: []Fly{
+					{stage: 0, isAlive: true}, {stage: 1, isAlive: true}, {stage: 5, isAlive: true}, {stage: 5, isAlive: true},
+					{stage: 3, isAlive: false}, {stage: 6, isAlive: true}, {stage: -1, isAlive: true},
+				},
+				eggs:      []Fly{{}, {}},
+				emigrated: 3,
+			},
+			result: Census{stages: [7]int{1, 1, 0, 0, 0, 2, 3}, alive: 4, eggsPending: 2, emigrated: 3},
+		},
+	}
+
+	for _, test := range tests {
+		result := TakeCensus(test.country)
+		if result.stages != test.result.stages || result.alive != test.result.alive ||
+			result.eggsPending != test.result.eggsPending || result.emigrated != test.result.emigrated {
+			t.Errorf("TakeCensus(%v) = %+v, want %+v", test.country.flies, result, test.result)
+		}
+	}
+}
+
+func TestDrawScaleBar(t *testing.T) {
+	for _, width := range []int{300, 800, 1500, 4000} {
+		basemap := Basemap{bounds: ContiguousUS()}
+		projection, err := basemap.Projection(width, width/2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := &recordingDrawer{width: width, height: width / 2}
+		DrawScaleBar(d, basemap, projection, float64(width)-10, float64(width/2)-10, 12)
+
+		if len(d.texts) != 1 || !strings.HasSuffix(d.texts[0], " km") {
+			t.Fatalf("scale bar of a %d pixel map is labelled %v", width, d.texts)
+		}
+		km, err := strconv.ParseFloat(strings.TrimSuffix(d.texts[0], " km"), 64)
+		if err != nil {
+			t.Fatalf("scale bar label %q is not a number of km", d.texts[0])
+		}
+		// a round length: 1, 2 or 5 times a power of ten
+		leading := km / math.Pow(10, math.Floor(math.Log10(km)))
+		if math.Abs(leading-1) > 1e-9 && math.Abs(leading-2) > 1e-9 && math.Abs(leading-5) > 1e-9 {
+			t.Errorf("scale bar length %v km is not a round number", km)
+		}
+
+		// the bar is drawn at that length, at most a sixth of the map
+		if len(d.strokes) != 1 || len(d.strokes[0]) != 4 {
+			t.Fatalf("scale bar drawn as %v", d.strokes)
+		}
+		length := d.strokes[0][2][0] - d.strokes[0][1][0]
+		lat := (basemap.bounds.minLat + basemap.bounds.maxLat) / 2
+		x0, _ := projection.Project(-100, lat)
+		x1, _ := projection.Project(-99, lat)
+		wantLength := km * math.Abs(x1-x0) / (math.Pi / 180 * earthRadius * math.Cos(lat*math.Pi/180))
+		if math.Abs(length-wantLength) > 1e-6 || length > float64(width)/6+1e-6 || length < float64(width)/6/5 {
+			t.Errorf("scale bar of %v km on a %d pixel map is %.1f pixels long, want %.1f", km, width, length, wantLength)
+		}
+	}
+}
+
+func TestDrawOverlayLegend(t *testing.T) {
+	country := Country{
+		flies:     []Fly{{stage: 1, isAlive: true}, {stage: 5, isAlive: true}, {stage: 5, isAlive: true}, {stage: 2, isAlive: false}},
+		date:      date(2021, 8, 1),
+		emigrated: 4,
+	}
+	basemap := Basemap{title: "Test map", bounds: ContiguousUS()}
+	projection, err := basemap.Projection(800, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &recordingDrawer{width: 800, height: 500}
+	DrawOverlay(d, country, basemap, projection)
+
+	for _, want := range []string{"Test map", "August 1, 2021 (summer)", "instar 1: 1", "adult: 2", "dead: 1", "living flies: 3, emigrated: 4"} {
+		found := false
+		for _, text := range d.texts {
+			found = found || text == want
+		}
+		if !found {
+			t.Errorf("overlay text %q missing from %q", want, d.texts)
+		}
+	}
+}
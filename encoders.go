@@ -0,0 +1,588 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"gifhelper"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FrameEncoder writes the frames of an animation one at a time, so that frames can be written as soon as they are drawn.
+// Close must be called after the last frame to finish the file.
+type FrameEncoder interface {
+	WriteFrame(img image.Image) error
+	Close() error
+}
+
+// EncoderOptions are the settings shared by all encoders.
+type EncoderOptions struct {
+	frameRate float64 // frames per second
+	quality   int     // 1 (smallest file) to 100 (best image), used by the lossy formats
+}
+
+// NewFrameEncoder creates an encoder for the named format writing to output.
+// The formats are:
+//   - gif: animated GIF through gifhelper (frames are kept in memory until Close, and gifhelper picks the frame delay)
+//   - apng: animated PNG
+//   - png: numbered PNG files, output_00000.png, output_00001.png, ...
+//   - avi: Motion-JPEG AVI
+//   - webp: animated WebP, encoded by a local ffmpeg built with libwebp
+//   - ffmpeg: raw frames piped to a local ffmpeg; the container and codec follow the extension of output (.mp4, .webm, .mkv, ...)
+//
+// A missing file extension is added for the apng, avi and webp formats.
+func NewFrameEncoder(format, output string, options EncoderOptions) (FrameEncoder, error) {
+	if options.frameRate <= 0 {
+		return nil, fmt.Errorf("frame rate must be positive, got %v", options.frameRate)
+	}
+	if options.quality < 1 || options.quality > 100 {
+		return nil, fmt.Errorf("quality must be between 1 and 100, got %d", options.quality)
+	}
+
+	switch strings.ToLower(format) {
+	case "gif":
+		return &gifEncoder{output: output}, nil
+	case "apng":
+		return newAPNGEncoder(withExtension(output, ".png"), options)
+	case "png":
+		return &pngSequenceEncoder{prefix: strings.TrimSuffix(output, ".png")}, nil
+	case "avi":
+		return newAVIEncoder(withExtension(output, ".avi"), options)
+	case "webp":
+		return &ffmpegEncoder{output: withExtension(output, ".webp"), options: options}, nil
+	case "ffmpeg":
+		if filepath.Ext(output) == "" {
+			return nil, fmt.Errorf("the ffmpeg format needs an output file with an extension, such as flies.mp4")
+		}
+		return &ffmpegEncoder{output: output, options: options}, nil
+	default:
+		return nil, fmt.Errorf("unknown animation format %q (want gif, apng, png, avi, webp or ffmpeg)", format)
+	}
+}
+
+// withExtension adds ext to the file name unless it already has it.
+func withExtension(name, ext string) string {
+	if strings.EqualFold(filepath.Ext(name), ext) {
+		return name
+	}
+	return name + ext
+}
+
+// gifEncoder collects the frames and hands them to gifhelper.ImagesToGIF on Close, which writes output.out.gif.
+// GIF frames are limited to 256 colours.
+type gifEncoder struct {
+	output string
+	images []image.Image
+}
+
+func (e *gifEncoder) WriteFrame(img image.Image) error {
+	e.images = append(e.images, img)
+	return nil
+}
+
+func (e *gifEncoder) Close() error {
+	gifhelper.ImagesToGIF(e.images, e.output)
+	return nil
+}
+
+// pngSequenceEncoder writes every frame to its own numbered PNG file.
+type pngSequenceEncoder struct {
+	prefix string
+	count  int
+}
+
+func (e *pngSequenceEncoder) WriteFrame(img image.Image) error {
+	name := fmt.Sprintf("%s_%05d.png", e.prefix, e.count)
+	file, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("error creating frame file: %v", err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	if err := png.Encode(w, img); err != nil {
+		return fmt.Errorf("error encoding %s: %v", name, err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("error writing %s: %v", name, err)
+	}
+	e.count++
+	return nil
+}
+
+func (e *pngSequenceEncoder) Close() error {
+	return nil
+}
+
+// apngEncoder writes an animated PNG.
+// Each frame is compressed with image/png and its image data is moved into the frame chunks of the animation.
+// The number of frames is not known until Close, so the animation control chunk is patched in place at the end.
+type apngEncoder struct {
+	file       *os.File
+	w          *bufio.Writer
+	options    EncoderOptions
+	header     []byte // IHDR data of the first frame; every frame must match it
+	actlOffset int64  // file offset of the acTL chunk
+	sequence   uint32 // sequence number of the next fcTL or fdAT chunk
+	frames     uint32
+	written    int64
+}
+
+func newAPNGEncoder(output string, options EncoderOptions) (*apngEncoder, error) {
+	file, err := os.Create(output)
+	if err != nil {
+		return nil, fmt.Errorf("error creating animation file: %v", err)
+	}
+	return &apngEncoder{file: file, w: bufio.NewWriter(file), options: options}, nil
+}
+
+func (e *apngEncoder) WriteFrame(img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, toRGBA(img)); err != nil {
+		return fmt.Errorf("error encoding frame %d: %v", e.frames, err)
+	}
+	chunks, err := readPNGChunks(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("error reading encoded frame %d: %v", e.frames, err)
+	}
+
+	var header []byte
+	var data [][]byte
+	for _, chunk := range chunks {
+		switch chunk.kind {
+		case "IHDR":
+			header = chunk.data
+		case "IDAT":
+			data = append(data, chunk.data)
+		}
+	}
+
+	if e.frames == 0 {
+		e.header = header
+		if err := e.write([]byte("\x89PNG\r\n\x1a\n")); err != nil {
+			return err
+		}
+		if err := e.writeChunk("IHDR", header); err != nil {
+			return err
+		}
+		e.actlOffset = e.written
+		// number of frames (patched on Close) and number of plays, 0 = loop forever
+		if err := e.writeChunk("acTL", make([]byte, 8)); err != nil {
+			return err
+		}
+	} else if !bytes.Equal(header, e.header) {
+		return fmt.Errorf("frame %d does not have the same size and colour type as the first frame", e.frames)
+	}
+
+	// frame control: sequence, width, height, x and y offset, delay, dispose and blend operations
+	delayNum, delayDen := frameDelay(e.options.frameRate)
+	fctl := make([]byte, 26)
+	binary.BigEndian.PutUint32(fctl[0:], e.sequence)
+	copy(fctl[4:12], header[0:8]) // width and height
+	binary.BigEndian.PutUint16(fctl[20:], delayNum)
+	binary.BigEndian.PutUint16(fctl[22:], delayDen)
+	e.sequence++
+	if err := e.writeChunk("fcTL", fctl); err != nil {
+		return err
+	}
+
+	for _, d := range data {
+		if e.frames == 0 {
+			// the first frame is also the default image shown by viewers without APNG support
+			if err := e.writeChunk("IDAT", d); err != nil {
+				return err
+			}
+			continue
+		}
+		fdat := make([]byte, 4+len(d))
+		binary.BigEndian.PutUint32(fdat, e.sequence)
+		copy(fdat[4:], d)
+		e.sequence++
+		if err := e.writeChunk("fdAT", fdat); err != nil {
+			return err
+		}
+	}
+
+	e.frames++
+	return nil
+}
+
+func (e *apngEncoder) Close() error {
+	defer e.file.Close()
+	if e.frames == 0 {
+		return fmt.Errorf("no frames were written")
+	}
+	if err := e.writeChunk("IEND", nil); err != nil {
+		return err
+	}
+	if err := e.w.Flush(); err != nil {
+		return fmt.Errorf("error writing animation: %v", err)
+	}
+
+	// patch the number of frames into the acTL chunk
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl, e.frames)
+	if _, err := e.file.Seek(e.actlOffset, io.SeekStart); err != nil {
+		return fmt.Errorf("error finishing animation: %v", err)
+	}
+	return writePNGChunk(e.file, "acTL", actl)
+}
+
+func (e *apngEncoder) write(b []byte) error {
+	n, err := e.w.Write(b)
+	e.written += int64(n)
+	if err != nil {
+		return fmt.Errorf("error writing animation: %v", err)
+	}
+	return nil
+}
+
+func (e *apngEncoder) writeChunk(kind string, data []byte) error {
+	var buf bytes.Buffer
+	writePNGChunk(&buf, kind, data)
+	return e.write(buf.Bytes())
+}
+
+// pngChunk is one chunk of a PNG file.
+type pngChunk struct {
+	kind string
+	data []byte
+}
+
+// readPNGChunks splits an encoded PNG file into its chunks.
+func readPNGChunks(file []byte) ([]pngChunk, error) {
+	if len(file) < 8 || string(file[:8]) != "\x89PNG\r\n\x1a\n" {
+		return nil, fmt.Errorf("not a PNG file")
+	}
+
+	var chunks []pngChunk
+	for pos := 8; pos < len(file); {
+		if pos+12 > len(file) {
+			return nil, fmt.Errorf("truncated chunk at byte %d", pos)
+		}
+		length := int(binary.BigEndian.Uint32(file[pos:]))
+		if pos+12+length > len(file) {
+			return nil, fmt.Errorf("truncated chunk at byte %d", pos)
+		}
+		chunks = append(chunks, pngChunk{kind: string(file[pos+4 : pos+8]), data: file[pos+8 : pos+8+length]})
+		pos += 12 + length
+	}
+	return chunks, nil
+}
+
+// writePNGChunk writes a chunk with its length and CRC.
+func writePNGChunk(w io.Writer, kind string, data []byte) error {
+	head := make([]byte, 8)
+	binary.BigEndian.PutUint32(head, uint32(len(data)))
+	copy(head[4:], kind)
+
+	crc := crc32.NewIEEE()
+	crc.Write(head[4:])
+	crc.Write(data)
+	tail := make([]byte, 4)
+	binary.BigEndian.PutUint32(tail, crc.Sum32())
+
+	for _, b := range [][]byte{head, data, tail} {
+		if _, err := w.Write(b); err != nil {
+			return fmt.Errorf("error writing animation: %v", err)
+		}
+	}
+	return nil
+}
+
+// frameDelay expresses the duration of one frame as a fraction of a second with 16 bit numerator and denominator.
+func frameDelay(frameRate float64) (uint16, uint16) {
+	if frameRate == math.Trunc(frameRate) && frameRate <= math.MaxUint16 {
+		return 1, uint16(frameRate)
+	}
+	return uint16(math.Round(1000 / frameRate)), 1000
+}
+
+// toRGBA returns the image as an *image.RGBA, converting it if necessary, so that every frame is encoded with the same colour type.
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba
+}
+
+// aviEncoder writes a Motion-JPEG AVI: every frame is a JPEG image in the movi list, followed by an idx1 index.
+// The header needs the frame size, so it is written with the first frame; the frame counts and sizes are patched on Close.
+type aviEncoder struct {
+	file        *os.File
+	w           *bufio.Writer
+	options     EncoderOptions
+	written     int64
+	moviOffset  int64 // file offset of the "movi" fourcc
+	index       []aviIndexEntry
+	width       int
+	height      int
+	maxFrameLen int
+}
+
+// aviIndexEntry locates one frame in the movi list.
+type aviIndexEntry struct {
+	offset uint32 // relative to the "movi" fourcc
+	size   uint32
+}
+
+// file offsets of the header fields patched on Close
+const (
+	aviRIFFSizeOffset    = 4
+	aviTotalFramesOffset = 48  // avih dwTotalFrames
+	aviAvihBufferOffset  = 60  // avih dwSuggestedBufferSize
+	aviStrhLengthOffset  = 140 // strh dwLength
+	aviStrhBufferOffset  = 144 // strh dwSuggestedBufferSize
+	aviMoviSizeOffset    = 216 // size of the movi LIST
+)
+
+func newAVIEncoder(output string, options EncoderOptions) (*aviEncoder, error) {
+	file, err := os.Create(output)
+	if err != nil {
+		return nil, fmt.Errorf("error creating animation file: %v", err)
+	}
+	return &aviEncoder{file: file, w: bufio.NewWriter(file), options: options}, nil
+}
+
+func (e *aviEncoder) WriteFrame(img image.Image) error {
+	bounds := img.Bounds()
+	if e.index == nil {
+		e.width, e.height = bounds.Dx(), bounds.Dy()
+		if err := e.writeHeader(); err != nil {
+			return err
+		}
+	} else if bounds.Dx() != e.width || bounds.Dy() != e.height {
+		return fmt.Errorf("frame %d is %dx%d, but the first frame was %dx%d", len(e.index), bounds.Dx(), bounds.Dy(), e.width, e.height)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: e.options.quality}); err != nil {
+		return fmt.Errorf("error encoding frame %d: %v", len(e.index), err)
+	}
+	frame := buf.Bytes()
+
+	e.index = append(e.index, aviIndexEntry{offset: uint32(e.written - e.moviOffset), size: uint32(len(frame))})
+	if len(frame) > e.maxFrameLen {
+		e.maxFrameLen = len(frame)
+	}
+	return e.writeChunk("00dc", frame)
+}
+
+// writeHeader writes the RIFF header, the hdrl list describing one MJPEG video stream and the start of the movi list.
+func (e *aviEncoder) writeHeader() error {
+	le := binary.LittleEndian
+	microSecPerFrame := uint32(math.Round(1e6 / e.options.frameRate))
+
+	avih := make([]byte, 56)
+	le.PutUint32(avih[0:], microSecPerFrame)
+	le.PutUint32(avih[12:], 0x10) // AVIF_HASINDEX
+	le.PutUint32(avih[24:], 1)    // streams
+	le.PutUint32(avih[32:], uint32(e.width))
+	le.PutUint32(avih[36:], uint32(e.height))
+
+	// frame rate as dwRate/dwScale
+	scale, rate := uint32(1000), uint32(math.Round(e.options.frameRate*1000))
+	strh := make([]byte, 56)
+	copy(strh[0:], "vids")
+	copy(strh[4:], "MJPG")
+	le.PutUint32(strh[20:], scale)
+	le.PutUint32(strh[24:], rate)
+	le.PutUint32(strh[40:], math.MaxUint32) // default quality
+	le.PutUint16(strh[52:], uint16(e.width))
+	le.PutUint16(strh[54:], uint16(e.height))
+
+	// BITMAPINFOHEADER
+	strf := make([]byte, 40)
+	le.PutUint32(strf[0:], 40)
+	le.PutUint32(strf[4:], uint32(e.width))
+	le.PutUint32(strf[8:], uint32(e.height))
+	le.PutUint16(strf[12:], 1)
+	le.PutUint16(strf[14:], 24)
+	copy(strf[16:], "MJPG")
+	le.PutUint32(strf[20:], uint32(e.width*e.height*3))
+
+	strl := concat([]byte("strl"), riffChunk("strh", strh), riffChunk("strf", strf))
+	hdrl := concat([]byte("hdrl"), riffChunk("avih", avih), riffChunk("LIST", strl))
+
+	// RIFF and movi sizes are patched on Close
+	if err := e.write(concat([]byte("RIFF"), make([]byte, 4), []byte("AVI "), riffChunk("LIST", hdrl), []byte("LIST"), make([]byte, 4))); err != nil {
+		return err
+	}
+	e.moviOffset = e.written
+	return e.write([]byte("movi"))
+}
+
+func (e *aviEncoder) Close() error {
+	defer e.file.Close()
+	if e.index == nil {
+		return fmt.Errorf("no frames were written")
+	}
+
+	moviSize := e.written - e.moviOffset
+	idx1 := make([]byte, 16*len(e.index))
+	for i, entry := range e.index {
+		copy(idx1[16*i:], "00dc")
+		binary.LittleEndian.PutUint32(idx1[16*i+4:], 0x10) // AVIIF_KEYFRAME
+		binary.LittleEndian.PutUint32(idx1[16*i+8:], entry.offset)
+		binary.LittleEndian.PutUint32(idx1[16*i+12:], entry.size)
+	}
+	if err := e.writeChunk("idx1", idx1); err != nil {
+		return err
+	}
+	if err := e.w.Flush(); err != nil {
+		return fmt.Errorf("error writing animation: %v", err)
+	}
+
+	patches := []struct {
+		offset int64
+		value  uint32
+	}{
+		{aviRIFFSizeOffset, uint32(e.written - 8)},
+		{aviTotalFramesOffset, uint32(len(e.index))},
+		{aviAvihBufferOffset, uint32(e.maxFrameLen + 8)},
+		{aviStrhLengthOffset, uint32(len(e.index))},
+		{aviStrhBufferOffset, uint32(e.maxFrameLen + 8)},
+		{aviMoviSizeOffset, uint32(moviSize)},
+	}
+	for _, p := range patches {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, p.value)
+		if _, err := e.file.WriteAt(b, p.offset); err != nil {
+			return fmt.Errorf("error finishing animation: %v", err)
+		}
+	}
+	return nil
+}
+
+func (e *aviEncoder) write(b []byte) error {
+	n, err := e.w.Write(b)
+	e.written += int64(n)
+	if err != nil {
+		return fmt.Errorf("error writing animation: %v", err)
+	}
+	return nil
+}
+
+func (e *aviEncoder) writeChunk(fourcc string, data []byte) error {
+	return e.write(riffChunk(fourcc, data))
+}
+
+// riffChunk returns a RIFF chunk: fourcc, little-endian size and data padded to an even length.
+func riffChunk(fourcc string, data []byte) []byte {
+	chunk := make([]byte, 8, 8+len(data)+1)
+	copy(chunk, fourcc)
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(data)))
+	chunk = append(chunk, data...)
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// concat joins byte slices.
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// ffmpegEncoder pipes raw RGBA frames to a local ffmpeg process, which is started when the first frame arrives.
+type ffmpegEncoder struct {
+	output  string
+	options EncoderOptions
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	width   int
+	height  int
+}
+
+func (e *ffmpegEncoder) WriteFrame(img image.Image) error {
+	rgba := toRGBA(img)
+	bounds := rgba.Bounds()
+
+	if e.cmd == nil {
+		e.width, e.height = bounds.Dx(), bounds.Dy()
+		if err := e.start(); err != nil {
+			return err
+		}
+	} else if bounds.Dx() != e.width || bounds.Dy() != e.height {
+		return fmt.Errorf("frame is %dx%d, but the first frame was %dx%d", bounds.Dx(), bounds.Dy(), e.width, e.height)
+	}
+
+	// write the rows one at a time in case the image is a sub-image with a larger stride
+	for y := 0; y < e.height; y++ {
+		start := y * rgba.Stride
+		if _, err := e.stdin.Write(rgba.Pix[start : start+4*e.width]); err != nil {
+			return fmt.Errorf("error sending frame to ffmpeg: %v", err)
+		}
+	}
+	return nil
+}
+
+// start launches ffmpeg reading raw frames from its standard input.
+func (e *ffmpegEncoder) start() error {
+	args := []string{
+		"-y", "-loglevel", "error",
+		"-f", "rawvideo", "-pix_fmt", "rgba",
+		"-s", strconv.Itoa(e.width) + "x" + strconv.Itoa(e.height),
+		"-r", strconv.FormatFloat(e.options.frameRate, 'f', -1, 64),
+		"-i", "-",
+	}
+	args = append(args, ffmpegCodecArgs(filepath.Ext(e.output), e.options.quality)...)
+	args = append(args, e.output)
+
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("error connecting to ffmpeg: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error starting ffmpeg (is it installed and on the PATH?): %v", err)
+	}
+	e.cmd, e.stdin = cmd, stdin
+	return nil
+}
+
+// ffmpegCodecArgs chooses the codec settings for an output extension, mapping quality 1-100 onto the codec's own scale.
+func ffmpegCodecArgs(ext string, quality int) []string {
+	// constant rate factor: 0 is lossless, 51 (x264) or 63 (vp9) is the worst
+	crf := func(worst int) string {
+		return strconv.Itoa(int(math.Round(float64(worst) * float64(100-quality) / 100)))
+	}
+
+	switch strings.ToLower(ext) {
+	case ".mp4", ".mkv", ".mov":
+		return []string{"-c:v", "libx264", "-pix_fmt", "yuv420p", "-crf", crf(51)}
+	case ".webm":
+		return []string{"-c:v", "libvpx-vp9", "-b:v", "0", "-crf", crf(63)}
+	case ".webp":
+		return []string{"-c:v", "libwebp", "-loop", "0", "-quality", strconv.Itoa(quality)}
+	default:
+		return nil
+	}
+}
+
+func (e *ffmpegEncoder) Close() error {
+	if e.cmd == nil {
+		return fmt.Errorf("no frames were written")
+	}
+	e.stdin.Close()
+	if err := e.cmd.Wait(); err != nil {
+		return fmt.Errorf("ffmpeg failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type NewFrameEncoderTest struct {
+	format  string
+	output  string
+	options EncoderOptions
+	fails   bool
+}
+
+type FrameDelayTest struct {
+	frameRate float64
+	num, den  uint16
+}
+
+// testFrame returns a w x h image filled with one shade of grey.
+func testFrame(w, h int, shade uint8) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = shade
+	}
+	return img
+}
+
+func TestNewFrameEncoder(t *testing.T) {
+	dir := t.TempDir()
+	good := EncoderOptions{frameRate: 10, quality: 90}
+	tests := []NewFrameEncoderTest{
+		{format: "gif", output: filepath.Join(dir, "a"), options: good},
+		{format: "APNG", output: filepath.Join(dir, "b"), options: good},
+		{format: "png", output: filepath.Join(dir, "c.png"), options: good},
+		{format: "avi", output: filepath.Join(dir, "d"), options: good},
+		{format: "mov", output: filepath.Join(dir, "e"), options: good, fails: true},
+		{format: "ffmpeg", output: filepath.Join(dir, "f"), options: good, fails: true},
+		{format: "apng", output: filepath.Join(dir, "g"), options: EncoderOptions{frameRate: 0, quality: 90}, fails: true},
+		{format: "avi", output: filepath.Join(dir, "h"), options: EncoderOptions{frameRate: 10, quality: 0}, fails: true},
+		{format: "avi", output: filepath.Join(dir, "i"), options: EncoderOptions{frameRate: 10, quality: 101}, fails: true},
+		{format: "apng", output: filepath.Join(dir, "missing", "j"), options: good, fails: true},
+	}
+
+	for _, test := range tests {
+		_, err := NewFrameEncoder(test.format, test.output, test.options)
+		if (err != nil) != test.fails {
+			t.Errorf("NewFrameEncoder(%q, %q, %+v) error = %v, want error %v", test.format, test.output, test.options, err, test.fails)
+		}
+	}
+}
+
+func TestFrameDelay(t *testing.T) {
+	tests := []FrameDelayTest{
+		{frameRate: 10, num: 1, den: 10},
+		{frameRate: 1, num: 1, den: 1},
+		{frameRate: 2.5, num: 400, den: 1000},
+		{frameRate: 0.5, num: 2000, den: 1000},
+	}
+	for _, test := range tests {
+		if num, den := frameDelay(test.frameRate); num != test.num || den != test.den {
+			t.Errorf("frameDelay(%v) = %d/%d, want %d/%d", test.frameRate, num, den, test.num, test.den)
+		}
+	}
+}
+
+// TestAPNGEncoder writes a three frame animation and checks its chunks after Close.
+func TestAPNGEncoder(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "flies")
+	encoder, err := NewFrameEncoder("apng", output, EncoderOptions{frameRate: 4, quality: 90})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := encoder.WriteFrame(testFrame(8, 6, uint8(80*i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encoder.WriteFrame(testFrame(6, 6, 0)); err == nil {
+		t.Errorf("a frame of another size was accepted")
+	}
+	if err := encoder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(output + ".png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := readPNGChunks(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every chunk has a valid CRC, including the patched acTL
+	for pos := 8; pos < len(data); {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		if crc32.ChecksumIEEE(data[pos+4:pos+8+length]) != binary.BigEndian.Uint32(data[pos+8+length:]) {
+			t.Errorf("chunk %s has a bad CRC", data[pos+4:pos+8])
+		}
+		pos += 12 + length
+	}
+
+	var kinds []string
+	var sequence []uint32
+	for _, chunk := range chunks {
+		kinds = append(kinds, chunk.kind)
+		switch chunk.kind {
+		case "acTL":
+			if frames := binary.BigEndian.Uint32(chunk.data); frames != 3 {
+				t.Errorf("acTL has %d frames, want 3", frames)
+			}
+		case "fcTL":
+			sequence = append(sequence, binary.BigEndian.Uint32(chunk.data))
+			if w, h := binary.BigEndian.Uint32(chunk.data[4:]), binary.BigEndian.Uint32(chunk.data[8:]); w != 8 || h != 6 {
+				t.Errorf("fcTL frame is %dx%d, want 8x6", w, h)
+			}
+			if num, den := binary.BigEndian.Uint16(chunk.data[20:]), binary.BigEndian.Uint16(chunk.data[22:]); num != 1 || den != 4 {
+				t.Errorf("fcTL delay is %d/%d, want 1/4", num, den)
+			}
+		case "fdAT":
+			sequence = append(sequence, binary.BigEndian.Uint32(chunk.data))
+		}
+	}
+	if len(kinds) < 4 || kinds[0] != "IHDR" || kinds[1] != "acTL" || kinds[2] != "fcTL" || kinds[len(kinds)-1] != "IEND" {
+		t.Errorf("APNG chunks are %v, want IHDR, acTL, fcTL first and IEND last", kinds)
+	}
+	for i, s := range sequence {
+		if s != uint32(i) {
+			t.Errorf("fcTL and fdAT sequence numbers are %v, want 0, 1, 2, ...", sequence)
+			break
+		}
+	}
+
+	// viewers without APNG support show the first frame
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("the animation is not a valid PNG: %v", err)
+	}
+	if r, g, b, a := img.At(0, 0).RGBA(); img.Bounds().Dx() != 8 || img.Bounds().Dy() != 6 || r|g|b|a != 0 {
+		t.Errorf("default image is %v with first pixel %v, want 8x6 of the first frame", img.Bounds(), img.At(0, 0))
+	}
+}
+
+// TestAVIEncoder writes a two frame Motion-JPEG AVI and checks the header fields patched on Close and the index.
+func TestAVIEncoder(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "flies.avi")
+	encoder, err := NewFrameEncoder("avi", output, EncoderOptions{frameRate: 5, quality: 75})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := encoder.WriteFrame(testFrame(16, 9, uint8(100*i+50))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encoder.WriteFrame(testFrame(9, 16, 0)); err == nil {
+		t.Errorf("a frame of another size was accepted")
+	}
+	if err := encoder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	le := binary.LittleEndian
+	u32 := func(offset int) uint32 { return le.Uint32(data[offset:]) }
+
+	if string(data[0:4]) != "RIFF" || string(data[8:12]) != "AVI " || int(u32(aviRIFFSizeOffset)) != len(data)-8 {
+		t.Errorf("RIFF header %q size %d, want RIFF....AVI  with size %d", data[:12], u32(aviRIFFSizeOffset), len(data)-8)
+	}
+	if string(data[24:28]) != "avih" || u32(32) != 200000 || u32(aviTotalFramesOffset) != 2 || u32(64) != 16 || u32(68) != 9 {
+		t.Errorf("avih has %d µs per frame, %d frames of %dx%d, want 200000, 2 of 16x9", u32(32), u32(aviTotalFramesOffset), u32(64), u32(68))
+	}
+	if string(data[100:104]) != "strh" || string(data[108:112]) != "vids" || string(data[112:116]) != "MJPG" || u32(aviStrhLengthOffset) != 2 {
+		t.Errorf("strh is %q %q with length %d, want vids MJPG with length 2", data[108:112], data[112:116], u32(aviStrhLengthOffset))
+	}
+	if u32(132) != 5000 || u32(128) != 1000 {
+		t.Errorf("strh rate %d/%d, want 5000/1000", u32(132), u32(128))
+	}
+	if u32(aviAvihBufferOffset) == 0 || u32(aviAvihBufferOffset) != u32(aviStrhBufferOffset) {
+		t.Errorf("suggested buffer sizes %d and %d, want the same non-zero size", u32(aviAvihBufferOffset), u32(aviStrhBufferOffset))
+	}
+
+	moviSize := int(u32(aviMoviSizeOffset))
+	movi := aviMoviSizeOffset + 4
+	if string(data[aviMoviSizeOffset-4:aviMoviSizeOffset]) != "LIST" || string(data[movi:movi+4]) != "movi" {
+		t.Fatalf("no movi list at byte %d", aviMoviSizeOffset-4)
+	}
+	idx1 := movi + moviSize
+	if string(data[idx1:idx1+4]) != "idx1" || u32(idx1+4) != 32 {
+		t.Fatalf("idx1 at byte %d is %q with size %d, want idx1 with 2 entries", idx1, data[idx1:idx1+4], u32(idx1+4))
+	}
+	for i := 0; i < 2; i++ {
+		entry := idx1 + 8 + 16*i
+		offset, size := int(u32(entry+8)), int(u32(entry+12))
+		frame := movi + offset
+		if string(data[entry:entry+4]) != "00dc" || string(data[frame:frame+4]) != "00dc" || int(u32(frame+4)) != size {
+			t.Errorf("index entry %d does not point to a frame of %d bytes", i, size)
+			continue
+		}
+		if data[frame+8] != 0xFF || data[frame+9] != 0xD8 {
+			t.Errorf("frame %d is not a JPEG image", i)
+		}
+	}
+}
+
+func TestPNGSequenceEncoder(t *testing.T) {
+	prefix := filepath.Join(t.TempDir(), "frame")
+	encoder, err := NewFrameEncoder("png", prefix+".png", EncoderOptions{frameRate: 10, quality: 90})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := encoder.WriteFrame(testFrame(4, 4, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"frame_00000.png", "frame_00001.png"} {
+		if _, err := os.Stat(filepath.Join(filepath.Dir(prefix), name)); err != nil {
+			t.Errorf("frame file %s was not written: %v", name, err)
+		}
+	}
+}
+
+func TestEncoderCloseWithoutFrames(t *testing.T) {
+	for _, format := range []string{"apng", "avi"} {
+		encoder, err := NewFrameEncoder(format, filepath.Join(t.TempDir(), "empty"), EncoderOptions{frameRate: 10, quality: 90})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := encoder.Close(); err == nil {
+			t.Errorf("closing an empty %s animation did not fail", format)
+		}
+	}
+}
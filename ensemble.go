@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// EnsembleRun is the outcome of one run of an ensemble: the state of the flies on the last day.
+type EnsembleRun struct {
+	scenario  string
+	replicate int
+	alive     int
+	cells     int     // raster cells with at least one living fly
+	area      float64 // area of those cells, km²
+	emigrated int     // flies that left the study region during the run
+
+	evaluated  bool
+	evaluation Evaluation // the run against the surveys, if evaluated
+}
+
+// RunEnsemble runs every scenario replicates times from the same start date for numYears. Runs seeded from a checkpoint start on
+// its date; startSet tells whether start was given explicitly, in which case it must agree (see StartDate).
+// Each replicate seeds one initial country (see InitializeCountry) that all scenarios start from, so the scenarios of a replicate are
+// paired and differ only in their weather. The spread on the last day is measured on a raster of cellSize degree cells covering the study region.
+// If validation is not nil, every run is also evaluated against its surveys (see Validation.EvaluateRun).
+func RunEnsemble(scenarios []Scenario, weather Weather, trees []Tree, hosts HostLayer, boundary BoundaryPolicy, movement StageMovement, reproduction Reproduction, mortality Mortality, detections []SampleData, seeding Seeding, validation *Validation, replicates, numYears int, start time.Time, startSet bool, step Timestep, region Region, cellSize float64) ([]EnsembleRun, error) {
+	if replicates <= 0 {
+		return nil, fmt.Errorf("the number of runs must be positive")
+	}
+
+	// load projected grids once, not for every replicate
+	weathers := make([]Weather, len(scenarios))
+	for i, s := range scenarios {
+		w, err := s.Weather(weather)
+		if err != nil {
+			return nil, err
+		}
+		weathers[i] = w
+	}
+
+	var runs []EnsembleRun
+	for r := 1; r <= replicates; r++ {
+		initialCountry, err := InitializeCountry(region, boundary, movement, reproduction, mortality, weather, trees, hosts, detections, seeding)
+		if err != nil {
+			return nil, err
+		}
+		first, err := StartDate(initialCountry, start, startSet)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, s := range scenarios {
+			clock := NewClock(first, step)
+			RegisterLifecycleEvents(clock)
+			end := clock.Start().AddDate(numYears, 0, 0)
+
+			// only the current state is kept, unlike SimulateMigration, along with the peak counts the validation needs
+			country := CopyCountry(initialCountry)
+			country.date = clock.Date()
+			var peak Raster
+			if validation != nil {
+				peak = NewRaster(validation.grid.bounds, validation.grid.cellSize, 0)
+			}
+			for {
+				if validation != nil && validation.Includes(country.date) {
+					counts := CountRaster(country, peak.bounds, peak.cellSize)
+					for j, v := range counts.values {
+						peak.values[j] = math.Max(peak.values[j], v)
+					}
+				}
+				if !clock.Date().Before(end) {
+					break
+				}
+				country = StepSimulation(country, weathers[i], clock)
+			}
+
+			run := EnsembleRun{scenario: s.name, replicate: r, alive: TakeCensus(country).alive, emigrated: country.emigrated}
+			if validation != nil {
+				evaluation, err := validation.grid.Evaluate(peak, validation.detectionProb)
+				if err != nil {
+					return nil, err
+				}
+				run.evaluated, run.evaluation = true, evaluation
+			}
+			counts := CountRaster(country, region.bounds, cellSize)
+			for row := 0; row < counts.rows; row++ {
+				for col := 0; col < counts.cols; col++ {
+					if counts.At(col, row) > 0 {
+						run.cells++
+						run.area += counts.CellArea(row)
+					}
+				}
+			}
+			runs = append(runs, run)
+			fmt.Printf("Run %d of %d, scenario %s: %d living flies in %d cells.\n", r, replicates, s.name, run.alive, run.cells)
+		}
+	}
+	return runs, nil
+}
+
+// EnsembleSummary summarises the runs of one scenario.
+type EnsembleSummary struct {
+	scenario           string
+	runs               int
+	aliveMean, aliveSD float64
+	areaMean, areaSD   float64
+	areaMin, areaMax   float64
+	areaChange         float64 // mean difference in area from the first scenario over the paired replicates, km²
+
+	evaluated            bool
+	tssMean              float64
+	logLikMean, logLikSD float64
+}
+
+// SummarizeEnsemble summarises the runs of each scenario, in the order of scenarios.
+// The change in area is measured against the first scenario, normally the baseline.
+func SummarizeEnsemble(runs []EnsembleRun, scenarios []Scenario) []EnsembleSummary {
+	reference := make(map[int]float64)
+	for _, run := range runs {
+		if run.scenario == scenarios[0].name {
+			reference[run.replicate] = run.area
+		}
+	}
+
+	summaries := make([]EnsembleSummary, 0, len(scenarios))
+	for _, s := range scenarios {
+		var alive, area, change, tss, logLik []float64
+		for _, run := range runs {
+			if run.scenario != s.name {
+				continue
+			}
+			alive = append(alive, float64(run.alive))
+			area = append(area, run.area)
+			change = append(change, run.area-reference[run.replicate])
+			if run.evaluated {
+				tss = append(tss, run.evaluation.TSS())
+				logLik = append(logLik, run.evaluation.logLikelihood)
+			}
+		}
+		if len(area) == 0 {
+			continue
+		}
+
+		summary := EnsembleSummary{scenario: s.name, runs: len(area)}
+		summary.aliveMean, summary.aliveSD = meanAndSD(alive)
+		summary.areaMean, summary.areaSD = meanAndSD(area)
+		summary.areaChange, _ = meanAndSD(change)
+		if len(logLik) > 0 {
+			summary.evaluated = true
+			summary.tssMean, _ = meanAndSD(tss)
+			summary.logLikMean, summary.logLikSD = meanAndSD(logLik)
+		}
+		sort.Float64s(area)
+		summary.areaMin, summary.areaMax = area[0], area[len(area)-1]
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// meanAndSD returns the mean and sample standard deviation of values. The deviation is 0 for fewer than two values.
+func meanAndSD(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return math.NaN(), math.NaN()
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)-1))
+}
+
+// PrintEnsembleSummary prints a table of the summaries.
+func PrintEnsembleSummary(summaries []EnsembleSummary) {
+	fmt.Printf("%-16s %5s %22s %28s %26s %18s\n", "scenario", "runs", "living flies", "occupied area (km²)", "area range (km²)", "change (km²)")
+	for _, s := range summaries {
+		fmt.Printf("%-16s %5d %10.0f ± %-9.0f %14.0f ± %-11.0f %12.0f - %-11.0f %+18.0f\n",
+			s.scenario, s.runs, s.aliveMean, s.aliveSD, s.areaMean, s.areaSD, s.areaMin, s.areaMax, s.areaChange)
+	}
+
+	if len(summaries) == 0 || !summaries[0].evaluated {
+		return
+	}
+	fmt.Printf("\n%-16s %8s %26s\n", "scenario", "TSS", "log-likelihood")
+	for _, s := range summaries {
+		fmt.Printf("%-16s %8.3f %14.1f ± %-9.1f\n", s.scenario, s.tssMean, s.logLikMean, s.logLikSD)
+	}
+}
+
+// WriteEnsembleCSV writes one row per run: scenario, replicate, alive, cells, area_km2 and emigrated, and for evaluated runs
+// the counts of true and false positives and negatives, tss and log_likelihood.
+func WriteEnsembleCSV(filename string, runs []EnsembleRun) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", filename, err)
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	w.Write([]string{"scenario", "replicate", "alive", "cells", "area_km2", "emigrated", "true_positives", "false_positives", "false_negatives", "true_negatives", "tss", "log_likelihood"})
+	for _, run := range runs {
+		row := []string{
+			run.scenario,
+			strconv.Itoa(run.replicate),
+			strconv.Itoa(run.alive),
+			strconv.Itoa(run.cells),
+			strconv.FormatFloat(run.area, 'f', 1, 64),
+			strconv.Itoa(run.emigrated),
+			"", "", "", "", "", "",
+		}
+		if e := run.evaluation; run.evaluated {
+			copy(row[6:], []string{
+				strconv.Itoa(e.truePositives),
+				strconv.Itoa(e.falsePositives),
+				strconv.Itoa(e.falseNegatives),
+				strconv.Itoa(e.trueNegatives),
+				strconv.FormatFloat(e.TSS(), 'f', 4, 64),
+				strconv.FormatFloat(e.logLikelihood, 'f', 2, 64),
+			})
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("error writing %s: %v", filename, err)
+	}
+	return nil
+}
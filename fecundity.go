@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Reproduction holds the rules for which adults lay eggs, and how many.
+//
+// Only females lay, and only once mated, so a female needs a living adult male within mateRadius km on some day of the season.
+// At low density few females find a mate, which gives an Allee effect that decides whether small or distant introductions establish.
+// A mateRadius of 0 needs no mate: every adult female lays.
+//
+// A mated female matures her first egg mass after preoviposition degree-days as an adult, and another every massDD degree-days
+// after that, up to maxMasses. She lays at most one mass a day, and only in the oviposition season, so her masses are spread
+// over several days. Each mass has 30 to 59 eggs, fewer as she ages (ageDecline is the share lost per 30 days as an adult) and
+// fewer if the host she feeds on is not Ailanthus (otherHosts is the fecundity on other hosts relative to Ailanthus).
+type Reproduction struct {
+	mateRadius     float64 // km
+	seasonFrom     int     // first day of the oviposition season, as month*100+day
+	seasonTo       int     // last day of the oviposition season, as month*100+day
+	preoviposition float64 // degree-days as an adult before the first egg mass
+	massDD         float64 // degree-days between egg masses
+	maxMasses      int     // most egg masses a female lays in a season
+	ageDecline     float64 // share of the eggs of a mass lost per 30 days as an adult
+	otherHosts     float64 // fecundity on hosts other than Ailanthus, relative to Ailanthus
+}
+
+// NewReproduction checks the rules of reproduction. season is the oviposition season as MM-DD:MM-DD, e.g. 09-01:11-30.
+func NewReproduction(mateRadius float64, season string, preoviposition, massDD float64, maxMasses int, ageDecline, otherHosts float64) (Reproduction, error) {
+	if mateRadius < 0 {
+		return Reproduction{}, fmt.Errorf("the mate radius cannot be negative")
+	}
+	from, to, err := ParseSeason(season)
+	if err != nil {
+		return Reproduction{}, err
+	}
+	if preoviposition < 0 || massDD <= 0 || maxMasses < 0 {
+		return Reproduction{}, fmt.Errorf("the preoviposition degree-days and most egg masses cannot be negative, and the degree-days between masses must be positive")
+	}
+	if ageDecline < 0 || ageDecline > 1 || otherHosts < 0 {
+		return Reproduction{}, fmt.Errorf("the fecundity lost with age must be from 0 to 1, and the fecundity on other hosts cannot be negative")
+	}
+	return Reproduction{
+		mateRadius:     mateRadius,
+		seasonFrom:     from,
+		seasonTo:       to,
+		preoviposition: preoviposition,
+		massDD:         massDD,
+		maxMasses:      maxMasses,
+		ageDecline:     ageDecline,
+		otherHosts:     otherHosts,
+	}, nil
+}
+
+// DefaultReproduction returns the rules of reproduction used when a country has none: mates within 0.1 km, an oviposition
+// season from July 1 to November 30, 60 degree-days before the first of up to two egg masses and 50 between them,
+// masses 20% smaller for every 30 days as an adult, and half the fecundity on hosts other than Ailanthus.
+// The adult stage lasts the 180 degree-days from instar4ToAdultThreshold to adultToDieThreshold, so the season opens when the first
+// adults emerge and both masses are matured well within it; the season closes on its last day, or earlier for a female that dies.
+func DefaultReproduction() Reproduction {
+	return Reproduction{mateRadius: 0.1, seasonFrom: 701, seasonTo: 1130, preoviposition: 60, massDD: 50, maxMasses: 2, ageDecline: 0.2, otherHosts: 0.5}
+}
+
+// ParseSeason reads a season of the year given as MM-DD:MM-DD, both days included, and returns its first and last day as
+// month*100+day. A season may run over the new year, e.g. 12-01:02-28.
+func ParseSeason(spec string) (int, int, error) {
+	from, to, found := strings.Cut(spec, ":")
+	if !found {
+		return 0, 0, fmt.Errorf("invalid season %q, expected MM-DD:MM-DD", spec)
+	}
+	var days [2]int
+	for i, s := range []string{from, to} {
+		// 2000 is a leap year, so February 29 parses
+		date, err := time.Parse("2006-01-02", "2000-"+strings.TrimSpace(s))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid season %q, expected MM-DD:MM-DD", spec)
+		}
+		days[i] = int(date.Month())*100 + date.Day()
+	}
+	return days[0], days[1], nil
+}
+
+// inSeason reports whether a date falls in the season from the day from to the day to, both given as month*100+day.
+func inSeason(date time.Time, from, to int) bool {
+	day := int(date.Month())*100 + date.Day()
+	if from <= to {
+		return day >= from && day <= to
+	}
+	return day >= from || day <= to
+}
+
+// MassesReady returns the number of egg masses a female has matured, from the degree-days she has accumulated as an adult.
+func (r Reproduction) MassesReady(fly Fly) int {
+	adultDD := fly.energy - instar4ToAdultThreshold
+	if adultDD < r.preoviposition {
+		return 0
+	}
+	masses := int((adultDD-r.preoviposition)/r.massDD) + 1
+	if masses > r.maxMasses {
+		return r.maxMasses
+	}
+	return masses
+}
+
+// HostFactor returns the fecundity of a female at a position relative to one feeding on Ailanthus: 1 on Ailanthus, and otherHosts
+// on the other species of a host layer that knows them (see HostSpecies). Hosts without a recorded species, and layers without
+// species such as rasters, count as Ailanthus, so a host file of unnamed points keeps its full fecundity.
+func (r Reproduction) HostFactor(hosts HostLayer, position OrderedPair) float64 {
+	layer, ok := hosts.(HostSpecies)
+	if !ok {
+		return 1
+	}
+	species := strings.ToLower(layer.SpeciesAt(position))
+	if species == "" || strings.Contains(species, "ailanthus") || strings.Contains(strings.ReplaceAll(species, "-", " "), "tree of heaven") {
+		return 1
+	}
+	return r.otherHosts
+}
+
+// AgeFactor returns the share of a full egg mass laid by a female that has been an adult for the given number of days.
+func (r Reproduction) AgeFactor(adultDays int) float64 {
+	return math.Pow(1-r.ageDecline, float64(adultDays)/30)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+type ParseSeasonTest struct {
+	spec     string
+	from, to int
+	fails    bool
+}
+
+type InSeasonTest struct {
+	day    string // YYYY-MM-DD
+	result bool
+}
+
+type MassesReadyTest struct {
+	energy float64
+	result int
+}
+
+func TestParseSeason(t *testing.T) {
+	tests := []ParseSeasonTest{
+		{spec: "09-01:11-30", from: 901, to: 1130},
+		{spec: " 07-01 : 11-30 ", from: 701, to: 1130},
+		{spec: "12-01:02-29", from: 1201, to: 229},
+		{spec: "09-01", fails: true},
+		{spec: "09-01:11-31", fails: true},
+		{spec: "13-01:11-30", fails: true},
+		{spec: "Sep-Nov", fails: true},
+	}
+
+	for _, test := range tests {
+		from, to, err := ParseSeason(test.spec)
+		if (err != nil) != test.fails {
+			t.Errorf("ParseSeason(%q) error = %v, want error %v", test.spec, err, test.fails)
+			continue
+		}
+		if from != test.from || to != test.to {
+			t.Errorf("ParseSeason(%q) = %d, %d, want %d, %d", test.spec, from, to, test.from, test.to)
+		}
+	}
+}
+
+func TestInSeason(t *testing.T) {
+	tests := map[string][]InSeasonTest{
+		"09-01:11-30": {
+			{day: "2022-09-01", result: true},
+			{day: "2022-10-15", result: true},
+			{day: "2022-11-30", result: true},
+			{day: "2022-08-31", result: false},
+			{day: "2022-12-01", result: false},
+		},
+		// over the new year
+		"12-01:02-28": {
+			{day: "2022-12-01", result: true},
+			{day: "2023-01-15", result: true},
+			{day: "2023-02-28", result: true},
+			{day: "2023-03-01", result: false},
+			{day: "2022-11-30", result: false},
+			{day: "2022-07-01", result: false},
+		},
+		"06-15:06-15": {
+			{day: "2022-06-15", result: true},
+			{day: "2022-06-16", result: false},
+		},
+	}
+
+	for season, cases := range tests {
+		from, to, err := ParseSeason(season)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, test := range cases {
+			day, err := ParseDate(test.day)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result := inSeason(day, from, to); result != test.result {
+				t.Errorf("inSeason(%s, %s) = %v, want %v", test.day, season, result, test.result)
+			}
+		}
+	}
+}
+
+func TestNewReproduction(t *testing.T) {
+	r, err := NewReproduction(0.1, "07-01:11-30", 60, 50, 2, 0.2, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != DefaultReproduction() {
+		t.Errorf("NewReproduction with the default rules = %+v, want %+v", r, DefaultReproduction())
+	}
+
+	for name, create := range map[string]func() (Reproduction, error){
+		"negative mate radius":    func() (Reproduction, error) { return NewReproduction(-1, "07-01:11-30", 60, 50, 2, 0.2, 0.5) },
+		"bad season":              func() (Reproduction, error) { return NewReproduction(0.1, "07-01", 60, 50, 2, 0.2, 0.5) },
+		"negative preoviposition": func() (Reproduction, error) { return NewReproduction(0.1, "07-01:11-30", -1, 50, 2, 0.2, 0.5) },
+		"no degree-days between masses": func() (Reproduction, error) {
+			return NewReproduction(0.1, "07-01:11-30", 60, 0, 2, 0.2, 0.5)
+		},
+		"negative masses":    func() (Reproduction, error) { return NewReproduction(0.1, "07-01:11-30", 60, 50, -1, 0.2, 0.5) },
+		"age decline over 1": func() (Reproduction, error) { return NewReproduction(0.1, "07-01:11-30", 60, 50, 2, 1.5, 0.5) },
+		"negative other hosts": func() (Reproduction, error) {
+			return NewReproduction(0.1, "07-01:11-30", 60, 50, 2, 0.2, -0.5)
+		},
+	} {
+		if _, err := create(); err == nil {
+			t.Errorf("NewReproduction with a %s did not fail", name)
+		}
+	}
+}
+
+// TestMassesReady checks that both masses of the default rules mature before the adult stage ends at adultToDieThreshold.
+func TestMassesReady(t *testing.T) {
+	r := DefaultReproduction()
+	tests := []MassesReadyTest{
+		{energy: 500, result: 0},
+		{energy: instar4ToAdultThreshold, result: 0},
+		{energy: instar4ToAdultThreshold + 59, result: 0},
+		{energy: instar4ToAdultThreshold + 60, result: 1},
+		{energy: instar4ToAdultThreshold + 109, result: 1},
+		{energy: instar4ToAdultThreshold + 110, result: 2},
+		{energy: adultToDieThreshold - 1, result: 2},
+		{energy: 2000, result: 2},
+	}
+
+	for _, test := range tests {
+		if result := r.MassesReady(Fly{energy: test.energy}); result != test.result {
+			t.Errorf("MassesReady(%v) = %d, want %d", test.energy, result, test.result)
+		}
+	}
+
+	if adultToDieThreshold != 800 {
+		t.Errorf("adultToDieThreshold = %v, want 800", adultToDieThreshold)
+	}
+	if stage := UpdateLifeStage(&Fly{energy: adultToDieThreshold - 1}); stage != 5 {
+		t.Errorf("UpdateLifeStage(%v) = %d, want 5", adultToDieThreshold-1, stage)
+	}
+	if stage := UpdateLifeStage(&Fly{energy: adultToDieThreshold}); stage != 6 {
+		t.Errorf("UpdateLifeStage(%v) = %d, want 6", adultToDieThreshold, stage)
+	}
+}
+
+func TestFecundityFactors(t *testing.T) {
+	r := DefaultReproduction()
+	for days, want := range map[int]float64{0: 1, 30: 0.8, 60: 0.64, 15: math.Sqrt(0.8)} {
+		if factor := r.AgeFactor(days); !closeTo(factor, want) {
+			t.Errorf("AgeFactor(%d) = %v, want %v", days, factor, want)
+		}
+	}
+
+	hosts := NewTreePoints([]Tree{
+		{position: OrderedPair{-80, 40}, species: "Ailanthus altissima"},
+		{position: OrderedPair{-78, 40}, species: "Tree-of-heaven"},
+		{position: OrderedPair{-76, 40}, species: "Acer rubrum"},
+		{position: OrderedPair{-74, 40}},
+	})
+	for x, want := range map[float64]float64{-80: 1, -78: 1, -76: 0.5, -74: 1} {
+		if factor := r.HostFactor(hosts, OrderedPair{x, 40}); factor != want {
+			t.Errorf("HostFactor(%v, 40) = %v, want %v", x, factor, want)
+		}
+	}
+	raster := SuitabilityRaster{raster: NewRaster(Bounds{minLon: -80, minLat: 40, maxLon: -79, maxLat: 41}, 1, 0)}
+	if factor := r.HostFactor(raster, OrderedPair{-79.5, 40.5}); factor != 1 {
+		t.Errorf("HostFactor of a raster = %v, want 1", factor)
+	}
+}
+
+// TestComputeFecundity checks that a female lays one mass a day, only in the season and only the masses she has matured.
+func TestComputeFecundity(t *testing.T) {
+	r := DefaultReproduction()
+	position := OrderedPair{-76, 40}
+	ailanthus := NewTreePoints([]Tree{{position: position, species: "Ailanthus altissima"}})
+	maple := NewTreePoints([]Tree{{position: position, species: "Acer rubrum"}})
+	female := Fly{position: position, stage: 5, isAlive: true, female: true, mated: true, energy: instar4ToAdultThreshold + 120}
+
+	if eggs := ComputeFecundity(&female, date(2022, 6, 30), ailanthus, r); len(eggs) != 0 || female.eggMasses != 0 {
+		t.Errorf("ComputeFecundity before the season laid %d eggs", len(eggs))
+	}
+	for day := 1; day <= 3; day++ {
+		eggs := ComputeFecundity(&female, date(2022, 9, day), ailanthus, r)
+		switch {
+		case day <= 2 && (len(eggs) < 30 || len(eggs) > 59):
+			t.Errorf("ComputeFecundity on day %d laid %d eggs, want a mass of 30 to 59", day, len(eggs))
+		case day == 3 && len(eggs) != 0:
+			t.Errorf("ComputeFecundity laid %d eggs after both masses", len(eggs))
+		}
+		for _, egg := range eggs {
+			if egg.position != position || egg.stage != 0 || !egg.isAlive || egg.mated {
+				t.Fatalf("ComputeFecundity laid %+v", egg)
+			}
+		}
+	}
+	if female.eggMasses != 2 {
+		t.Errorf("female laid %d masses, want 2", female.eggMasses)
+	}
+
+	young := Fly{position: position, stage: 5, isAlive: true, female: true, mated: true, energy: instar4ToAdultThreshold + 10}
+	if eggs := ComputeFecundity(&young, date(2022, 9, 1), ailanthus, r); len(eggs) != 0 {
+		t.Errorf("ComputeFecundity before the preoviposition laid %d eggs", len(eggs))
+	}
+
+	// on a maple she lays half as many, and 60 days as an adult costs another 36%
+	old := female
+	old.eggMasses, old.adultDays = 0, 60
+	eggs := ComputeFecundity(&old, date(2022, 9, 1), maple, r)
+	if min, max := int(math.Round(30*0.5*0.64)), int(math.Round(59*0.5*0.64)); len(eggs) < min || len(eggs) > max {
+		t.Errorf("ComputeFecundity of an old female on a maple laid %d eggs, want %d to %d", len(eggs), min, max)
+	}
+}
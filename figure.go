@@ -0,0 +1,90 @@
+package main
+
+import (
+	"canvas"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExportFigure writes a resolution-independent map of the simulation to an SVG or PDF file, chosen by the extension of filename.
+// One panel is drawn for each date, laid out in rows of the given number of columns; a single date gives a snapshot.
+// Each panel shows the first time point on or after its date and is panelSize pixels (points in a PDF) square.
+// If dates is empty, the last time point is drawn.
+func ExportFigure(filename string, timePoints []Country, dates []time.Time, basemap Basemap, settings RenderSettings, panelSize, columns int) error {
+	if len(timePoints) == 0 {
+		return fmt.Errorf("there are no time points to draw")
+	}
+	if panelSize <= 0 || columns <= 0 {
+		return fmt.Errorf("panel size and number of columns must be positive")
+	}
+	if len(dates) == 0 {
+		dates = []time.Time{timePoints[len(timePoints)-1].date}
+	}
+	sorted := append([]time.Time(nil), dates...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+
+	if len(sorted) < columns {
+		columns = len(sorted)
+	}
+	rows := (len(sorted) + columns - 1) / columns
+	width, height := columns*panelSize, rows*panelSize
+
+	var drawer canvas.Drawer
+	var save func() error
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".svg":
+		svg := canvas.CreateNewSVGCanvas(width, height)
+		drawer, save = svg, func() error { return svg.SaveToSVG(filename) }
+	case ".pdf":
+		pdf := canvas.CreateNewPDFCanvas(width, height)
+		drawer, save = pdf, func() error { return pdf.SaveToPDF(filename) }
+	default:
+		return fmt.Errorf("unknown figure format %q (want a .svg or .pdf file)", filepath.Ext(filename))
+	}
+
+	// panels draw their own background, this covers the empty cells of the last row
+	drawer.SetFillColor(canvas.MakeColor(0, 0, 0))
+	drawer.Rect(0, 0, float64(width), float64(height))
+	drawer.Fill()
+
+	// the occupancy and arrival modes need every time point up to the one drawn
+	history := NewRenderHistory(settings, basemap.quadrants, basemap.bounds)
+	next := 0
+	for _, country := range timePoints {
+		history.Add(country)
+		for next < len(sorted) && !country.date.Before(sorted[next]) {
+			x := float64(next%columns) * float64(panelSize)
+			y := float64(next/columns) * float64(panelSize)
+			panel := canvas.NewPanel(drawer, x, y, panelSize, panelSize)
+			if err := DrawFrame(panel, country, basemap, settings, history); err != nil {
+				return err
+			}
+			next++
+		}
+	}
+	if next < len(sorted) {
+		return fmt.Errorf("the simulation ends on %s, before %s", timePoints[len(timePoints)-1].date.Format(dateLayout), sorted[next].Format(dateLayout))
+	}
+
+	return save()
+}
+
+// ParseDates parses a comma-separated list of YYYY-MM-DD dates. An empty list gives no dates.
+func ParseDates(list string) ([]time.Time, error) {
+	var dates []time.Time
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		date, err := ParseDate(field)
+		if err != nil {
+			return nil, err
+		}
+		dates = append(dates, date)
+	}
+	return dates, nil
+}
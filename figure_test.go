@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type ParseDatesTest struct {
+	list   string
+	result []time.Time
+	fails  bool
+}
+
+type ExportFigureTest struct {
+	filename  string
+	dates     []time.Time
+	panelSize int
+	columns   int
+	fails     bool
+}
+
+func TestParseDates(t *testing.T) {
+	tests := []ParseDatesTest{
+		{list: ""},
+		{list: " , "},
+		{list: "2021-08-01", result: []time.Time{date(2021, 8, 1)}},
+		{list: "2021-08-01, 2022-08-01,", result: []time.Time{date(2021, 8, 1), date(2022, 8, 1)}},
+		{list: "2021-08-01,August 2022", fails: true},
+	}
+
+	for _, test := range tests {
+		result, err := ParseDates(test.list)
+		if (err != nil) != test.fails {
+			t.Errorf("ParseDates(%q) error = %v, want error %v", test.list, err, test.fails)
+			continue
+		}
+		if len(result) != len(test.result) {
+			t.Errorf("ParseDates(%q) = %v, want %v", test.list, result, test.result)
+			continue
+		}
+		for i := range result {
+			if !result[i].Equal(test.result[i]) {
+				t.Errorf("ParseDates(%q) = %v, want %v", test.list, result, test.result)
+				break
+			}
+		}
+	}
+}
+
+// testFigure returns two time points a year apart and a basemap with one state for them.
+func testFigure(t *testing.T) ([]Country, Basemap, RenderSettings) {
+	states := writeTestFile(t, "states.geojson", `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{"name":"Square"},
+		"geometry":{"type":"Polygon","coordinates":[[[-80,40],[-78,40],[-78,42],[-80,42],[-80,40]]]}}]}`)
+	basemap, err := NewBasemap("Test", states, Bounds{minLon: -81, minLat: 39, maxLon: -77, maxLat: 43}, "albers", nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings, err := NewRenderSettings("points", "viridis", 0.25, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timePoints := []Country{
+		{date: date(2021, 8, 1), flies: []Fly{{position: OrderedPair{-79, 41}, stage: 5, isAlive: true}}},
+		{date: date(2022, 8, 1), flies: []Fly{{position: OrderedPair{-79, 41}, stage: 5, isAlive: true}, {position: OrderedPair{-78.5, 41.5}, stage: 1, isAlive: true}}},
+	}
+	return timePoints, basemap, settings
+}
+
+func TestExportFigure(t *testing.T) {
+	timePoints, basemap, settings := testFigure(t)
+	dir := t.TempDir()
+	tests := []ExportFigureTest{
+		{filename: filepath.Join(dir, "last.svg"), panelSize: 300, columns: 2},
+		{filename: filepath.Join(dir, "panels.svg"), dates: []time.Time{date(2022, 1, 1), date(2021, 6, 1)}, panelSize: 300, columns: 2},
+		{filename: filepath.Join(dir, "panels.pdf"), dates: []time.Time{date(2021, 6, 1), date(2022, 1, 1), date(2022, 8, 1)}, panelSize: 200, columns: 2},
+		{filename: filepath.Join(dir, "late.svg"), dates: []time.Time{date(2023, 1, 1)}, panelSize: 300, columns: 2, fails: true},
+		{filename: filepath.Join(dir, "map.png"), panelSize: 300, columns: 2, fails: true},
+		{filename: filepath.Join(dir, "empty.svg"), panelSize: 0, columns: 2, fails: true},
+	}
+
+	for _, test := range tests {
+		err := ExportFigure(test.filename, timePoints, test.dates, basemap, settings, test.panelSize, test.columns)
+		if (err != nil) != test.fails {
+			t.Errorf("ExportFigure(%s) error = %v, want error %v", filepath.Base(test.filename), err, test.fails)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "late.svg")); err == nil {
+		t.Errorf("ExportFigure wrote a file for a date after the end of the run")
+	}
+	if err := ExportFigure(filepath.Join(dir, "none.svg"), nil, nil, basemap, settings, 300, 1); err == nil {
+		t.Errorf("ExportFigure without time points did not fail")
+	}
+}
+
+// TestExportFigureSVG checks that the SVG figure is well-formed XML as wide and high as its panels.
+func TestExportFigureSVG(t *testing.T) {
+	timePoints, basemap, settings := testFigure(t)
+	filename := filepath.Join(t.TempDir(), "figure.svg")
+	if err := ExportFigure(filename, timePoints, []time.Time{date(2021, 8, 1), date(2022, 8, 1), date(2022, 8, 1)}, basemap, settings, 250, 2); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var root *xml.StartElement
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("the SVG figure is not well-formed: %v", err)
+		}
+		if start, ok := token.(xml.StartElement); ok && root == nil {
+			root = &start
+		}
+	}
+	if root == nil || root.Name.Local != "svg" {
+		t.Fatalf("the SVG figure has no svg element")
+	}
+	attributes := make(map[string]string)
+	for _, a := range root.Attr {
+		attributes[a.Name.Local] = a.Value
+	}
+	// three panels in rows of two
+	if attributes["width"] != "500" || attributes["height"] != "500" {
+		t.Errorf("SVG figure is %s x %s, want 500 x 500", attributes["width"], attributes["height"])
+	}
+}
+
+// TestExportFigurePDF checks the cross-reference table of the PDF figure.
+func TestExportFigurePDF(t *testing.T) {
+	timePoints, basemap, settings := testFigure(t)
+	filename := filepath.Join(t.TempDir(), "figure.pdf")
+	if err := ExportFigure(filename, timePoints, nil, basemap, settings, 300, 1); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatalf("the PDF figure does not start with %%PDF-1.4 and end with %%%%EOF")
+	}
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(data)
+	if startxref == nil {
+		t.Fatalf("the PDF figure has no startxref")
+	}
+	xref, _ := strconv.Atoi(string(startxref[1]))
+	if !bytes.HasPrefix(data[xref:], []byte("xref\n0 ")) {
+		t.Fatalf("startxref %d does not point to the xref table", xref)
+	}
+
+	lines := strings.Split(string(data[xref:]), "\n")
+	count, _ := strconv.Atoi(strings.Fields(lines[1])[1])
+	if count < 4 {
+		t.Fatalf("the xref table has %d entries, want at least 4", count)
+	}
+	for i := 1; i < count; i++ {
+		offset, err := strconv.Atoi(strings.Fields(lines[2+i])[0])
+		if err != nil || !bytes.HasPrefix(data[offset:], []byte(strconv.Itoa(i)+" 0 obj\n")) {
+			t.Errorf("xref entry %d does not point to object %d", i, i)
+		}
+	}
+	if !bytes.Contains(data, []byte("/MediaBox [0 0 300 300]")) {
+		t.Errorf("the PDF page is not 300 x 300 points")
+	}
+}
@@ -7,71 +7,176 @@ import (
 	"math"
 	"math/rand"
 	"runtime"
+	"time"
 )
 
 // SimulateMigration simulates the migration of flies across the country over multiple years.
-// The drawPoints array keeps track of the state of the country at the end of each day.
-// In each year, the flies go through their lifecycle, with adults laying eggs and other stages changing over time.
-// During the winter months, all flies die, except for the eggs laid by the adults.
-// The simulation updates the state of the country and appends it to the drawPoints array for each day.
-// Finally, the drawPoints array is returned, representing the state of the country at the end of each day for all years.
-func SimulateMigration(initialCountry Country, numYears int, weather Weather) []Country {
+// The drawPoints array keeps track of the state of the country at the end of each tick of the clock.
+// Every tick is computed by StepSimulation, which updates the flies once per day and applies the phenology events registered on the clock.
+// Finally, the drawPoints array is returned, representing the state of the country at the end of each tick for all years.
+func SimulateMigration(initialCountry Country, numYears int, weather Weather, clock *Clock) []Country {
 	drawPoints := make([]Country, 0)
-	drawPoints = append(drawPoints, initialCountry)
+
 	currentCountry := CopyCountry(initialCountry)
-	finalState := currentCountry
-	for year := 0; year <= numYears; year++ {
-		var totalEggs []Fly
-		for i := 1; i <= 365; i++ {
-			var eggs []Fly
-			finalState = UpdateCountry(currentCountry, weather)
-			// if adult, lay eggs
-			// collect all eggs
-			if finalState.flies[i].stage == 5 {
-				eggs = ComputeFecundity(finalState.flies[i])
-				totalEggs = append(totalEggs, eggs...)
-			}
+	currentCountry.date = clock.Date()
+	drawPoints = append(drawPoints, currentCountry)
 
-			drawPoints = append(drawPoints, finalState)
+	end := clock.Start().AddDate(numYears, 0, 0)
+	for clock.Date().Before(end) {
+		currentCountry = StepSimulation(currentCountry, weather, clock)
+		drawPoints = append(drawPoints, currentCountry)
+	}
+	return drawPoints
+}
 
-			if i > 214 { // Days from May to Dec
-				for i := range finalState.flies {
-					if finalState.flies[i].isAlive == true && finalState.flies[i].stage != 0 {
-						finalState.flies[i].isAlive = false
-					}
-				}
+// StepSimulation advances the country by one tick of the clock and returns the new state.
+// The clock decides how many days one tick covers; within a tick the flies are updated once per day.
+// Adult females mate (see MateFlies) and then lay egg masses in the oviposition season, which are held back until the hatch event, and the phenology events registered on the clock
+// (see RegisterLifecycleEvents) are applied at the end of the tick in which they fall.
+// The distance every living fly moved on every day of the tick is kept with the new state under the stage it moved in, for MeasureDisplacement.
+func StepSimulation(currentCountry Country, weather Weather, clock *Clock) Country {
+	var moves [6][]float64
+	hosts := currentCountry.Hosts()
+	for d := 0; d < clock.StepDays(); d++ {
+		currentCountry = UpdateCountry(currentCountry, weather.On(currentCountry.date.AddDate(0, 0, d)), hosts)
+		for _, fly := range currentCountry.flies {
+			if fly.isAlive && fly.movedStage >= 0 && fly.movedStage <= 5 {
+				moves[fly.movedStage] = append(moves[fly.movedStage], fly.moved)
 			}
+		}
 
+		// adult females that meet a male mate
+		reproduction := currentCountry.Reproduction()
+		MateFlies(currentCountry.flies, reproduction.mateRadius)
+
+		// eggs waiting to hatch age as well, and die only by intervention
+		day := weather.On(currentCountry.date.AddDate(0, 0, d))
+		mortality := currentCountry.Mortality()
+		pending := currentCountry.eggs[:0]
+		for _, egg := range currentCountry.eggs {
+			egg.age++
+			if mortality.intervention == nil {
+				pending = append(pending, egg)
+				continue
+			}
+			if cause := ComputeMortality(&egg, day, 0, 1, mortality); cause != CauseNone {
+				currentCountry.deaths[cause]++
+				continue
+			}
+			pending = append(pending, egg)
 		}
-		// collect all eggs
-		currentCountry = finalState
+		currentCountry.eggs = pending
 
-		if CheckDead(finalState.flies) {
-			finalState.flies = totalEggs
+		// if mated adult female, lay the egg masses she has ready, one a day
+		// collect all eggs
+		date := currentCountry.date.AddDate(0, 0, d)
+		for i := range currentCountry.flies {
+			fly := &currentCountry.flies[i]
+			if fly.isAlive && fly.stage == 5 && fly.female && fly.mated {
+				eggs := ComputeFecundity(fly, date, hosts, reproduction)
+				currentCountry.eggs = append(currentCountry.eggs, eggs...)
+			}
 		}
 	}
-	return drawPoints
+
+	events := clock.Advance()
+	currentCountry.date = clock.Date()
+	currentCountry.moves = moves
+	for _, e := range events {
+		e.event.hook(&currentCountry, e.date)
+	}
+
+	return currentCountry
+}
+
+// RegisterLifecycleEvents registers the yearly events of the lantern fly life cycle on the clock.
+// On December 1 the first hard frost kills every fly that is not an egg.
+// On May 1 the eggs laid during the previous season hatch, replacing the dead flies.
+func RegisterLifecycleEvents(clock *Clock) {
+	clock.On("winter", time.December, 1, func(country *Country, date time.Time) {
+		for i := range country.flies {
+			if country.flies[i].isAlive && country.flies[i].stage != 0 {
+				Kill(&country.flies[i], CauseCold, date)
+				country.deaths[CauseCold]++
+			}
+		}
+	})
+
+	clock.On("hatch", time.May, 1, func(country *Country, date time.Time) {
+		survivors := make([]Fly, 0, len(country.flies)+len(country.eggs))
+		for _, fly := range country.flies {
+			if fly.isAlive {
+				survivors = append(survivors, fly)
+			}
+		}
+		country.flies = append(survivors, country.eggs...)
+		country.eggs = nil
+	})
 }
 
-// UpdateCountry takes a current country and weather data as parameters,
-// creates a new copy of the country, updates the fly population in parallel based on the weather data,
-// and returns the updated country.
-func UpdateCountry(currentCountry Country, weather Weather) Country {
+// UpdateCountry takes a current country, weather data and the country's host layer (see Country.Hosts) as parameters,
+// creates a new copy of the country, updates the fly population in parallel based on the weather data and the host layer,
+// counts the flies that left the study region or died, and returns the updated country.
+func UpdateCountry(currentCountry Country, weather Weather, hosts HostLayer) Country {
 	newcountry := CopyCountry(currentCountry) //copy current country
 
 	numProcs := runtime.NumCPU() //get number of CPUs
 
 	// update flies
-	UpdateFlyMultiProcs(newcountry.flies, weather, newcountry.trees, numProcs)
+	UpdateFlyMultiProcs(newcountry.flies, weather, hosts, newcountry.boundary, newcountry.Movement(), newcountry.Mortality(), numProcs)
+
+	// the flies are in the same order as before the update
+	for i, fly := range newcountry.flies {
+		if fly.emigrated && !currentCountry.flies[i].emigrated {
+			newcountry.emigrated++
+		}
+		if !fly.isAlive && currentCountry.flies[i].isAlive && fly.cause != CauseNone {
+			newcountry.deaths[fly.cause]++
+		}
+	}
 
 	return newcountry
 }
 
+// Movement returns the movement rules of the country, or DefaultMovement if it has none.
+func (country Country) Movement() StageMovement {
+	if country.movement.adult == nil {
+		return DefaultMovement()
+	}
+	return country.movement
+}
+
+// Mortality returns the mortality rules of the country, or DefaultMortality if it has none.
+func (country Country) Mortality() Mortality {
+	if !country.mortality.set {
+		return DefaultMortality()
+	}
+	return country.mortality
+}
+
+// Reproduction returns the reproduction rules of the country, or DefaultReproduction if it has none.
+func (country Country) Reproduction() Reproduction {
+	if country.reproduction.seasonTo == 0 {
+		return DefaultReproduction()
+	}
+	return country.reproduction
+}
+
+// Hosts returns the host layer of the country, or its trees as points if it has none.
+// The trees are then sorted into a new grid on every call (see NewTreePoints), so StepSimulation gets the layer once per tick
+// and hands it to every day of the tick.
+func (country Country) Hosts() HostLayer {
+	if country.hosts == nil {
+		return NewTreePoints(country.trees)
+	}
+	return country.hosts
+}
+
 // UpdateFlyMultiProcs updates the flies in parallel
 // takes a slice of flies and a number of processors.
 // It divides the slice of flies into approximately equal parts, and sends each part to a separate goroutine for processing.
 // It uses a finished channel to wait for all the goroutines to finish.
-func UpdateFlyMultiProcs(fly []Fly, weather Weather, trees []Tree, numProcs int) {
+func UpdateFlyMultiProcs(fly []Fly, weather Weather, hosts HostLayer, boundary BoundaryPolicy, movement StageMovement, mortality Mortality, numProcs int) {
 	numFlies := len(fly)
 
 	finished := make(chan bool)
@@ -82,7 +187,7 @@ func UpdateFlyMultiProcs(fly []Fly, weather Weather, trees []Tree, numProcs int)
 		startIndex := i * numFlies / numProcs
 		endIndex := (i + 1) * numFlies / numProcs
 
-		go UpdateFlySingleProc(fly[startIndex:endIndex], weather, trees, finished)
+		go UpdateFlySingleProc(fly[startIndex:endIndex], weather, hosts, boundary, movement, mortality, finished)
 	}
 
 	for i := 0; i < numProcs; i++ {
@@ -91,84 +196,99 @@ func UpdateFlyMultiProcs(fly []Fly, weather Weather, trees []Tree, numProcs int)
 
 }
 
-// UpdateFlySingleProc takes a slice of Fly instances, a Weather instance, a slice of Tree instances, and a finished channel as input.
+// UpdateFlySingleProc takes a slice of Fly instances, a Weather instance, a HostLayer, a BoundaryPolicy, the StageMovement rules, the Mortality rules, and a finished channel as input.
 // The function iterates over the fly slice using a for loop and range function.
-// Inside the loop, it calls the UpdateFly function with the current Fly instance, Weather, and Tree slices as arguments.
+// Inside the loop, it calls the UpdateFly function with the current Fly instance and the rest of the arguments.
 // After the loop, the function sends a value through the finished channel to signal that the update process is finished.
-func UpdateFlySingleProc(fly []Fly, weather Weather, trees []Tree, finished chan bool) {
+func UpdateFlySingleProc(fly []Fly, weather Weather, hosts HostLayer, boundary BoundaryPolicy, movement StageMovement, mortality Mortality, finished chan bool) {
 	for i := range fly {
-		fly[i] = UpdateFly(fly[i], weather, trees)
+		fly[i] = UpdateFly(fly[i], weather, hosts, boundary, movement, mortality)
 	}
 	finished <- true
 }
 
-// UpdateFly takes a fly, weather, and trees as parameters.
+// UpdateFly takes a fly, weather, the host layer, the boundary policy, the movement rules and the mortality rules as parameters.
+// Dead flies stay as they died, with their cause and day of death.
 // It updates the fly's energy, position, life stage, and determines if the fly is alive or not.
+// A living fly that moves out of the study region is kept in by the boundary policy, or emigrates and is no longer alive;
+// emigrated flies are left unchanged from then on.
+// The chance of survival scales with the host suitability where the fly ends up.
+// Living flies also grow one day older.
 // The updated fly is then returned.
-func UpdateFly(fly Fly, weather Weather, trees []Tree) Fly {
-	// Compute degree-day to affect fly's energy
-	fly.energy = ComputeDegreeDay(&fly, weather)
-
-	// Compute movement based on fly's energy and tree locations
-	fly.position = ComputeMovement(&fly, trees)
+func UpdateFly(fly Fly, weather Weather, hosts HostLayer, boundary BoundaryPolicy, movement StageMovement, mortality Mortality) Fly {
+	if fly.emigrated || !fly.isAlive {
+		return fly
+	}
+	fly.age++
+
+	// Accumulate the degree-days of the day in the fly's energy
+	degreeDays := ComputeDegreeDay(&fly, weather)
+	fly.energy += degreeDays
+
+	// Move the fly as its stage does, and record how far it went
+	start := fly.position
+	fly.position = movement.Move(&fly, hosts, weather)
+	fly.moved = distanceKm(start, fly.position)
+	fly.movedStage = fly.stage
+
+	// Apply the boundary policy to flies that left the study region
+	position, inside := boundary.Apply(fly.position)
+	fly.position = position
+	if !inside {
+		fly.isAlive = false
+		fly.emigrated = true
+		return fly
+	}
 
 	// Update fly's life stage based on age and conditions
 	fly.stage = UpdateLifeStage(&fly)
+	if fly.stage == 5 {
+		fly.adultDays++
+	}
 
 	// Check if fly has died based on its current condition
-	fly.isAlive = ComputeMortality(&fly)
+	if cause := ComputeMortality(&fly, weather, degreeDays, hosts.Suitability(fly.position), mortality); cause != CauseNone {
+		Kill(&fly, cause, weather.date)
+	}
 
 	return fly
 }
 
-// phiE calculates the calculates the difference between two terms of a function, returning the absolute value of their difference.
-// It defines a constant value 'k' and calculates the two terms of the function using 'math.Exp'.
-// Finally, it returns the absolute difference between the two terms.
-func phiE(d float64) float64 {
-	// Define the constant value
-	const k = 0.012
-
-	// Calculate each term of the function
-	term1 := 1 / (k*math.Exp(d-1) - 1)
-	term2 := 1 / (k*math.Exp(d) - 1)
-
-	// Return the absolute difference
-	return math.Abs(term1 - term2)
-}
-
-// ComputeFecundity computes the fecundity of a fly.
-// The function takes a fly as an argument and returns a slice of new flies that result from the laying of eggs.
+// ComputeFecundity computes the fecundity of a fly on one day.
+// The function takes a mated female as an argument and returns a slice of new flies that result from the laying of eggs.
 // The code generates a new egg fly at the location of the adult fly.
-// The number of egg masses, number of eggs in each mass, and the total number of eggs are randomly determined.
-// The probability of laying eggs is determined by the fly's energy level.
-// females lay one or two egg masses, each containing 30-60 eggs
-func ComputeFecundity(fly Fly) []Fly {
+// She lays one egg mass on a day of the oviposition season if she has matured more masses than she has laid (see Reproduction.MassesReady),
+// and no eggs otherwise.
+// A mass has 30-59 eggs, scaled by her age, the host species she feeds on and the host suitability at her position,
+// and each egg is female with probability femaleShare.
+func ComputeFecundity(fly *Fly, date time.Time, hosts HostLayer, reproduction Reproduction) []Fly {
 	newFly := make([]Fly, 0)
 
-	probToLayEggs := phiE(fly.energy)
-
-	if rand.Float64() > probToLayEggs {
-		// randomly choose the number of egg masses
-		numEggMasses := rand.Intn(2) + 1
-
-		// randomly choose the number of eggs in each egg mass
-		numEggs := rand.Intn(30) + 30
-
-		totalEggs := numEggMasses * numEggs
-
-		// location of the eggs is the location of the adult
-		for i := 0; i < totalEggs; i++ {
-			newEgg := Fly{
-				position: OrderedPair{
-					x: fly.position.x,
-					y: fly.position.y,
-				},
-				stage:   0,
-				energy:  0,
-				isAlive: true,
-			}
-			newFly = append(newFly, newEgg)
+	if !inSeason(date, reproduction.seasonFrom, reproduction.seasonTo) || fly.eggMasses >= reproduction.MassesReady(*fly) {
+		return newFly
+	}
+	fly.eggMasses++
+
+	// randomly choose the number of eggs in the egg mass
+	numEggs := rand.Intn(30) + 30
+
+	scale := reproduction.AgeFactor(fly.adultDays) * reproduction.HostFactor(hosts, fly.position) * hosts.Suitability(fly.position)
+	totalEggs := int(math.Round(float64(numEggs) * scale))
+
+	// location of the eggs is the location of the adult
+	for i := 0; i < totalEggs; i++ {
+		newEgg := Fly{
+			position: OrderedPair{
+				x: fly.position.x,
+				y: fly.position.y,
+			},
+			stage:   0,
+			energy:  0,
+			isAlive: true,
+			female:  drawSex(),
+			heading: rand.Float64() * 2 * math.Pi,
 		}
+		newFly = append(newFly, newEgg)
 	}
 
 	return newFly
@@ -189,20 +309,18 @@ func GetTreePositions(country Country) []Tree {
 
 // ComputeDegreeDay calculates the degree days for a single day.
 // takes a pointer to a fly and a weather struct as inputs.
-// It computes the degree days for the fly by considering the fly's stage and the temperature of the quadrant it is in.
+// It computes the degree days for the fly by considering the fly's stage and the daily minimum and maximum temperature at its position.
+// A minimum below the base temperature counts as the base temperature (the modified average method).
 // The result is then returned.
 func ComputeDegreeDay(fly *Fly, weather Weather) float64 {
-	// get the quadrant of the fly to determine the temperature
-	quadrantID := GetQuadrant(fly, weather.Quadrants)
-
-	// get the temperature of the quadrant
-	temperature := GetTemperature(quadrantID, weather.Quadrants)
+	// get the temperatures at the fly's position on the day of the weather
+	tmin, tmax := GetTemperature(fly.position, weather)
 
 	// get the base temperature base on the fly's stage
 	baseTemp := GetBaseTemp(fly.stage)
 
 	// calculate the degree days
-	degreeDays := (temperature+baseTemp)/2 - baseTemp
+	degreeDays := (tmax+math.Max(tmin, baseTemp))/2 - baseTemp
 
 	// if degreeDays is negative, set it to 0
 	if degreeDays < 0 {
@@ -250,78 +368,63 @@ func UpdateLifeStage(fly *Fly) int {
 	}
 }
 
-// ComputeMortality updates the mortality status of flies.
-// survival rate: 1: 0.6488, 2: 0.9087, 3: 0.8948, 4: 0.822
-// calculates the mortality of a fly based on its stage.
-// The stage is a number from 1 to 5, and the mortality rate for each stage is determined by a set of survival rates (sRI1 to sRI5).
-// The function uses a switch statement to select the appropriate survival rate based on the fly's stage, and then generates a random float between 0 and 1.
-// If the random float is less than or equal to the survival rate, the function returns true, indicating that the fly has died.
-// If the fly's stage is invalid or the random float is greater than the survival rate, the function returns false, indicating that the fly is still alive.
-func ComputeMortality(fly *Fly) bool {
-	// Compute mortality based on stage and survival rates
-	switch fly.stage {
-	case 1:
-		return rand.Float64() <= sRI1 // stage 1 survival rate
-	case 2:
-		return rand.Float64() <= sRI2 // stage 2 survival rate
-	case 3:
-		return rand.Float64() <= sRI3 // stage 3 survival rate
-	case 4:
-		return rand.Float64() <= sRI4 // stage 4 survival rate
-	case 5:
-		return rand.Float64() <= sRA // stage 5 adult survival rate
-	default:
-		// Handle invalid stages, consider them dead
-		return false
-	}
-}
-
-// ComputeMovement updates the position of adult flies
+// ComputeMovement updates the position of adult flies on the days they fly (see StageMovement)
 // determines the movement of a Fly instance.
 // It has a 70% chance of executing RandomMovement and a 30% chance of executing DirectedMovement.
-func ComputeMovement(fly *Fly, trees []Tree) OrderedPair {
+// The move is then shortened to the share of the day the fly could fly (flight, from 0 to 1; see FlightShare), and the wind of the day
+// drifts the fly for that share of airtime seconds (see WindDrift).
+func ComputeMovement(fly *Fly, hosts HostLayer, movement MovementKernel, weather Weather, airtime, flight float64) OrderedPair {
+	var position OrderedPair
+
 	// Randomly decide between random movement and directed movement
 	if rand.Float64() < 0.7 {
 
-		// Random movement: flies move randomly within a certain distance
-		return RandomMovement(fly)
+		// Random movement: flies move as the movement kernel draws
+		position = RandomMovement(fly, movement)
 	} else {
 
-		// Directed movement: flies move towards the nearest host tree
-		return DirectedMovement(fly, trees)
+		// Directed movement: flies move towards their hosts
+		position = DirectedMovement(fly, hosts)
 	}
+
+	position = OrderedPair{fly.position.x + flight*(position.x-fly.position.x), fly.position.y + flight*(position.y-fly.position.y)}
+	return WindDrift(fly.position, position, weather, airtime*flight)
 }
 
-// RandomMovement updates the position of adult flies based on random movement
-// takes a fly object and updates its position randomly within a maximum distance.
-// It uses trigonometry to calculate the new position based on the angle and maximum distance.
-func RandomMovement(fly *Fly) OrderedPair {
-	var maxDistance float64
-	if rand.Float64() < 0.7 {
-		maxDistance = 90.0
-	} else {
-		maxDistance = 10.0
+// NymphWalk returns where a nymph ends the day: on the host the host layer points it to if that is at most walk km away,
+// and otherwise walk km away in a random direction, searching for one.
+func NymphWalk(fly *Fly, hosts HostLayer, walk float64) OrderedPair {
+	host := hosts.Toward(fly.position)
+	if distanceKm(fly.position, host) <= walk {
+		return host
 	}
+	fly.heading = rand.Float64() * 2 * math.Pi
+	return offsetKm(fly.position, walk, fly.heading)
+}
 
-	angle := rand.Float64() * 2 * math.Pi // Random angle between 0 and 2*Pi radians
-
-	// Calculate the new position based on random movement
-	newX := fly.position.x + maxDistance*math.Cos(angle)
-	newY := fly.position.y + maxDistance*math.Sin(angle)
-
-	return OrderedPair{newX, newY}
+// RandomMovement updates the position of adult flies based on random movement
+// takes a fly object and moves it by a distance and direction drawn from the movement kernel.
+// The fly keeps the direction of the move as its heading, so a correlated kernel can turn from it the next day.
+func RandomMovement(fly *Fly, movement MovementKernel) OrderedPair {
+	position, heading := movement.Move(fly.position, fly.heading)
+	fly.heading = heading
+	return position
 }
 
 // DirectedMovement updates the position of adult flies based on directed movement
 // implements directed movement for a fly.
-// The fly is attracted to the nearest host tree, and its position is updated based on the displacement vector between the fly and the tree.
+// The fly is attracted to where the host layer points it: the nearest host tree, or up the suitability gradient of a host raster.
+// Its position is updated based on the displacement vector between the fly and that target.
 // A small random jitter is added to the new position to create more realistic movement.
-func DirectedMovement(fly *Fly, trees []Tree) OrderedPair {
-	// Find the nearest host tree
-	nearestTree := FindNearestTree(fly.position, trees)
+func DirectedMovement(fly *Fly, hosts HostLayer) OrderedPair {
+	// Find where the hosts draw the fly
+	nearestTree := hosts.Toward(fly.position)
 
 	dx := nearestTree.x - fly.position.x
 	dy := nearestTree.y - fly.position.y
+	if dx != 0 || dy != 0 {
+		fly.heading = math.Atan2(dy, dx)
+	}
 
 	// Calculate the new position based on directed movement towards the nearest tree
 	newX := fly.position.x + rand.Float64()*dx
@@ -372,7 +475,7 @@ func GetQuadrant(fly *Fly, quadrants []Quadrant) int {
 		// Check if fly's x position lies within the quadrant
 		if fly.position.x >= q.x && fly.position.x <= q.x+q.width {
 			// Check if fly's y position lies within the quadrant
-			if fly.position.y >= q.y && fly.position.y <= q.y+q.width {
+			if fly.position.y >= q.y && fly.position.y <= q.y+q.height {
 				// If both conditions are met, return the quadrant id
 				return q.id
 			}
@@ -383,25 +486,32 @@ func GetQuadrant(fly *Fly, quadrants []Quadrant) int {
 	return -1
 }
 
-// GetTemperature returns the temperature of the quadrant based on its ID.
-// If the quadrant ID is -1, the function returns 0 as the temperature.
-// If the quadrant ID matches a quadrant in the quadrant slice, the function retrieves and returns the temperature of that quadrant.
-// If the quadrant ID does not match any quadrant, the function returns 0 as the temperature.
-func GetTemperature(quadrantID int, quadrant []Quadrant) float64 {
-	temp := 0.0
-
-	if quadrantID == -1 {
-		return temp
+// GetTemperature returns the minimum and maximum temperature at a position on the day of the weather.
+// The temperatures come from the weather's provider if it has data for the position and day,
+// then from the stations of a season containing the day (see SeasonalTemperatures),
+// and otherwise from the quadrant containing the position (see QuadrantTemperatures).
+// If the position is outside every quadrant, the function returns 0 for both temperatures.
+// The weather's climate scenario, if any, is applied to the result.
+func GetTemperature(position OrderedPair, weather Weather) (float64, float64) {
+	tmin, tmax, ok := 0.0, 0.0, false
+	if weather.provider != nil {
+		tmin, tmax, ok = weather.provider.DailyTemperature(position, weather.date)
 	}
-
-	// loop through the quadrant slice and find the quadrant with the matching id
-	for _, q := range quadrant {
-		if q.id == quadrantID {
-			temp = q.temp
+	for _, season := range weather.seasons {
+		if ok {
+			break
 		}
+		tmin, tmax, ok = season.DailyTemperature(position, weather.date)
+	}
+	if !ok {
+		tmin, tmax, ok = QuadrantTemperatures(weather.Quadrants).DailyTemperature(position, weather.date)
 	}
 
-	return temp
+	if ok && weather.scenario != nil {
+		tmin = weather.scenario.Apply(tmin, weather.date)
+		tmax = weather.scenario.Apply(tmax, weather.date)
+	}
+	return tmin, tmax
 }
 
 // distance calculates the Euclidean distance between two points (2D).
@@ -434,7 +544,7 @@ func InBounds(fly *Fly, quadrants []Quadrant) bool {
 	// Check if fly position is within quadrants
 	for _, q := range quadrants {
 		if fly.position.x >= q.x && fly.position.x <= q.x+q.width &&
-			fly.position.y >= q.y && fly.position.y <= q.y+q.width {
+			fly.position.y >= q.y && fly.position.y <= q.y+q.height {
 			return true
 		}
 	}
@@ -448,9 +558,20 @@ func InBounds(fly *Fly, quadrants []Quadrant) bool {
 func CopyCountry(original Country) Country {
 	// Create a new Country instance
 	copyCountry := Country{
-		width: original.width,
-		flies: make([]Fly, len(original.flies)),
-		trees: make([]Tree, len(original.trees)),
+		width:  original.width,
+		height: original.height,
+		flies:  make([]Fly, len(original.flies)),
+		trees:  make([]Tree, len(original.trees)),
+		date:   original.date,
+		eggs:   make([]Fly, len(original.eggs)),
+		hosts:  original.hosts,
+
+		boundary:     original.boundary,
+		movement:     original.movement,
+		reproduction: original.reproduction,
+		mortality:    original.mortality,
+		deaths:       original.deaths,
+		emigrated:    original.emigrated,
 	}
 
 	// Deep copy flies
@@ -458,6 +579,11 @@ func CopyCountry(original Country) Country {
 		copyCountry.flies[i] = CopyFly(fly)
 	}
 
+	// Deep copy eggs waiting to hatch
+	for i, egg := range original.eggs {
+		copyCountry.eggs[i] = CopyFly(egg)
+	}
+
 	// Deep copy trees
 	for i, tree := range original.trees {
 		copyCountry.trees[i] = CopyTree(tree)
@@ -471,11 +597,23 @@ func CopyCountry(original Country) Country {
 func CopyFly(original Fly) Fly {
 	// Create a new Fly instance
 	copyFly := Fly{
-		position: CopyOrderedPair(original.position),
-		stage:    original.stage,
-		energy:   original.energy,
-		isAlive:  original.isAlive,
-		color:    original.color,
+		position:   CopyOrderedPair(original.position),
+		stage:      original.stage,
+		energy:     original.energy,
+		isAlive:    original.isAlive,
+		locationID: original.locationID,
+		eggMasses:  original.eggMasses,
+		adultDays:  original.adultDays,
+		female:     original.female,
+		mated:      original.mated,
+		age:        original.age,
+		emigrated:  original.emigrated,
+		heading:    original.heading,
+		moved:      original.moved,
+		movedStage: original.movedStage,
+		cause:      original.cause,
+		died:       original.died,
+		color:      original.color,
 	}
 
 	return copyFly
@@ -486,7 +624,11 @@ func CopyFly(original Fly) Fly {
 func CopyTree(original Tree) Tree {
 	// Create a new Tree instance
 	copyTree := Tree{
-		position: CopyOrderedPair(original.position),
+		position:  CopyOrderedPair(original.position),
+		species:   original.species,
+		abundance: original.abundance,
+		date:      original.date,
+		source:    original.source,
 	}
 
 	return copyTree
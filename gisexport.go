@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+)
+
+// The exporters in this file write the state of a run in formats GIS software such as QGIS opens directly.
+// Coordinates are WGS 84 longitude/latitude (EPSG:4326), like the rest of the model.
+
+// geoJSONFeature is a GeoJSON Feature with a Point or Polygon geometry.
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// geoJSONGeometry is a GeoJSON geometry. Coordinates is a [lon, lat] pair for a Point and a list of rings for a Polygon.
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// geoJSONCollection is a GeoJSON FeatureCollection.
+type geoJSONCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+// WriteFliesGeoJSON writes every fly of the country, and the eggs waiting to hatch, as GeoJSON point features.
+// Each feature has the properties date, stage (0-5), stageName, age (days since the egg was laid), alive and
+// pending (true for eggs laid this season that have not hatched yet). Dead flies are left out unless includeDead is set;
+// they also have the properties cause (of death, or "emigrated" for flies that left the study region) and died (the day).
+func WriteFliesGeoJSON(filename string, country Country, includeDead bool) error {
+	collection := geoJSONCollection{Type: "FeatureCollection", Features: make([]geoJSONFeature, 0, len(country.flies)+len(country.eggs))}
+	date := country.date.Format(dateLayout)
+
+	add := func(fly Fly, pending bool) {
+		if !fly.isAlive && !includeDead {
+			return
+		}
+		name := stageNames[6]
+		if fly.isAlive && fly.stage >= 0 && fly.stage <= 5 {
+			name = stageNames[fly.stage]
+		}
+		collection.Features = append(collection.Features, geoJSONFeature{
+			Type:     "Feature",
+			Geometry: geoJSONGeometry{Type: "Point", Coordinates: [2]float64{fly.position.x, fly.position.y}},
+			Properties: map[string]interface{}{
+				"date":      date,
+				"stage":     fly.stage,
+				"stageName": name,
+				"age":       fly.age,
+				"alive":     fly.isAlive,
+				"pending":   pending,
+			},
+		})
+		if !fly.isAlive {
+			properties := collection.Features[len(collection.Features)-1].Properties
+			properties["cause"] = fly.cause.String()
+			if fly.emigrated {
+				properties["cause"] = "emigrated"
+			}
+			if !fly.died.IsZero() {
+				properties["died"] = fly.died.Format(dateLayout)
+			}
+		}
+	}
+	for _, fly := range country.flies {
+		add(fly, false)
+	}
+	for _, egg := range country.eggs {
+		add(egg, true)
+	}
+
+	return writeJSONFile(filename, collection)
+}
+
+// CensusByQuadrant takes the census of the flies in each quadrant, keyed by quadrant id.
+// Flies outside every quadrant are left out.
+func CensusByQuadrant(country Country, quadrants []Quadrant) map[int]Census {
+	byQuadrant := make(map[int]Country)
+	for i := range country.flies {
+		fly := &country.flies[i]
+		id := GetQuadrant(fly, quadrants)
+		if id == -1 {
+			continue
+		}
+		cell := byQuadrant[id]
+		cell.date = country.date
+		cell.flies = append(cell.flies, *fly)
+		byQuadrant[id] = cell
+	}
+
+	censuses := make(map[int]Census, len(quadrants))
+	for _, q := range quadrants {
+		census := TakeCensus(byQuadrant[q.id])
+		census.date = country.date // also set for quadrants without flies
+		censuses[q.id] = census
+	}
+	return censuses
+}
+
+// WriteQuadrantsGeoJSON writes the quadrants of the weather as GeoJSON polygons with their census: the properties are id,
+// tmin and tmax (the minimum and maximum temperature at the centre of the quadrant on the day of the weather, °C; see GetTemperature),
+// date, alive, one count per stage name and dead.
+func WriteQuadrantsGeoJSON(filename string, weather Weather, censuses map[int]Census) error {
+	collection := geoJSONCollection{Type: "FeatureCollection", Features: make([]geoJSONFeature, 0, len(weather.Quadrants))}
+
+	for _, q := range weather.Quadrants {
+		census := censuses[q.id]
+		tmin, tmax := GetTemperature(OrderedPair{q.x + q.width/2, q.y + q.height/2}, weather)
+		properties := map[string]interface{}{
+			"id":    q.id,
+			"tmin":  tmin,
+			"tmax":  tmax,
+			"alive": census.alive,
+		}
+		if !census.date.IsZero() {
+			properties["date"] = census.date.Format(dateLayout)
+		}
+		for i, name := range stageNames {
+			properties[name] = census.stages[i]
+		}
+
+		ring := [][2]float64{{q.x, q.y}, {q.x + q.width, q.y}, {q.x + q.width, q.y + q.height}, {q.x, q.y + q.height}, {q.x, q.y}}
+		collection.Features = append(collection.Features, geoJSONFeature{
+			Type:       "Feature",
+			Geometry:   geoJSONGeometry{Type: "Polygon", Coordinates: [][][2]float64{ring}},
+			Properties: properties,
+		})
+	}
+
+	return writeJSONFile(filename, collection)
+}
+
+// CountRaster counts the living flies in each cell of a raster of cellSize degree cells covering bounds.
+func CountRaster(country Country, bounds Bounds, cellSize float64) Raster {
+	counts := NewRaster(bounds, cellSize, 0)
+	for i := range country.flies {
+		fly := &country.flies[i]
+		if !fly.isAlive {
+			continue
+		}
+		if col, row, ok := counts.Cell(fly.position.x, fly.position.y); ok {
+			counts.Set(col, row, counts.At(col, row)+1)
+		}
+	}
+	return counts
+}
+
+// PeakCountRaster returns, for each cell, the largest number of living flies counted on any of the time points.
+// Used to summarise a whole year of a run in one raster.
+func PeakCountRaster(timePoints []Country, bounds Bounds, cellSize float64) Raster {
+	peak := NewRaster(bounds, cellSize, 0)
+	for _, country := range timePoints {
+		counts := CountRaster(country, bounds, cellSize)
+		for i, v := range counts.values {
+			peak.values[i] = math.Max(peak.values[i], v)
+		}
+	}
+	return peak
+}
+
+// WriteGeoTIFF writes a raster as a single-band, uncompressed 32 bit float GeoTIFF in EPSG:4326.
+// Cells without data are written as NaN, which is declared as the no-data value.
+func WriteGeoTIFF(filename string, r Raster) error {
+	type entry struct {
+		tag   uint16
+		kind  uint16 // 2 ASCII, 3 SHORT, 4 LONG, 12 DOUBLE
+		count uint32
+		data  []byte
+	}
+	le := binary.LittleEndian
+	shorts := func(values ...uint16) []byte {
+		b := make([]byte, 2*len(values))
+		for i, v := range values {
+			le.PutUint16(b[2*i:], v)
+		}
+		return b
+	}
+	long := func(v uint32) []byte {
+		b := make([]byte, 4)
+		le.PutUint32(b, v)
+		return b
+	}
+	doubles := func(values ...float64) []byte {
+		b := make([]byte, 8*len(values))
+		for i, v := range values {
+			le.PutUint64(b[8*i:], math.Float64bits(v))
+		}
+		return b
+	}
+
+	pixels := make([]byte, 4*len(r.values))
+	for i, v := range r.values {
+		le.PutUint32(pixels[4*i:], math.Float32bits(float32(v)))
+	}
+
+	// geo keys: model type geographic, raster type pixel is area, geographic type WGS 84
+	geoKeys := shorts(1, 1, 0, 3,
+		1024, 0, 1, 2,
+		1025, 0, 1, 1,
+		2048, 0, 1, 4326)
+
+	// entries must be sorted by tag; the strip offset is filled in once the layout is known
+	entries := []entry{
+		{256, 4, 1, long(uint32(r.cols))},
+		{257, 4, 1, long(uint32(r.rows))},
+		{258, 3, 1, shorts(32)},
+		{259, 3, 1, shorts(1)}, // no compression
+		{262, 3, 1, shorts(1)}, // black is zero
+		{273, 4, 1, nil},       // strip offset
+		{277, 3, 1, shorts(1)},
+		{278, 4, 1, long(uint32(r.rows))},
+		{279, 4, 1, long(uint32(len(pixels)))},
+		{284, 3, 1, shorts(1)},
+		{339, 3, 1, shorts(3)}, // IEEE floating point samples
+		{33550, 12, 3, doubles(r.cellSize, r.cellSize, 0)},
+		{33922, 12, 6, doubles(0, 0, 0, r.bounds.minLon, r.bounds.maxLat, 0)},
+		{34735, 3, uint32(len(geoKeys) / 2), geoKeys},
+		{42113, 2, 4, []byte("nan\x00")}, // GDAL no-data value
+	}
+
+	// layout: header, IFD, values too large for the IFD, pixels
+	ifdSize := 2 + 12*len(entries) + 4
+	extraOffset := 8 + ifdSize
+	var extra bytes.Buffer
+	offsets := make([]uint32, len(entries))
+	for i, e := range entries {
+		if len(e.data) > 4 {
+			offsets[i] = uint32(extraOffset + extra.Len())
+			extra.Write(e.data)
+			if extra.Len()%2 == 1 {
+				extra.WriteByte(0) // values start on a word boundary
+			}
+		}
+	}
+	entries[5].data = long(uint32(extraOffset + extra.Len()))
+
+	var out bytes.Buffer
+	out.WriteString("II")
+	out.Write(shorts(42))
+	out.Write(long(8))
+	out.Write(shorts(uint16(len(entries))))
+	for i, e := range entries {
+		out.Write(shorts(e.tag, e.kind))
+		out.Write(long(e.count))
+		if len(e.data) > 4 {
+			out.Write(long(offsets[i]))
+			continue
+		}
+		value := make([]byte, 4)
+		copy(value, e.data)
+		out.Write(value)
+	}
+	out.Write(long(0)) // no further IFDs
+	out.Write(extra.Bytes())
+	out.Write(pixels)
+
+	if err := os.WriteFile(filename, out.Bytes(), 0644); err != nil {
+		return fmt.Errorf("error writing %s: %v", filename, err)
+	}
+	return nil
+}
+
+// writeJSONFile writes a value as JSON.
+func writeJSONFile(filename string, value interface{}) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", filename, err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		return fmt.Errorf("error writing %s: %v", filename, err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("error writing %s: %v", filename, err)
+	}
+	return nil
+}
+
+// ExportGIS writes the state of a run for GIS software. when is either a date (YYYY-MM-DD) or a year (YYYY):
+//   - prefix_flies.geojson: the flies and pending eggs on the date, or on the last day of the year
+//   - prefix_quadrants.geojson: the quadrants with their census and temperatures on that same day
+//   - prefix_counts.tif: living flies per raster cell on the date, or the peak count of every cell during the year
+//
+// For a date, the first time point on or after it is used.
+func ExportGIS(prefix, when string, timePoints []Country, weather Weather, bounds Bounds, cellSize float64) error {
+	var selected []Country
+	if len(when) == 4 {
+		year, err := strconv.Atoi(when)
+		if err != nil {
+			return fmt.Errorf("invalid year %q", when)
+		}
+		for _, country := range timePoints {
+			if country.date.Year() == year {
+				selected = append(selected, country)
+			}
+		}
+		if len(selected) == 0 {
+			return fmt.Errorf("the run does not include %d", year)
+		}
+	} else {
+		date, err := ParseDate(when)
+		if err != nil {
+			return err
+		}
+		for _, country := range timePoints {
+			if !country.date.Before(date) {
+				selected = []Country{country}
+				break
+			}
+		}
+		if len(selected) == 0 {
+			return fmt.Errorf("the run ends before %s", when)
+		}
+	}
+	last := selected[len(selected)-1]
+
+	if err := WriteFliesGeoJSON(prefix+"_flies.geojson", last, false); err != nil {
+		return err
+	}
+	if err := WriteQuadrantsGeoJSON(prefix+"_quadrants.geojson", weather.On(last.date), CensusByQuadrant(last, weather.Quadrants)); err != nil {
+		return err
+	}
+	return WriteGeoTIFF(prefix+"_counts.tif", PeakCountRaster(selected, bounds, cellSize))
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type ExportGISTest struct {
+	when  string
+	peak  float64 // largest count in the raster
+	flies int     // features in the flies file
+	fails bool
+}
+
+// geoTIFF is what readGeoTIFF reads back from a file written by WriteGeoTIFF.
+type geoTIFF struct {
+	cols, rows int
+	scale      [3]float64
+	tiepoint   [6]float64
+	epsg       uint16
+	noData     string
+	values     []float32
+}
+
+// readGeoTIFF reads a single-strip, little-endian 32 bit float GeoTIFF.
+func readGeoTIFF(t *testing.T, filename string) geoTIFF {
+	t.Helper()
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	le := binary.LittleEndian
+	if string(data[0:2]) != "II" || le.Uint16(data[2:]) != 42 {
+		t.Fatalf("%s is not a little-endian TIFF", filename)
+	}
+
+	var g geoTIFF
+	var stripOffset, stripBytes uint32
+	ifd := int(le.Uint32(data[4:]))
+	n := int(le.Uint16(data[ifd:]))
+	lastTag := uint16(0)
+	for i := 0; i < n; i++ {
+		e := data[ifd+2+12*i:]
+		tag, kind, count := le.Uint16(e), le.Uint16(e[2:]), le.Uint32(e[4:])
+		if tag <= lastTag {
+			t.Errorf("TIFF tag %d is out of order", tag)
+		}
+		lastTag = tag
+		size := map[uint16]uint32{2: 1, 3: 2, 4: 4, 12: 8}[kind] * count
+		value := e[8:12]
+		if size > 4 {
+			value = data[le.Uint32(e[8:]) : le.Uint32(e[8:])+size]
+		}
+		switch tag {
+		case 256:
+			g.cols = int(le.Uint32(value))
+		case 257:
+			g.rows = int(le.Uint32(value))
+		case 258:
+			if le.Uint16(value) != 32 {
+				t.Errorf("TIFF has %d bits per sample, want 32", le.Uint16(value))
+			}
+		case 273:
+			stripOffset = le.Uint32(value)
+		case 279:
+			stripBytes = le.Uint32(value)
+		case 339:
+			if le.Uint16(value) != 3 {
+				t.Errorf("TIFF sample format is %d, want 3 (floating point)", le.Uint16(value))
+			}
+		case 33550:
+			for j := range g.scale {
+				g.scale[j] = math.Float64frombits(le.Uint64(value[8*j:]))
+			}
+		case 33922:
+			for j := range g.tiepoint {
+				g.tiepoint[j] = math.Float64frombits(le.Uint64(value[8*j:]))
+			}
+		case 34735:
+			for k := 4; k+3 < int(count); k += 4 {
+				if le.Uint16(value[2*k:]) == 2048 {
+					g.epsg = le.Uint16(value[2*k+6:])
+				}
+			}
+		case 42113:
+			g.noData = string(value[:count-1])
+		}
+	}
+
+	if int(stripBytes) != 4*g.cols*g.rows || int(stripOffset+stripBytes) != len(data) {
+		t.Fatalf("TIFF strip of %d bytes at %d does not hold %d x %d floats at the end of the %d byte file", stripBytes, stripOffset, g.cols, g.rows, len(data))
+	}
+	for i := 0; i < g.cols*g.rows; i++ {
+		g.values = append(g.values, math.Float32frombits(le.Uint32(data[int(stripOffset)+4*i:])))
+	}
+	return g
+}
+
+// TestWriteGeoTIFF writes a raster and reads it back.
+func TestWriteGeoTIFF(t *testing.T) {
+	r := NewRaster(Bounds{minLon: -80, minLat: 39.5, maxLon: -77.5, maxLat: 42}, 0.5, 0)
+	for i := range r.values {
+		r.values[i] = float64(i) * 1.5
+	}
+	r.Set(2, 3, math.NaN())
+	filename := filepath.Join(t.TempDir(), "counts.tif")
+	if err := WriteGeoTIFF(filename, r); err != nil {
+		t.Fatal(err)
+	}
+
+	g := readGeoTIFF(t, filename)
+	if g.cols != r.cols || g.rows != r.rows {
+		t.Fatalf("GeoTIFF is %d x %d, want %d x %d", g.cols, g.rows, r.cols, r.rows)
+	}
+	if g.scale != [3]float64{0.5, 0.5, 0} {
+		t.Errorf("GeoTIFF pixel scale = %v, want [0.5 0.5 0]", g.scale)
+	}
+	if g.tiepoint != [6]float64{0, 0, 0, -80, 42, 0} {
+		t.Errorf("GeoTIFF tie point = %v, want the top left corner -80, 42", g.tiepoint)
+	}
+	if g.epsg != 4326 || g.noData != "nan" {
+		t.Errorf("GeoTIFF EPSG %d and no-data %q, want 4326 and nan", g.epsg, g.noData)
+	}
+	for row := 0; row < r.rows; row++ {
+		for col := 0; col < r.cols; col++ {
+			want, got := r.At(col, row), float64(g.values[row*g.cols+col])
+			if (math.IsNaN(want) != math.IsNaN(got)) || (!math.IsNaN(want) && want != got) {
+				t.Errorf("GeoTIFF cell %d, %d = %v, want %v", col, row, got, want)
+			}
+		}
+	}
+}
+
+func TestWriteFliesGeoJSON(t *testing.T) {
+	country := Country{
+		date: date(2021, 8, 1),
+		flies: []Fly{
+			{position: OrderedPair{-79.5, 40.5}, stage: 5, age: 90, isAlive: true},
+			{position: OrderedPair{-79, 41}, stage: 3, isAlive: false, cause: CauseCold, died: date(2021, 7, 30)},
+			{position: OrderedPair{-82, 41}, stage: 4, isAlive: false, emigrated: true},
+		},
+		eggs: []Fly{{position: OrderedPair{-78, 40}, stage: 0, isAlive: true}},
+	}
+
+	for _, includeDead := range []bool{false, true} {
+		filename := filepath.Join(t.TempDir(), "flies.geojson")
+		if err := WriteFliesGeoJSON(filename, country, includeDead); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var collection struct {
+			Type     string `json:"type"`
+			Features []struct {
+				Geometry struct {
+					Type        string     `json:"type"`
+					Coordinates [2]float64 `json:"coordinates"`
+				} `json:"geometry"`
+				Properties map[string]interface{} `json:"properties"`
+			} `json:"features"`
+		}
+		if err := json.Unmarshal(data, &collection); err != nil {
+			t.Fatal(err)
+		}
+
+		want := 2
+		if includeDead {
+			want = 4
+		}
+		if collection.Type != "FeatureCollection" || len(collection.Features) != want {
+			t.Fatalf("WriteFliesGeoJSON(includeDead %v) wrote %d features, want %d", includeDead, len(collection.Features), want)
+		}
+		first := collection.Features[0]
+		if first.Geometry.Type != "Point" || first.Geometry.Coordinates != [2]float64{-79.5, 40.5} ||
+			first.Properties["stageName"] != "adult" || first.Properties["age"] != 90.0 || first.Properties["date"] != "2021-08-01" {
+			t.Errorf("first feature = %+v", first)
+		}
+		egg := collection.Features[len(collection.Features)-1]
+		if egg.Properties["pending"] != true || egg.Properties["stageName"] != "egg" {
+			t.Errorf("pending egg feature = %+v", egg)
+		}
+		if includeDead {
+			if p := collection.Features[1].Properties; p["cause"] != "cold" || p["died"] != "2021-07-30" || p["stageName"] != "dead" {
+				t.Errorf("dead fly feature has properties %v", p)
+			}
+			if p := collection.Features[2].Properties; p["cause"] != "emigrated" {
+				t.Errorf("emigrated fly feature has properties %v", p)
+			}
+		}
+	}
+}
+
+// TestWriteQuadrantsGeoJSON writes the quadrants and reads them back as boundaries, with the temperatures of the day.
+func TestWriteQuadrantsGeoJSON(t *testing.T) {
+	quadrants := []Quadrant{{x: -80, y: 40, width: 1, height: 1, id: 3, temp: 25, minTemp: 10}, {x: -79, y: 40, width: 1, height: 1, id: 7, temp: 20, minTemp: 8}}
+	// the oviposition weather replaces the station temperatures in the autumn
+	autumn := []Quadrant{{x: -80, y: 40, width: 2, height: 1, id: 0, temp: 15, minTemp: 2}}
+	weather := Weather{Quadrants: quadrants, seasons: []SeasonalTemperatures{{from: 901, to: 1130, quadrants: autumn}}}
+	country := Country{date: date(2021, 8, 1), flies: []Fly{
+		{position: OrderedPair{-79.5, 40.5}, stage: 5, isAlive: true},
+		{position: OrderedPair{-79.5, 40.6}, stage: 1, isAlive: true},
+		{position: OrderedPair{-85, 40.5}, stage: 1, isAlive: true},
+	}}
+	censuses := CensusByQuadrant(country, quadrants)
+	if censuses[3].alive != 2 || censuses[7].alive != 0 || !censuses[7].date.Equal(country.date) {
+		t.Errorf("CensusByQuadrant = %+v", censuses)
+	}
+
+	filename := filepath.Join(t.TempDir(), "quadrants.geojson")
+	if err := WriteQuadrantsGeoJSON(filename, weather.On(country.date), censuses); err != nil {
+		t.Fatal(err)
+	}
+	boundaries, err := LoadBoundaries(filename)
+	if err != nil {
+		t.Fatalf("the quadrants file cannot be read back: %v", err)
+	}
+	if len(boundaries) != 2 {
+		t.Fatalf("read back %d quadrants, want 2", len(boundaries))
+	}
+	ring := boundaries[1].polygons[0][0]
+	want := []OrderedPair{{-79, 40}, {-78, 40}, {-78, 41}, {-79, 41}, {-79, 40}}
+	for i := range want {
+		if ring[i] != want[i] {
+			t.Errorf("quadrant ring = %v, want %v", ring, want)
+			break
+		}
+	}
+
+	for day, want := range map[string][2]float64{"2021-08-01": {8, 20}, "2021-10-01": {2, 15}} {
+		when, err := ParseDate(day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteQuadrantsGeoJSON(filename, weather.On(when), censuses); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var collection geoJSONFile
+		if err := json.Unmarshal(data, &collection); err != nil {
+			t.Fatal(err)
+		}
+		p := collection.Features[1].Properties
+		if p["tmin"] != want[0] || p["tmax"] != want[1] {
+			t.Errorf("quadrant 7 on %s has tmin %v and tmax %v, want %v and %v", day, p["tmin"], p["tmax"], want[0], want[1])
+		}
+		if _, ok := p["temp"]; ok {
+			t.Errorf("quadrant 7 still has the station temp property")
+		}
+	}
+}
+
+func TestExportGIS(t *testing.T) {
+	weather := Weather{Quadrants: []Quadrant{{x: -80, y: 40, width: 2, height: 2, id: 0}}}
+	bounds := Bounds{minLon: -80, minLat: 40, maxLon: -78, maxLat: 42}
+	one := Fly{position: OrderedPair{-79.5, 40.5}, stage: 5, isAlive: true}
+	timePoints := []Country{
+		{date: date(2021, 7, 1), flies: []Fly{one, one, one}},
+		{date: date(2021, 12, 31), flies: []Fly{one}},
+		{date: date(2022, 7, 1), flies: []Fly{one, one}},
+	}
+	tests := []ExportGISTest{
+		{when: "2021", peak: 3, flies: 1},
+		{when: "2021-08-01", peak: 1, flies: 1},
+		{when: "2022-07-01", peak: 2, flies: 2},
+		{when: "2020", fails: true},
+		{when: "2023-01-01", fails: true},
+		{when: "20x1", fails: true},
+		{when: "July 2021", fails: true},
+	}
+
+	for _, test := range tests {
+		prefix := filepath.Join(t.TempDir(), "run")
+		err := ExportGIS(prefix, test.when, timePoints, weather, bounds, 1)
+		if (err != nil) != test.fails {
+			t.Errorf("ExportGIS(%q) error = %v, want error %v", test.when, err, test.fails)
+			continue
+		}
+		if test.fails {
+			continue
+		}
+		g := readGeoTIFF(t, prefix+"_counts.tif")
+		peak := 0.0
+		for _, v := range g.values {
+			peak = math.Max(peak, float64(v))
+		}
+		if peak != test.peak {
+			t.Errorf("ExportGIS(%q) peak count = %v, want %v", test.when, peak, test.peak)
+		}
+		data, err := os.ReadFile(prefix + "_flies.geojson")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var collection geoJSONFile
+		if err := json.Unmarshal(data, &collection); err != nil {
+			t.Fatal(err)
+		}
+		if len(collection.Features) != test.flies {
+			t.Errorf("ExportGIS(%q) wrote %d flies, want %d", test.when, len(collection.Features), test.flies)
+		}
+	}
+}
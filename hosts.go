@@ -0,0 +1,422 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hostColumns lists the columns of a host tree file with the names each may have in the header, covering the bundled
+// processed_data.csv, iNaturalist and GBIF exports, EDDMapS exports and forest inventory plot tables.
+var hostColumns = []tableColumn{
+	{"latitude", []string{"lat", "decimalLatitude", "lat_dd"}, true},
+	{"longitude", []string{"lon", "long", "lng", "decimalLongitude", "lon_dd"}, true},
+	{"species", []string{"scientific_name", "scientificName", "taxon_species_name", "sciname", "taxon", "common_name", "commonName"}, false},
+	{"abundance", []string{"count", "individualCount", "number_of_trees", "trees", "stems", "tpa", "tpa_unadj"}, false},
+	{"date", []string{"observed_on", "eventDate", "ObsDate", "date_observed", "measdate", "invyr"}, false},
+	{"source", []string{"dataset", "datasetName", "institutionCode"}, false},
+}
+
+// hostDateLayouts are the date formats accepted in the date column, tried in order.
+var hostDateLayouts = []string{
+	"2006-01-02",
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05 -0700",
+	"01/02/2006",
+	"1/2/2006",
+	"2006",
+}
+
+// HostOptions selects and cleans the host tree records that are loaded.
+type HostOptions struct {
+	region    Region   // study region; records outside it are left out
+	tolerance float64  // records of the same species closer than this, in km, are merged; 0 keeps them all
+	species   []string // species to keep, compared ignoring case; empty for all
+}
+
+// HostFileReport summarises the reading of one host tree file.
+type HostFileReport struct {
+	file      string
+	rows      int            // data rows in the file
+	kept      int            // rows returned
+	outside   int            // rows outside the study region
+	dropped   map[string]int // rows left out for another reason
+	guessed   int            // rows whose abundance could not be read and was taken as 1
+	errors    []RowError     // rows that could not be read
+	noSpecies bool           // the file has no species column
+}
+
+// HostReport summarises the host trees loaded from all files.
+type HostReport struct {
+	files      []HostFileReport
+	duplicates int // records merged into a nearby record of the same species
+	trees      int // records left after merging
+	abundance  float64
+	bySpecies  map[string]int // records left per species, "" for not recorded
+	first      time.Time      // earliest date recorded
+	last       time.Time      // latest date recorded
+}
+
+// String formats the report for printing. Only the first few row errors of each file are listed.
+func (r HostReport) String() string {
+	var b strings.Builder
+	for _, f := range r.files {
+		fmt.Fprintf(&b, "%s: %d rows, %d kept, %d outside the study region", f.file, f.rows, f.kept, f.outside)
+		if f.noSpecies {
+			b.WriteString(", no species column")
+		}
+		b.WriteString("\n")
+
+		reasons := make([]string, 0, len(f.dropped))
+		for reason := range f.dropped {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			fmt.Fprintf(&b, "  %d dropped: %s\n", f.dropped[reason], reason)
+		}
+		if f.guessed > 0 {
+			fmt.Fprintf(&b, "  %d with an abundance that is not a number, counted as 1\n", f.guessed)
+		}
+		if len(f.errors) > 0 {
+			fmt.Fprintf(&b, "  %d rows could not be read:\n", len(f.errors))
+			for i, e := range f.errors {
+				if i == 10 {
+					fmt.Fprintf(&b, "  ... and %d more\n", len(f.errors)-10)
+					break
+				}
+				fmt.Fprintf(&b, "  %v\n", e)
+			}
+		}
+	}
+
+	fmt.Fprintf(&b, "%d host trees loaded (abundance %.0f), %d near-duplicates merged", r.trees, r.abundance, r.duplicates)
+	if !r.first.IsZero() {
+		fmt.Fprintf(&b, ", dated %s to %s", r.first.Format(dateLayout), r.last.Format(dateLayout))
+	}
+	b.WriteString("\n")
+
+	species := make([]string, 0, len(r.bySpecies))
+	for name := range r.bySpecies {
+		species = append(species, name)
+	}
+	sort.Slice(species, func(i, j int) bool {
+		if r.bySpecies[species[i]] != r.bySpecies[species[j]] {
+			return r.bySpecies[species[i]] > r.bySpecies[species[j]]
+		}
+		return species[i] < species[j]
+	})
+	for i, name := range species {
+		if i == 10 {
+			fmt.Fprintf(&b, "  ... and %d more species\n", len(species)-10)
+			break
+		}
+		if name == "" {
+			name = "(species not recorded)"
+		}
+		fmt.Fprintf(&b, "  %s: %d\n", name, r.bySpecies[species[i]])
+	}
+	return b.String()
+}
+
+// LoadHosts reads the host trees of one or more files (see ReadHostFile), merges the near-duplicates across all of them
+// (see DeduplicateTrees) and summarises what was loaded.
+func LoadHosts(filenames []string, options HostOptions) ([]Tree, HostReport, error) {
+	report := HostReport{bySpecies: make(map[string]int)}
+	if len(filenames) == 0 {
+		return nil, report, fmt.Errorf("no host tree files given")
+	}
+
+	var trees []Tree
+	for _, filename := range filenames {
+		fileTrees, fileReport, err := ReadHostFile(filename, options)
+		if err != nil {
+			return nil, report, err
+		}
+		trees = append(trees, fileTrees...)
+		report.files = append(report.files, fileReport)
+	}
+
+	before := len(trees)
+	trees = DeduplicateTrees(trees, options.tolerance)
+	report.duplicates = before - len(trees)
+	report.trees = len(trees)
+	for _, tree := range trees {
+		report.abundance += tree.abundance
+		report.bySpecies[tree.species]++
+		if tree.date.IsZero() {
+			continue
+		}
+		if report.first.IsZero() || tree.date.Before(report.first) {
+			report.first = tree.date
+		}
+		if tree.date.After(report.last) {
+			report.last = tree.date
+		}
+	}
+	return trees, report, nil
+}
+
+// ReadHostFile reads host tree records from a CSV or tab-separated file, finding the columns by their header (see hostColumns).
+// Only the coordinates are required: a missing species is left empty, a missing abundance is 1 and the source defaults to the
+// file name. "NA" and empty fields are missing values. Records outside the study region, of other species or with no abundance
+// are left out; rows that cannot be parsed are listed in the report instead of stopping the read.
+// The error is only set if the file cannot be opened or its header does not match.
+func ReadHostFile(filename string, options HostOptions) ([]Tree, HostFileReport, error) {
+	report := HostFileReport{file: filename, dropped: make(map[string]int)}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, report, fmt.Errorf("error opening host tree file: %v", err)
+	}
+	defer file.Close()
+
+	reader := newTableReader(file)
+	header, err := readHeader(reader, hostColumns)
+	if err != nil {
+		return nil, report, fmt.Errorf("%s: %v", filename, err)
+	}
+	_, hasSpecies := header["species"]
+	report.noSpecies = !hasSpecies
+	source := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+
+	var trees []Tree
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			parseErr, ok := err.(*csv.ParseError)
+			if !ok {
+				return nil, report, fmt.Errorf("%s: %v", filename, err)
+			}
+			report.rows++
+			report.errors = append(report.errors, RowError{line: parseErr.StartLine, reason: parseErr.Err.Error()})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		if isEmptyRecord(record) {
+			continue
+		}
+		report.rows++
+
+		tree, guessed, dropReason, rowErr := parseHostTree(record, header)
+		if rowErr != nil {
+			rowErr.line = line
+			report.errors = append(report.errors, *rowErr)
+			continue
+		}
+		switch {
+		case dropReason != "":
+			report.dropped[dropReason]++
+			continue
+		case !options.region.Contains(tree.position):
+			report.outside++
+			continue
+		case len(options.species) > 0 && !containsFold(options.species, tree.species):
+			report.dropped["other species"]++
+			continue
+		}
+		if guessed {
+			report.guessed++
+		}
+		if tree.source == "" {
+			tree.source = source
+		}
+		trees = append(trees, tree)
+		report.kept++
+	}
+	return trees, report, nil
+}
+
+// parseHostTree reads one record. It returns the tree, whether its abundance was not a number and taken as 1,
+// the reason for dropping a row without coordinates or abundance, and an error for a value that cannot be parsed.
+func parseHostTree(record []string, header map[string]int) (Tree, bool, string, *RowError) {
+	field := func(column string) string {
+		i, ok := header[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		value := strings.TrimSpace(record[i])
+		if strings.EqualFold(value, "NA") {
+			return ""
+		}
+		return value
+	}
+	rowError := func(column, reason string) *RowError {
+		return &RowError{column: column, value: field(column), reason: reason}
+	}
+
+	tree := Tree{species: strings.Join(strings.Fields(field("species")), " "), source: field("source"), abundance: 1}
+	if field("latitude") == "" || field("longitude") == "" {
+		return tree, false, "no coordinates", nil
+	}
+
+	var err error
+	if tree.position.y, err = parseFloat(field("latitude")); err != nil || tree.position.y < -90 || tree.position.y > 90 {
+		return tree, false, "", rowError("latitude", "not a latitude")
+	}
+	if tree.position.x, err = parseFloat(field("longitude")); err != nil || tree.position.x < -180 || tree.position.x > 180 {
+		return tree, false, "", rowError("longitude", "not a longitude")
+	}
+
+	guessed := false
+	if value := field("abundance"); value != "" {
+		abundance, err := strconv.ParseFloat(value, 64)
+		switch {
+		case err != nil:
+			guessed = true
+		case abundance < 0 || math.IsNaN(abundance) || math.IsInf(abundance, 0):
+			return tree, false, "", rowError("abundance", "not a count")
+		case abundance == 0:
+			return tree, false, "abundance of 0", nil
+		default:
+			tree.abundance = abundance
+		}
+	}
+
+	if value := field("date"); value != "" {
+		if tree.date, err = parseHostDate(value); err != nil {
+			return tree, false, "", rowError("date", "not a date")
+		}
+	}
+	return tree, guessed, "", nil
+}
+
+// parseHostDate parses a date in one of the hostDateLayouts.
+func parseHostDate(s string) (time.Time, error) {
+	for _, layout := range hostDateLayouts {
+		if date, err := time.Parse(layout, s); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("error parsing date %q", s)
+}
+
+// hostCell is a cell of the grid DeduplicateTrees sorts the trees into.
+type hostCell struct {
+	col, row int
+	species  string
+}
+
+// DeduplicateTrees merges records of the same species (compared ignoring case) that lie closer than tolerance km to a record
+// kept before them, such as one tree reported to several datasets. The kept record takes the larger abundance and the earlier date
+// of the two. Records are sorted into a grid of cells tolerance km high, so only the neighbouring cells are searched.
+// A tolerance of 0 or less returns the trees unchanged.
+func DeduplicateTrees(trees []Tree, tolerance float64) []Tree {
+	if tolerance <= 0 {
+		return trees
+	}
+	kmPerDegree := math.Pi / 180 * earthRadius
+	size := tolerance / kmPerDegree // cell size in degrees
+
+	cells := make(map[hostCell][]int)
+	kept := make([]Tree, 0, len(trees))
+	for _, tree := range trees {
+		species := strings.ToLower(tree.species)
+		col, row := int(math.Floor(tree.position.x/size)), int(math.Floor(tree.position.y/size))
+		// a degree of longitude is shorter than one of latitude, so more columns are within the tolerance
+		cosLat := math.Cos(math.Min(math.Abs(tree.position.y)+size, 89.9) * math.Pi / 180)
+		reach := int(math.Ceil(1 / cosLat))
+
+		duplicate := -1
+		for r := row - 1; r <= row+1 && duplicate < 0; r++ {
+			for c := col - reach; c <= col+reach && duplicate < 0; c++ {
+				for _, i := range cells[hostCell{c, r, species}] {
+					dx := (tree.position.x - kept[i].position.x) * kmPerDegree * math.Cos(tree.position.y*math.Pi/180)
+					dy := (tree.position.y - kept[i].position.y) * kmPerDegree
+					if math.Hypot(dx, dy) < tolerance {
+						duplicate = i
+						break
+					}
+				}
+			}
+		}
+
+		if duplicate >= 0 {
+			original := &kept[duplicate]
+			original.abundance = math.Max(original.abundance, tree.abundance)
+			if !tree.date.IsZero() && (original.date.IsZero() || tree.date.Before(original.date)) {
+				original.date = tree.date
+			}
+			continue
+		}
+		key := hostCell{col, row, species}
+		cells[key] = append(cells[key], len(kept))
+		kept = append(kept, tree)
+	}
+	return kept
+}
+
+// WriteHostSummary writes one row per species and source of the trees: species, source, records, abundance, first_date and last_date.
+func WriteHostSummary(filename string, trees []Tree) error {
+	type group struct {
+		species, source string
+		records         int
+		abundance       float64
+		first, last     time.Time
+	}
+	groups := make(map[[2]string]*group)
+	for _, tree := range trees {
+		key := [2]string{tree.species, tree.source}
+		g, ok := groups[key]
+		if !ok {
+			g = &group{species: tree.species, source: tree.source}
+			groups[key] = g
+		}
+		g.records++
+		g.abundance += tree.abundance
+		if !tree.date.IsZero() {
+			if g.first.IsZero() || tree.date.Before(g.first) {
+				g.first = tree.date
+			}
+			if tree.date.After(g.last) {
+				g.last = tree.date
+			}
+		}
+	}
+	keys := make([][2]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", filename, err)
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	w.Write([]string{"species", "source", "records", "abundance", "first_date", "last_date"})
+	formatDate := func(date time.Time) string {
+		if date.IsZero() {
+			return ""
+		}
+		return date.Format(dateLayout)
+	}
+	for _, key := range keys {
+		g := groups[key]
+		w.Write([]string{g.species, g.source, strconv.Itoa(g.records), strconv.FormatFloat(g.abundance, 'f', -1, 64), formatDate(g.first), formatDate(g.last)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("error writing %s: %v", filename, err)
+	}
+	return nil
+}
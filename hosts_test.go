@@ -0,0 +1,253 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type ReadHostFileTest struct {
+	name    string
+	input   string
+	options HostOptions
+	trees   []Tree
+	dropped map[string]int
+	outside int
+	guessed int
+	errors  []string // columns of the row errors
+	fails   bool
+}
+
+type DeduplicateTreesTest struct {
+	name      string
+	trees     []Tree
+	tolerance float64
+	result    []Tree
+}
+
+// kmEast returns the position km kilometres east of a position.
+func kmEast(position OrderedPair, km float64) OrderedPair {
+	return OrderedPair{position.x + km/(math.Pi/180*earthRadius*math.Cos(position.y*math.Pi/180)), position.y}
+}
+
+func sameTrees(a, b []Tree) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].position != b[i].position || a[i].species != b[i].species || a[i].abundance != b[i].abundance ||
+			!a[i].date.Equal(b[i].date) || a[i].source != b[i].source {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReadHostFile(t *testing.T) {
+	region, err := boxRegion("test", []float64{-80, 39, -74, 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []ReadHostFileTest{
+		{
+			name:    "bundled schema without species",
+			input:   "latitude,longitude\n40.5,-79.5\nNA,-79\n40.1,-75.2\n45,-79\n",
+			options: HostOptions{region: region},
+			trees:   []Tree{{position: OrderedPair{-79.5, 40.5}, abundance: 1, source: "trees"}, {position: OrderedPair{-75.2, 40.1}, abundance: 1, source: "trees"}},
+			dropped: map[string]int{"no coordinates": 1},
+			outside: 1,
+		},
+		{
+			name: "GBIF export",
+			input: "gbifID\tdatasetName\tscientificName\tdecimalLatitude\tdecimalLongitude\tindividualCount\teventDate\n" +
+				"1\tiNaturalist\tAilanthus  altissima\t40.5\t-79.5\t3\t2021-06-15T10:00:00Z\n" +
+				"2\tiNaturalist\tAcer rubrum\t40.6\t-79.4\t\t2020\n" +
+				"3\tEDDMapS\tAilanthus altissima\t40.7\t-79.3\t0\t\n" +
+				"4\tEDDMapS\tAilanthus altissima\t40.8\t-79.2\tmany\t06/01/2019\n",
+			options: HostOptions{region: region, species: []string{"ailanthus altissima"}},
+			trees: []Tree{
+				{position: OrderedPair{-79.5, 40.5}, species: "Ailanthus altissima", abundance: 3, date: time.Date(2021, 6, 15, 10, 0, 0, 0, time.UTC), source: "iNaturalist"},
+				{position: OrderedPair{-79.2, 40.8}, species: "Ailanthus altissima", abundance: 1, date: date(2019, 6, 1), source: "EDDMapS"},
+			},
+			dropped: map[string]int{"other species": 1, "abundance of 0": 1},
+			guessed: 1,
+		},
+		{
+			name:    "row errors do not stop the read",
+			input:   "lat,lon,count,date\n40,-79,1,2020-01-01\n95,-79,1,\n40,-200,1,\n40,-79,-2,\n40,-79,1,yesterday\n",
+			options: HostOptions{region: region},
+			trees:   []Tree{{position: OrderedPair{-79, 40}, abundance: 1, date: date(2020, 1, 1), source: "trees"}},
+			errors:  []string{"latitude", "longitude", "abundance", "date"},
+		},
+		{name: "no coordinates", input: "species,count\nAcer rubrum,1\n", options: HostOptions{region: region}, fails: true},
+		{name: "empty file", input: "", options: HostOptions{region: region}, fails: true},
+	}
+
+	for _, test := range tests {
+		path := writeTestFile(t, "trees.csv", test.input)
+		trees, report, err := ReadHostFile(path, test.options)
+		if (err != nil) != test.fails {
+			t.Errorf("ReadHostFile(%s) error = %v, want error %v", test.name, err, test.fails)
+			continue
+		}
+		if test.fails {
+			continue
+		}
+		if !sameTrees(trees, test.trees) {
+			t.Errorf("ReadHostFile(%s) = %+v, want %+v", test.name, trees, test.trees)
+		}
+		if report.outside != test.outside || report.guessed != test.guessed || report.kept != len(test.trees) {
+			t.Errorf("ReadHostFile(%s) report = %d kept, %d outside, %d guessed, want %d, %d, %d", test.name,
+				report.kept, report.outside, report.guessed, len(test.trees), test.outside, test.guessed)
+		}
+		for reason, n := range test.dropped {
+			if report.dropped[reason] != n {
+				t.Errorf("ReadHostFile(%s) dropped %d rows for %q, want %d", test.name, report.dropped[reason], reason, n)
+			}
+		}
+		if len(report.errors) != len(test.errors) {
+			t.Errorf("ReadHostFile(%s) row errors = %v, want errors in %v", test.name, report.errors, test.errors)
+			continue
+		}
+		for i, e := range report.errors {
+			if e.column != test.errors[i] || e.line != i+3 {
+				t.Errorf("ReadHostFile(%s) row error %d = %v, want one in %s on line %d", test.name, i, e, test.errors[i], i+3)
+			}
+		}
+	}
+}
+
+func TestLoadHosts(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"inat.csv": "latitude,longitude,species,date\n40.5,-79.5,Ailanthus altissima,2021-06-01\n41,-78,Acer rubrum,2019-05-01\n",
+		"fia.csv":  "lat,lon,species,tpa,invyr\n40.5,-79.5,ailanthus altissima,6,2018\n",
+	}
+	var filenames []string
+	for name, contents := range files {
+		filenames = append(filenames, filepath.Join(dir, name))
+		if err := os.WriteFile(filenames[len(filenames)-1], []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	trees, report, err := LoadHosts(filenames, HostOptions{region: ContiguousUSRegion(), tolerance: 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the two records of the same tree are merged, keeping the larger abundance and the earlier date
+	if len(trees) != 2 || report.duplicates != 1 || report.trees != 2 || report.abundance != 7 {
+		t.Errorf("LoadHosts = %d trees, %d duplicates, abundance %v, want 2, 1, 7", len(trees), report.duplicates, report.abundance)
+	}
+	if !report.first.Equal(date(2018, 1, 1)) || !report.last.Equal(date(2019, 5, 1)) {
+		t.Errorf("LoadHosts dates = %v to %v, want 2018-01-01 to 2019-05-01", report.first, report.last)
+	}
+	if !strings.Contains(report.String(), "2 host trees loaded (abundance 7), 1 near-duplicates merged") {
+		t.Errorf("report = %q", report.String())
+	}
+
+	if _, _, err := LoadHosts(nil, HostOptions{region: ContiguousUSRegion()}); err == nil {
+		t.Errorf("LoadHosts without files did not fail")
+	}
+	if _, _, err := LoadHosts([]string{filepath.Join(dir, "missing.csv")}, HostOptions{region: ContiguousUSRegion()}); err == nil {
+		t.Errorf("LoadHosts of a missing file did not fail")
+	}
+}
+
+func TestDeduplicateTrees(t *testing.T) {
+	here := OrderedPair{-79.5, 40.5}
+	north := OrderedPair{10, 70} // a degree of longitude is about 38 km
+	tests := []DeduplicateTreesTest{
+		{
+			name:      "tolerance 0",
+			trees:     []Tree{{position: here, species: "a", abundance: 1}, {position: here, species: "a", abundance: 2}},
+			tolerance: 0,
+			result:    []Tree{{position: here, species: "a", abundance: 1}, {position: here, species: "a", abundance: 2}},
+		},
+		{
+			name: "same species within the tolerance",
+			trees: []Tree{
+				{position: here, species: "Acer rubrum", abundance: 1, date: date(2021, 6, 1)},
+				{position: kmEast(here, 0.9), species: "acer rubrum", abundance: 4, date: date(2019, 6, 1)},
+				{position: kmEast(here, 1.1), species: "Acer rubrum", abundance: 1},
+			},
+			tolerance: 1,
+			result: []Tree{
+				{position: here, species: "Acer rubrum", abundance: 4, date: date(2019, 6, 1)},
+				{position: kmEast(here, 1.1), species: "Acer rubrum", abundance: 1},
+			},
+		},
+		{
+			name:      "other species",
+			trees:     []Tree{{position: here, species: "a", abundance: 1}, {position: here, species: "b", abundance: 1}},
+			tolerance: 1,
+			result:    []Tree{{position: here, species: "a", abundance: 1}, {position: here, species: "b", abundance: 1}},
+		},
+		{
+			name:      "neighbouring columns far north",
+			trees:     []Tree{{position: north, species: "a", abundance: 1}, {position: kmEast(north, -2.5), species: "a", abundance: 1}},
+			tolerance: 3,
+			result:    []Tree{{position: north, species: "a", abundance: 1}},
+		},
+	}
+
+	for _, test := range tests {
+		result := DeduplicateTrees(test.trees, test.tolerance)
+		if !sameTrees(result, test.result) {
+			t.Errorf("DeduplicateTrees(%s) = %+v, want %+v", test.name, result, test.result)
+		}
+	}
+}
+
+// TestDeduplicateTreesBruteForce compares the grid search with checking every pair of random trees.
+func TestDeduplicateTreesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	trees := make([]Tree, 2000)
+	for i := range trees {
+		trees[i] = Tree{position: OrderedPair{-80 + rng.Float64(), 45 + rng.Float64()}, species: []string{"a", "b"}[rng.Intn(2)], abundance: 1}
+	}
+	kmPerDegree := math.Pi / 180 * earthRadius
+	within := func(a, b Tree, tolerance float64) bool {
+		dx := (a.position.x - b.position.x) * kmPerDegree * math.Cos(a.position.y*math.Pi/180)
+		dy := (a.position.y - b.position.y) * kmPerDegree
+		return a.species == b.species && math.Hypot(dx, dy) < tolerance
+	}
+
+	const tolerance = 2.0
+	var want []Tree
+	for _, tree := range trees {
+		duplicate := false
+		for _, kept := range want {
+			duplicate = duplicate || within(tree, kept, tolerance)
+		}
+		if !duplicate {
+			want = append(want, tree)
+		}
+	}
+	if result := DeduplicateTrees(trees, tolerance); !sameTrees(result, want) {
+		t.Errorf("DeduplicateTrees kept %d of %d trees, want %d", len(result), len(trees), len(want))
+	}
+}
+
+func TestWriteHostSummary(t *testing.T) {
+	trees := []Tree{
+		{species: "b", source: "x", abundance: 2, date: date(2021, 1, 1)},
+		{species: "a", source: "y", abundance: 1},
+		{species: "b", source: "x", abundance: 3, date: date(2019, 1, 1)},
+	}
+	filename := filepath.Join(t.TempDir(), "summary.csv")
+	if err := WriteHostSummary(filename, trees); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "species,source,records,abundance,first_date,last_date\na,y,1,1,,\nb,x,2,5,2019-01-01,2021-01-01\n"
+	if string(data) != want {
+		t.Errorf("WriteHostSummary wrote %q, want %q", data, want)
+	}
+}
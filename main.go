@@ -1,43 +1,434 @@
 package main
 
 import (
+	"canvas"
+	"flag"
 	"fmt"
-	"gifhelper"
+	"os"
+	"strconv"
+	"strings"
 )
 
-// initializes a system, simulates migration, and generates an animated GIF to visualize the system.
+// initializes a system, simulates migration, and generates an animation (an animated GIF by default, see -format) to visualize the system.
+// Running "LanternFly serve" instead runs the simulation in a live viewer served over local HTTP,
+// "LanternFly export" draws an SVG or PDF figure of one or more dates instead of the animation,
+// "LanternFly gis" writes GeoJSON and GeoTIFF files of one day or year of the run for GIS software,
+// and "LanternFly ensemble" repeats the run under several climate scenarios and compares the spread of the flies.
 func main() {
 	fmt.Println("Lantern Flies simulation!")
+
+	command := "run"
+	args := os.Args[1:]
+	if len(args) > 0 && (args[0] == "serve" || args[0] == "export" || args[0] == "gis" || args[0] == "ensemble") {
+		command = args[0]
+		args = args[1:]
+	}
+
 	// Reading input
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	startDate := flags.String("start", "2021-05-01", "first day of the simulation (YYYY-MM-DD); with -seed checkpoint the checkpoint's date is used, and -start must agree with it")
+	timestep := flags.String("step", "daily", "length of one simulation tick: daily or weekly")
+	numYears := flags.Int("years", 1, "number of years to simulate")
+	addr := flags.String("addr", "localhost:8080", "address the live viewer listens on (serve only)")
+	projection := flags.String("projection", "equirectangular", "map projection of the rendered frames: equirectangular or albers")
+	regionSpec := flags.String("region", "us", "study region: us (the contiguous US), a bounding box minLon,minLat,maxLon,maxLat, comma-separated states (e.g. PA,NJ) or a JSON file with a bounding box, polygon, states or counties")
+	boundaryPolicy := flags.String("boundary", BoundaryAbsorb, "what happens to flies leaving the study region: absorb (removed and counted as emigrated), reflect, clamp or wrap (for testing)")
+	movementSpec := flags.String("movement", KernelCRW, "random movement kernel of adults: crw, levy, exponential, 2dt, lognormal, uniform (the original 10 or 90 degree steps), or a JSON file with the kernel and its parameters")
+	nymphWalk := flags.Float64("nymph-walk", 0.01, "farthest a nymph walks in a day to climb a host, km")
+	flightTemp := flags.Float64("flight-temp", 15, "lowest temperature at which adults fly, °C; the distance an adult flies in a day is scaled by the share of daylight hours at or above it")
+	windFolder := flags.String("wind", "", "folder of daily wind grids (wspd_YYYYMMDD.asc in m/s and wdir_YYYYMMDD.asc in degrees the wind blows from) drifting flying adults; empty for no wind")
+	airtime := flags.Float64("wind-airtime", 60, "seconds a flying adult is carried by the wind each day it flies")
+	mateRadius := flags.Float64("mate-radius", 0.1, "km within which an adult female must meet a living adult male to mate and lay eggs; 0 needs no mate")
+	ovipositionSeason := flags.String("oviposition", "07-01:11-30", "oviposition season, MM-DD:MM-DD; mated females lay only on these days, and not after they die of old age")
+	ovipositionWeather := flags.String("oviposition-weather", "Data/Egg laying_Sep-Nov", "folder of NOAA Climate at a Glance CSV files with the temperatures of the main egg-laying months; empty to use -weather all year")
+	ovipositionWeatherSeason := flags.String("oviposition-weather-season", "09-01:11-30", "days of the year, MM-DD:MM-DD, on which the -oviposition-weather temperatures are used")
+	preoviposition := flags.Float64("preoviposition", 60, "degree-days (above 5 °C) as an adult before a female matures her first egg mass")
+	massDD := flags.Float64("mass-dd", 50, "degree-days between the egg masses of a female")
+	maxMasses := flags.Int("max-masses", 2, "most egg masses a female lays in a season")
+	ageDecline := flags.Float64("fecundity-age-decline", 0.2, "share of the eggs of a mass lost for every 30 days a female has been an adult")
+	otherHosts := flags.Float64("other-host-fecundity", 0.5, "fecundity of females feeding on hosts other than Ailanthus, relative to those on Ailanthus")
+	coldLethal := flags.Float64("cold-lethal", -2, "daily minimum temperature, °C, at or below which nymphs and adults die")
+	starvation := flags.Float64("starvation", 0.1, "daily chance that a nymph or adult starves where there are no hosts; it falls to 0 with the host suitability")
+	pathogen := flags.Float64("pathogen", 0, "daily chance that a nymph or adult dies of predators or pathogens such as Beauveria, on top of the background mortality of its stage")
+	interventionSpec := flags.String("intervention", "", "area of a control programme, given like -region; empty for none")
+	interventionRate := flags.Float64("intervention-rate", 0.05, "daily chance that a fly or egg in the -intervention area is killed")
+	interventionSeason := flags.String("intervention-season", "01-01:12-31", "season of the -intervention, MM-DD:MM-DD")
+	displacementFile := flags.String("displacement-out", "", "CSV file to write the daily displacement of each stage to, for every snapshot")
+	weatherFolder := flags.String("weather", "Data/Hatch_May-Jun", "folder of NOAA Climate at a Glance CSV files, one per state")
+	gridFolder := flags.String("grid", "", "folder of daily ESRI ASCII grids (tmin_YYYYMMDD.asc and tmax_YYYYMMDD.asc, °C) used instead of the station temperatures where they have data")
+	scenarioSpec := flags.String("scenarios", "", "climate scenarios: a JSON file or a comma-separated list such as baseline,+1,+2,+4 (default: baseline, or baseline,+1,+2,+4 for ensemble)")
+	replicates := flags.Int("runs", 10, "number of runs of each scenario (ensemble only)")
+	ensembleFile := flags.String("ensemble-out", "ensemble.csv", "CSV file with the outcome of every run (ensemble only)")
+	hostFiles := flags.String("hosts", "Data/processed_data.csv", "comma-separated host tree files (CSV or tab-separated with latitude and longitude columns, e.g. iNaturalist, EDDMapS or forest inventory exports)")
+	hostSpecies := flags.String("host-species", "", "comma-separated species of the host trees to keep; empty for all")
+	hostTolerance := flags.Float64("host-dedup", 0.01, "host trees of the same species closer than this many km are merged; 0 keeps them all")
+	hostLayer := flags.String("host-layer", HostPoints, "where flies find hosts: points (the nearest host tree), smooth (the host trees smoothed into a suitability raster) or an ESRI ASCII grid of suitability or land-cover classes in longitude/latitude")
+	hostBandwidth := flags.Float64("host-bandwidth", 10, "kernel bandwidth in km of -host-layer smooth")
+	hostCell := flags.Float64("host-cell", 0.05, "cell size in degrees of -host-layer smooth")
+	hostClasses := flags.String("host-classes", "", "class:suitability pairs of a land-cover -host-layer, e.g. 41:1,43:0.8,21:0.5; empty to use the grid values scaled to a maximum of 1")
+	hostSummary := flags.String("host-summary", "", "CSV file to write the loaded host trees per species and source to")
+	detectionFile := flags.String("detections", "Data/lydetext.txt", "SLF detection dataset (CSV or tab-separated, with the columns of the lydemapr lyde data) the flies start from")
+	detectionYears := flags.String("detection-years", "2021", "bio year or range of bio years (e.g. 2019-2021) of the detections the flies start from; empty for all")
+	detectionStates := flags.String("detection-states", "", "comma-separated states (as in the state column, e.g. PA,NJ) of the detections the flies start from; empty for all")
+	established := flags.String("detection-established", "any", "establishment status of the detections the flies start from: any, yes or no")
+	densities := flags.String("detection-density", "", "comma-separated density classes of the detections the flies start from; empty for all")
+	seedStrategy := flags.String("seed", SeedSample, "how the first flies are placed: sample (one egg for a random -seed-fraction of the detections), introduction (-egg-masses at -seed-point), density (egg masses per detection by density class), established (-egg-masses per established detection) or checkpoint (-seed-checkpoint)")
+	seedFraction := flags.Float64("seed-fraction", 0.1, "share of the detections that get an egg with -seed sample")
+	eggMasses := flags.Int("egg-masses", 0, "egg masses per established detection, or in all for an introduction (default 1 and 10)")
+	seedPoint := flags.String("seed-point", "", "longitude,latitude of the introduction (default Berks County, PA: -75.68,40.43)")
+	seedCheckpoint := flags.String("seed-checkpoint", "", "checkpoint file the run starts from with -seed checkpoint")
+	checkpointOut := flags.String("checkpoint-out", "", "file to save the living flies and pending eggs of the last day in, for a later run with -seed checkpoint")
+	validationYears := flags.String("validate-years", "", "bio year or range of bio years (e.g. 2022) whose presence and absence surveys the run is evaluated against; empty for no evaluation")
+	detectionProb := flags.Float64("detection-prob", 0.8, "probability that one survey of an occupied cell finds lanternflies, used in the evaluation likelihood")
+	fill := flags.String("fill", FillNone, "how quadrants without a weather station are filled: none (an error), nearest or mean")
+	boundaryFile := flags.String("boundaries", "Data/us_states.geojson", "GeoJSON file with the state outlines drawn on the map and used to place the weather stations")
+	figureFile := flags.String("figure", "flies.svg", "SVG or PDF file written by export")
+	figureDates := flags.String("dates", "", "comma-separated YYYY-MM-DD dates drawn as small multiples by export (default: the last day)")
+	columns := flags.Int("columns", 3, "number of panels per row of the exported figure")
+	panelSize := flags.Int("panel", 1000, "width and height of each panel of the exported figure, in pixels (points in a PDF)")
+	gisPrefix := flags.String("gis-out", "flies", "file name prefix of the files written by gis")
+	gisWhen := flags.String("when", "", "day (YYYY-MM-DD) or year (YYYY) written by gis (default: the last day)")
+	fontFile := flags.String("font", "canvas/fonts/Go-Regular.ttf", "TrueType font used for titles and legends")
+	title := flags.String("title", "Spotted Lanternfly Migration Model", "title printed on every frame")
+	renderMode := flags.String("render", RenderPoints, "how flies are drawn: points, heatmap, choropleth, occupancy or arrival")
+	rampName := flags.String("ramp", "viridis", "colour ramp of the heatmap, choropleth, occupancy and arrival modes: viridis, magma, heat or greys")
+	cellSize := flags.Float64("cell", 0.25, "raster cell size in degrees for the heatmap and arrival modes and the gis count raster")
+	bandwidth := flags.Float64("bandwidth", 50, "kernel bandwidth in km for the heatmap mode")
+	format := flags.String("format", "gif", "animation format: gif, apng, png (numbered files), avi (Motion-JPEG), webp or ffmpeg (needs ffmpeg on the PATH for webp and ffmpeg)")
+	output := flags.String("out", "flies!", "animation file name; for the ffmpeg format the extension picks the container, e.g. flies.mp4")
+	frameRate := flags.Float64("fps", 10, "frames per second of the animation")
+	quality := flags.Int("quality", 75, "image quality from 1 to 100 for the avi, webp and ffmpeg formats")
+	flags.Parse(args)
+
+	start, err := ParseDate(*startDate)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	// a run seeded from a checkpoint starts on the checkpoint's date unless -start is given (see StartDate)
+	startSet := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "start" {
+			startSet = true
+		}
+	})
+	step, err := ParseTimestep(*timestep)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	renderSettings, err := NewRenderSettings(*renderMode, *rampName, *cellSize, *bandwidth)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	dates, err := ParseDates(*figureDates)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	point := BerksCounty
+	if *seedPoint != "" {
+		lon, lat, found := strings.Cut(*seedPoint, ",")
+		var err1, err2 error
+		point.x, err1 = strconv.ParseFloat(strings.TrimSpace(lon), 64)
+		point.y, err2 = strconv.ParseFloat(strings.TrimSpace(lat), 64)
+		if !found || err1 != nil || err2 != nil {
+			fmt.Printf("invalid seed point %q, expected longitude,latitude\n", *seedPoint)
+			os.Exit(1)
+		}
+	}
+	seeding, err := NewSeeding(*seedStrategy, *seedFraction, *eggMasses, point, *seedCheckpoint)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	detectionFilter, err := ParseDetectionFilter(*detectionYears, *detectionStates, *established, *densities)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
 	fmt.Println("Success! Now we are ready to do something cool with our data.")
 
 	// Initialize the system
-	initialCountry := InitializeCountry()
-	fmt.Println("Country initialized.")
+	region, err := ParseRegion(*regionSpec, *boundaryFile)
+	if err != nil {
+		fmt.Println("Error loading study region:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Study region:", region.String()+".")
+	detectionFilter.region = &region
+	boundary, err := NewBoundaryPolicy(*boundaryPolicy, region.bounds)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	kernel, err := ParseMovement(*movementSpec)
+	if err != nil {
+		fmt.Println("Error reading movement kernel:", err)
+		os.Exit(1)
+	}
+	movement, err := NewStageMovement(kernel, *nymphWalk, *flightTemp, *airtime)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	var intervention *Intervention
+	if *interventionSpec != "" {
+		area, err := ParseRegion(*interventionSpec, *boundaryFile)
+		if err != nil {
+			fmt.Println("Error loading intervention area:", err)
+			os.Exit(1)
+		}
+		if intervention, err = NewIntervention(area, *interventionSeason, *interventionRate); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	mortality, err := NewMortality(*coldLethal, *starvation, *pathogen, intervention)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	reproduction, err := NewReproduction(*mateRadius, *ovipositionSeason, *preoviposition, *massDD, *maxMasses, *ageDecline, *otherHosts)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
-	numYears := 1
-
-	weather := InitializeQuadrants()
+	weather, err := InitializeQuadrants(region.bounds, *weatherFolder, *boundaryFile, *fill)
+	if err != nil {
+		fmt.Println("Error loading weather data:", err)
+		os.Exit(1)
+	}
+	if *gridFolder != "" {
+		grid, err := NewGridTemperatures(*gridFolder)
+		if err != nil {
+			fmt.Println("Error loading temperature grids:", err)
+			os.Exit(1)
+		}
+		days, first, last := grid.Days()
+		fmt.Printf("Read %d days of temperature grids, %s to %s.\n", days, first.Format(dateLayout), last.Format(dateLayout))
+		weather.provider = grid
+	}
+	if *ovipositionWeather != "" {
+		from, to, err := ParseSeason(*ovipositionWeatherSeason)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		season, err := InitializeQuadrants(region.bounds, *ovipositionWeather, *boundaryFile, *fill)
+		if err != nil {
+			fmt.Println("Error loading oviposition season weather:", err)
+			os.Exit(1)
+		}
+		weather.seasons = append(weather.seasons, SeasonalTemperatures{from: from, to: to, quadrants: season.Quadrants})
+	}
+	if *windFolder != "" {
+		wind, err := NewGridWind(*windFolder)
+		if err != nil {
+			fmt.Println("Error loading wind grids:", err)
+			os.Exit(1)
+		}
+		days, first, last := wind.Days()
+		fmt.Printf("Read %d days of wind grids, %s to %s.\n", days, first.Format(dateLayout), last.Format(dateLayout))
+		weather.wind = wind
+	}
 	fmt.Println("Quadrants initialized.")
 
-	timePoints := SimulateMigration(initialCountry, numYears, weather)
+	trees, hostReport, err := LoadHosts(splitList(*hostFiles), HostOptions{region: region, tolerance: *hostTolerance, species: splitList(*hostSpecies)})
+	if err != nil {
+		fmt.Println("Error loading host trees:", err)
+		os.Exit(1)
+	}
+	fmt.Print(hostReport)
+	if *hostSummary != "" {
+		if err := WriteHostSummary(*hostSummary, trees); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	hosts, err := NewHostLayer(*hostLayer, trees, region.bounds, *hostCell, *hostBandwidth, *hostClasses)
+	if err != nil {
+		fmt.Println("Error building host layer:", err)
+		os.Exit(1)
+	}
+
+	// the surveys are read once: the presences kept by the detection filter seed the flies, and the presences and absences of the
+	// validation years evaluate the run
+	var surveys []SampleData
+	var report DetectionReport
+	if seeding.UsesDetections() || *validationYears != "" {
+		surveys, report, err = ReadSampleDataFromFile(*detectionFile)
+		if err != nil {
+			fmt.Println("Error loading detections:", err)
+			os.Exit(1)
+		}
+	}
+
+	var detections []SampleData
+	if seeding.UsesDetections() {
+		detections, _ = detectionFilter.Select(surveys, &report)
+		fmt.Print(report)
+		if len(detections) == 0 {
+			fmt.Println("No detections are left after filtering, so there are no flies to simulate.")
+			os.Exit(1)
+		}
+	}
+
+	var validation *Validation
+	if *validationYears != "" {
+		validation, err = NewValidation(surveys, *validationYears, region, *cellSize, *detectionProb)
+		if err != nil {
+			fmt.Println("Error loading surveys:", err)
+			os.Exit(1)
+		}
+	}
+
+	if command == "ensemble" && *scenarioSpec == "" {
+		*scenarioSpec = "baseline,+1,+2,+4"
+	}
+	scenarios, err := ParseScenarios(*scenarioSpec)
+	if err != nil {
+		fmt.Println("Error reading scenarios:", err)
+		os.Exit(1)
+	}
+
+	if command == "ensemble" {
+		runs, err := RunEnsemble(scenarios, weather, trees, hosts, boundary, movement, reproduction, mortality, detections, seeding, validation, *replicates, *numYears, start, startSet, step, region, *cellSize)
+		if err != nil {
+			fmt.Println("Error running ensemble:", err)
+			os.Exit(1)
+		}
+		PrintEnsembleSummary(SummarizeEnsemble(runs, scenarios))
+		if err := WriteEnsembleCSV(*ensembleFile, runs); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("Ensemble written to", *ensembleFile+".")
+		return
+	}
+
+	if len(scenarios) > 1 {
+		fmt.Println("A single run takes one scenario; use ensemble to compare several.")
+		os.Exit(1)
+	}
+	if weather, err = scenarios[0].Weather(weather); err != nil {
+		fmt.Println("Error loading scenario:", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Seeding:", seeding.Describe()+".")
+	initialCountry, err := InitializeCountry(region, boundary, movement, reproduction, mortality, weather, trees, hosts, detections, seeding)
+	if err != nil {
+		fmt.Println("Error seeding flies:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Country initialized with", len(initialCountry.flies), "flies and", len(initialCountry.eggs), "eggs waiting to hatch.")
+
+	if start, err = StartDate(initialCountry, start, startSet); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	clock := NewClock(start, step)
+	RegisterLifecycleEvents(clock)
+	fmt.Println("Simulating from", clock.Date().Format(dateLayout), "in", *timestep, "steps.")
+
+	if command == "serve" {
+		viewer := NewViewer(initialCountry, *numYears, weather, region.bounds, clock)
+		if err := viewer.Serve(*addr); err != nil {
+			fmt.Println("Error running viewer:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	timePoints := SimulateMigration(initialCountry, *numYears, weather, clock)
 	fmt.Println("Migration simulated.")
+	if boundary.mode == BoundaryAbsorb {
+		fmt.Println(timePoints[len(timePoints)-1].emigrated, "flies left the study region.")
+	}
+	fmt.Println("Deaths by cause:")
+	PrintDeaths(TakeCensus(timePoints[len(timePoints)-1]))
+	fmt.Println("Daily displacement by stage:")
+	PrintDisplacement(MeasureDisplacement(timePoints[1:]))
+	if *displacementFile != "" {
+		if err := WriteDisplacementCSV(*displacementFile, timePoints[1:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("Displacement written to", *displacementFile+".")
+	}
+
+	if validation != nil {
+		evaluation, err := validation.EvaluateRun(timePoints)
+		if err != nil {
+			fmt.Println("Error evaluating the run:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Evaluation against the surveys:", evaluation)
+	}
+
+	if *checkpointOut != "" {
+		if err := SaveCheckpoint(*checkpointOut, timePoints[len(timePoints)-1]); err != nil {
+			fmt.Println("Error saving checkpoint:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Checkpoint saved to", *checkpointOut+".")
+	}
+
+	if command == "gis" {
+		when := *gisWhen
+		if when == "" {
+			when = timePoints[len(timePoints)-1].date.Format(dateLayout)
+		}
+		if err := ExportGIS(*gisPrefix, when, timePoints, weather, region.bounds, *cellSize); err != nil {
+			fmt.Println("Error exporting GIS files:", err)
+			os.Exit(1)
+		}
+		fmt.Println("GIS files written with prefix", *gisPrefix+".")
+		return
+	}
 
 	canvasWidth := 10000
 	canvasHeight := 10000
 	imageFrequency := 30
 
-	// Animate the system
-	images := AnimateSystem(timePoints, int(canvasWidth), int(canvasHeight), imageFrequency)
-	fmt.Println("Images drawn!")
+	if err := canvas.LoadFont(*fontFile); err != nil {
+		fmt.Println("Error loading font, frames will have no text:", err)
+	}
+
+	basemap, err := NewBasemap(*title, *boundaryFile, region.bounds, *projection, weather.Quadrants, 5)
+	if err != nil {
+		fmt.Println("Error loading basemap:", err)
+		os.Exit(1)
+	}
+
+	if command == "export" {
+		if err := ExportFigure(*figureFile, timePoints, dates, basemap, renderSettings, *panelSize, *columns); err != nil {
+			fmt.Println("Error exporting figure:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Figure written to", *figureFile+".")
+		return
+	}
 
-	fmt.Println("Generating an animated GIF.")
+	encoder, err := NewFrameEncoder(*format, *output, EncoderOptions{frameRate: *frameRate, quality: *quality})
+	if err != nil {
+		fmt.Println("Error setting up animation:", err)
+		os.Exit(1)
+	}
 
-	// Save the images as an animated GIF
-	gifhelper.ImagesToGIF(images, "flies!")
+	// Animate the system, writing each frame as soon as it is drawn
+	fmt.Println("Drawing the animation as", *format+".")
+	if err := WriteAnimation(timePoints, basemap, renderSettings, canvasWidth, canvasHeight, imageFrequency, encoder); err != nil {
+		fmt.Println("Error writing animation:", err)
+		os.Exit(1)
+	}
 
-	fmt.Println("GIF drawn!")
+	fmt.Println("Animation drawn!")
 
 	fmt.Println("Simulation complete!")
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// femaleShare is the share of eggs that are female; the sex ratio of lanternflies is close to 1:1.
+const femaleShare = 0.5
+
+// drawSex returns true for a female, with probability femaleShare.
+func drawSex() bool {
+	return rand.Float64() < femaleShare
+}
+
+// mateCell is a cell of the grid MateFlies sorts the males into.
+type mateCell struct {
+	col, row int
+}
+
+// MateFlies marks as mated the living unmated adult females with a living adult male within radius km, and returns how many mated.
+// Males are sorted into a grid of cells radius km high, so only the neighbouring cells are searched. A male may mate with any
+// number of females. With a radius of 0 or less every living adult female is mated.
+func MateFlies(flies []Fly, radius float64) int {
+	isAdult := func(fly Fly) bool {
+		return fly.isAlive && fly.stage == 5
+	}
+
+	mated := 0
+	if radius <= 0 {
+		for i := range flies {
+			if isAdult(flies[i]) && flies[i].female && !flies[i].mated {
+				flies[i].mated = true
+				mated++
+			}
+		}
+		return mated
+	}
+
+	kmPerDegree := math.Pi / 180 * earthRadius
+	size := radius / kmPerDegree // cell size in degrees
+	cellOf := func(position OrderedPair) mateCell {
+		return mateCell{int(math.Floor(position.x / size)), int(math.Floor(position.y / size))}
+	}
+
+	males := make(map[mateCell][]OrderedPair)
+	for _, fly := range flies {
+		if isAdult(fly) && !fly.female {
+			cell := cellOf(fly.position)
+			males[cell] = append(males[cell], fly.position)
+		}
+	}
+	if len(males) == 0 {
+		return 0
+	}
+
+	for i := range flies {
+		fly := &flies[i]
+		if !isAdult(*fly) || !fly.female || fly.mated {
+			continue
+		}
+		cell := cellOf(fly.position)
+		// a degree of longitude is shorter than one of latitude, so more columns are within the radius
+		cosLat := math.Cos(math.Min(math.Abs(fly.position.y)+size, 89.9) * math.Pi / 180)
+		reach := int(math.Ceil(1 / cosLat))
+		for r := cell.row - 1; r <= cell.row+1 && !fly.mated; r++ {
+			for c := cell.col - reach; c <= cell.col+reach && !fly.mated; c++ {
+				for _, male := range males[mateCell{c, r}] {
+					if distanceKm(fly.position, male) <= radius {
+						fly.mated = true
+						mated++
+						break
+					}
+				}
+			}
+		}
+	}
+	return mated
+}
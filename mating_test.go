@@ -0,0 +1,121 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+type MateFliesTest struct {
+	name   string
+	radius float64
+	flies  []Fly
+	mated  []bool // after MateFlies, for each fly
+}
+
+func TestMateFlies(t *testing.T) {
+	home := OrderedPair{-75, 40}
+	female := Fly{position: home, stage: 5, isAlive: true, female: true}
+	male := func(km float64) Fly {
+		return Fly{position: kmEast(home, km), stage: 5, isAlive: true}
+	}
+	deadMale, nymphMale := male(0.05), male(0.05)
+	deadMale.isAlive = false
+	nymphMale.stage = 4
+	mated := female
+	mated.mated = true
+	nymph := female
+	nymph.stage = 4
+	// in the far north a degree of longitude is short, so the male is several grid columns away
+	north, northMale := female, male(0.09)
+	north.position = OrderedPair{-75, 70}
+	northMale.position = kmEast(north.position, 0.09)
+
+	tests := []MateFliesTest{
+		{name: "male within reach", radius: 0.1, flies: []Fly{female, male(0.09)}, mated: []bool{true, false}},
+		{name: "male out of reach", radius: 0.1, flies: []Fly{female, male(0.11)}, mated: []bool{false, false}},
+		{name: "male far away", radius: 0.1, flies: []Fly{female, male(50)}, mated: []bool{false, false}},
+		{name: "dead male", radius: 0.1, flies: []Fly{female, deadMale}, mated: []bool{false, false}},
+		{name: "nymph male", radius: 0.1, flies: []Fly{female, nymphMale}, mated: []bool{false, false}},
+		{name: "nymph female", radius: 0.1, flies: []Fly{nymph, male(0.05)}, mated: []bool{false, false}},
+		{name: "no male", radius: 0.1, flies: []Fly{female, female}, mated: []bool{false, false}},
+		{name: "one male, two females", radius: 0.1, flies: []Fly{female, male(0.05), female}, mated: []bool{true, false, true}},
+		{name: "already mated", radius: 0.1, flies: []Fly{mated, male(0.05)}, mated: []bool{true, false}},
+		{name: "far north", radius: 0.1, flies: []Fly{north, northMale}, mated: []bool{true, false}},
+		{name: "no mate needed", radius: 0, flies: []Fly{female, nymph, mated}, mated: []bool{true, false, true}},
+	}
+
+	for _, test := range tests {
+		want := 0
+		for i, m := range test.mated {
+			if m && !test.flies[i].mated {
+				want++
+			}
+		}
+		if n := MateFlies(test.flies, test.radius); n != want {
+			t.Errorf("MateFlies(%s) = %d, want %d", test.name, n, want)
+		}
+		for i, fly := range test.flies {
+			if fly.mated != test.mated[i] {
+				t.Errorf("MateFlies(%s): fly %d mated = %v, want %v", test.name, i, fly.mated, test.mated[i])
+			}
+		}
+	}
+}
+
+// TestMateFliesBruteForce compares the grid search with checking every pair.
+func TestMateFliesBruteForce(t *testing.T) {
+	var flies []Fly
+	for i := 0; i < 400; i++ {
+		x, y := -75+float64(i%20)*0.0011, 40+float64(i/20)*0.0013
+		flies = append(flies, Fly{position: OrderedPair{x, y}, stage: 5, isAlive: true, female: i%7 != 0})
+	}
+	want := make([]bool, len(flies))
+	for i, fly := range flies {
+		for _, other := range flies {
+			if fly.female && !other.female && distanceKm(fly.position, other.position) <= 0.15 {
+				want[i] = true
+			}
+		}
+	}
+
+	MateFlies(flies, 0.15)
+	for i, fly := range flies {
+		if fly.mated != want[i] {
+			t.Errorf("fly %d at %v mated = %v, want %v", i, fly.position, fly.mated, want[i])
+		}
+	}
+}
+
+func TestDrawSex(t *testing.T) {
+	n, females := 10000, 0
+	for i := 0; i < n; i++ {
+		if drawSex() {
+			females++
+		}
+	}
+	if share := float64(females) / float64(n); math.Abs(share-femaleShare) > 0.05 {
+		t.Errorf("drawSex gave %v females, want %v", share, femaleShare)
+	}
+}
+
+func TestLoadCheckpointSex(t *testing.T) {
+	tests := map[string]string{
+		"missing sex": `{"flies": [{"lon": -75, "lat": 40, "stage": 5, "alive": true}]}`,
+		"unknown sex": `{"flies": [{"lon": -75, "lat": 40, "stage": 5, "alive": true, "sex": "F"}]}`,
+		"egg sex":     `{"flies": [], "eggs": [{"lon": -75, "lat": 40, "alive": true, "sex": ""}]}`,
+	}
+	for name, contents := range tests {
+		if _, err := LoadCheckpoint(writeTestFile(t, "checkpoint.json", contents)); err == nil {
+			t.Errorf("LoadCheckpoint with a %s did not fail", name)
+		}
+	}
+
+	country, err := LoadCheckpoint(writeTestFile(t, "checkpoint.json",
+		`{"flies": [{"lon": -75, "lat": 40, "stage": 5, "alive": true, "sex": "female", "mated": true}, {"lon": -75, "lat": 40, "stage": 5, "alive": true, "sex": "male"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := country.flies; len(f) != 2 || !f[0].female || !f[0].mated || f[1].female {
+		t.Errorf("LoadCheckpoint flies = %+v, want a mated female and a male", f)
+	}
+}
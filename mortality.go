@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// DeathCause is what killed a fly.
+type DeathCause int
+
+// Causes of death, in the order their hazards are drawn each day (see ComputeMortality).
+const (
+	CauseNone         DeathCause = iota // the fly is alive, or has left the study region
+	CauseOldAge                         // the fly reached adultToDieThreshold degree-days
+	CauseCold                           // a day at or below the lethal minimum temperature, or the first hard frost
+	CauseIntervention                   // control in the intervention area and season
+	CausePathogen                       // predators and pathogens such as Beauveria bassiana
+	CauseStarvation                     // too few hosts where the fly feeds
+	CauseBackground                     // the rest of the mortality measured over each stage
+
+	numCauses = int(CauseBackground) + 1
+)
+
+// causeNames are the names of the causes of death, indexed by DeathCause.
+var causeNames = [numCauses]string{"none", "old age", "cold", "intervention", "pathogen", "starvation", "background"}
+
+// String returns the name of the cause.
+func (c DeathCause) String() string {
+	if c < 0 || int(c) >= numCauses {
+		return fmt.Sprintf("cause %d", int(c))
+	}
+	return causeNames[c]
+}
+
+// stageSurvival is the share of nymphs and adults that live through each stage (index 1 to 5), and stageDD the degree-days the
+// stage lasts. Background mortality spreads the deaths of a stage over its degree-days (see Mortality.BackgroundHazard).
+var (
+	stageSurvival = [6]float64{1, sRI1, sRI2, sRI3, sRI4, sRA}
+	stageDD       = [6]float64{0, instar1To2Threshold, instar2To3Threshold - instar1To2Threshold, instar3To4Threshold - instar2To3Threshold,
+		instar4ToAdultThreshold - instar3To4Threshold, adultToDieThreshold - instar4ToAdultThreshold}
+)
+
+// Mortality holds the daily hazards of the causes of death of nymphs and adults. Eggs die only by intervention.
+type Mortality struct {
+	coldLethal   float64 // minimum temperature at or below which nymphs and adults die, °C
+	starvation   float64 // daily chance of starving where there are no hosts; it falls to 0 with the host suitability
+	pathogen     float64 // daily chance of dying of predators or pathogens, on top of the background mortality
+	intervention *Intervention
+	set          bool // true once built by NewMortality; the zero value stands for DefaultMortality
+}
+
+// Intervention is a control programme: within its area, on the days of its season, every living fly, eggs included,
+// is killed with the daily probability rate.
+type Intervention struct {
+	area     Region
+	from, to int // season, as month*100+day (see ParseSeason)
+	rate     float64
+}
+
+// NewMortality checks the mortality rules. intervention may be nil for none.
+func NewMortality(coldLethal, starvation, pathogen float64, intervention *Intervention) (Mortality, error) {
+	if starvation < 0 || starvation > 1 || pathogen < 0 || pathogen > 1 {
+		return Mortality{}, fmt.Errorf("the daily chances of starvation and of death by pathogens must be from 0 to 1")
+	}
+	return Mortality{coldLethal: coldLethal, starvation: starvation, pathogen: pathogen, intervention: intervention, set: true}, nil
+}
+
+// DefaultMortality returns the mortality rules used when a country has none: nymphs and adults die at -2 °C (a hard freeze),
+// and 10% a day starve where there are no hosts, with no extra pathogen mortality and no intervention.
+func DefaultMortality() Mortality {
+	return Mortality{coldLethal: -2, starvation: 0.1, set: true}
+}
+
+// NewIntervention returns an intervention killing flies in an area with the daily probability rate, in the season
+// given as MM-DD:MM-DD (see ParseSeason).
+func NewIntervention(area Region, season string, rate float64) (*Intervention, error) {
+	if rate < 0 || rate > 1 {
+		return nil, fmt.Errorf("the daily kill rate of an intervention must be from 0 to 1")
+	}
+	from, to, err := ParseSeason(season)
+	if err != nil {
+		return nil, err
+	}
+	return &Intervention{area: area, from: from, to: to, rate: rate}, nil
+}
+
+// BackgroundHazard returns the chance that a fly of a stage dies of background causes on a day of the given degree-days.
+// The survival of the stage is spread over its degree-days, so a fly that lives through the stage survives it with the measured
+// rate however many days it lasts, and flies die faster on warm days, when they also develop faster.
+func BackgroundHazard(stage int, degreeDays float64) float64 {
+	if stage < 1 || stage > 5 || degreeDays <= 0 {
+		return 0
+	}
+	return 1 - math.Pow(stageSurvival[stage], degreeDays/stageDD[stage])
+}
+
+// Hazards returns the daily chance of death of a living fly from each cause, indexed by DeathCause, given the degree-days of
+// the day, the minimum temperature and the host suitability at the fly's position.
+func (m Mortality) Hazards(fly Fly, weather Weather, degreeDays, tmin, suitability float64) [numCauses]float64 {
+	var h [numCauses]float64
+	if fly.stage > 5 || fly.energy >= adultToDieThreshold {
+		h[CauseOldAge] = 1
+	}
+	if i := m.intervention; i != nil && inSeason(weather.date, i.from, i.to) && i.area.Contains(fly.position) {
+		h[CauseIntervention] = i.rate
+	}
+	if fly.stage < 1 {
+		return h
+	}
+	if tmin <= m.coldLethal {
+		h[CauseCold] = 1
+	}
+	h[CausePathogen] = m.pathogen
+	h[CauseStarvation] = m.starvation * (1 - math.Min(math.Max(suitability, 0), 1))
+	h[CauseBackground] = BackgroundHazard(fly.stage, degreeDays)
+	return h
+}
+
+// ComputeMortality decides whether a living fly dies on the day of the weather, which had the given degree-days, and of what.
+// The hazards of the causes (see Mortality.Hazards) are drawn one after another in the order of the causes, and the first that
+// strikes is the cause of death. It returns CauseNone if the fly survives the day.
+func ComputeMortality(fly *Fly, weather Weather, degreeDays, suitability float64, mortality Mortality) DeathCause {
+	tmin, _ := GetTemperature(fly.position, weather)
+	for cause, h := range mortality.Hazards(*fly, weather, degreeDays, tmin, suitability) {
+		if h > 0 && rand.Float64() < h {
+			return DeathCause(cause)
+		}
+	}
+	return CauseNone
+}
+
+// Kill marks a fly as dead of a cause on a date.
+func Kill(fly *Fly, cause DeathCause, date time.Time) {
+	fly.isAlive = false
+	fly.cause = cause
+	fly.died = date
+}
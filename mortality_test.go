@@ -0,0 +1,162 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+type HazardsTest struct {
+	name        string
+	fly         Fly
+	day         string // YYYY-MM-DD
+	degreeDays  float64
+	tmin        float64
+	suitability float64
+	hazards     map[DeathCause]float64 // the others are 0
+}
+
+// TestBackgroundHazard checks that the daily hazards compound to the survival of each stage, however its degree-days are split.
+func TestBackgroundHazard(t *testing.T) {
+	for stage := 1; stage <= 5; stage++ {
+		for _, days := range []int{1, 7, 40} {
+			survival := 1.0
+			for d := 0; d < days; d++ {
+				survival *= 1 - BackgroundHazard(stage, stageDD[stage]/float64(days))
+			}
+			if !closeTo(survival, stageSurvival[stage]) {
+				t.Errorf("stage %d over %d days: survival %v, want %v", stage, days, survival, stageSurvival[stage])
+			}
+		}
+		if h := BackgroundHazard(stage, 2*stageDD[stage]); !closeTo(1-h, stageSurvival[stage]*stageSurvival[stage]) {
+			t.Errorf("BackgroundHazard(%d) over twice the stage = %v, want %v", stage, h, 1-stageSurvival[stage]*stageSurvival[stage])
+		}
+	}
+
+	for _, stage := range []int{0, 6, -1} {
+		if h := BackgroundHazard(stage, 10); h != 0 {
+			t.Errorf("BackgroundHazard(%d, 10) = %v, want 0", stage, h)
+		}
+	}
+	if h := BackgroundHazard(3, 0); h != 0 {
+		t.Errorf("BackgroundHazard(3) on a day without degree-days = %v, want 0", h)
+	}
+}
+
+func TestHazards(t *testing.T) {
+	area := Region{bounds: Bounds{minLon: -80, minLat: 40, maxLon: -78, maxLat: 42}}
+	intervention, err := NewIntervention(area, "06-01:08-31", 0.3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMortality(-2, 0.1, 0.01, intervention)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inside, outside := OrderedPair{-79, 41}, OrderedPair{-75, 41}
+	tests := []HazardsTest{
+		{name: "nymph on a host", fly: Fly{position: outside, stage: 2, energy: 300}, day: "2022-07-01", degreeDays: 10, tmin: 15, suitability: 1,
+			hazards: map[DeathCause]float64{CausePathogen: 0.01, CauseBackground: BackgroundHazard(2, 10)}},
+		{name: "nymph away from hosts", fly: Fly{position: outside, stage: 2, energy: 300}, day: "2022-07-01", tmin: 15, suitability: 0.25,
+			hazards: map[DeathCause]float64{CausePathogen: 0.01, CauseStarvation: 0.075}},
+		{name: "suitability over 1", fly: Fly{position: outside, stage: 2, energy: 300}, day: "2022-07-01", tmin: 15, suitability: 3,
+			hazards: map[DeathCause]float64{CausePathogen: 0.01}},
+		{name: "freeze", fly: Fly{position: outside, stage: 5, energy: 700}, day: "2022-10-20", tmin: -2, suitability: 1,
+			hazards: map[DeathCause]float64{CauseCold: 1, CausePathogen: 0.01}},
+		{name: "old age", fly: Fly{position: outside, stage: 5, energy: adultToDieThreshold}, day: "2022-10-01", tmin: 5, suitability: 1,
+			hazards: map[DeathCause]float64{CauseOldAge: 1, CausePathogen: 0.01}},
+		{name: "past the adult stage", fly: Fly{position: outside, stage: 6, energy: 500}, day: "2022-10-01", tmin: 5, suitability: 1,
+			hazards: map[DeathCause]float64{CauseOldAge: 1, CausePathogen: 0.01}},
+		{name: "intervention", fly: Fly{position: inside, stage: 3, energy: 400}, day: "2022-08-31", tmin: 15, suitability: 1,
+			hazards: map[DeathCause]float64{CauseIntervention: 0.3, CausePathogen: 0.01}},
+		{name: "intervention out of season", fly: Fly{position: inside, stage: 3, energy: 400}, day: "2022-09-01", tmin: 15, suitability: 1,
+			hazards: map[DeathCause]float64{CausePathogen: 0.01}},
+		// eggs die only by intervention, whatever the weather and hosts
+		{name: "egg", fly: Fly{position: outside}, day: "2022-07-01", degreeDays: 10, tmin: -20, suitability: 0},
+		{name: "egg in the area", fly: Fly{position: inside}, day: "2022-07-01", tmin: -20, suitability: 0,
+			hazards: map[DeathCause]float64{CauseIntervention: 0.3}},
+	}
+
+	for _, test := range tests {
+		day, err := ParseDate(test.day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hazards := m.Hazards(test.fly, Weather{date: day}, test.degreeDays, test.tmin, test.suitability)
+		for cause, h := range hazards {
+			if want := test.hazards[DeathCause(cause)]; !closeTo(h, want) {
+				t.Errorf("Hazards(%s) of %s = %v, want %v", test.name, DeathCause(cause), h, want)
+			}
+		}
+	}
+}
+
+func TestNewMortality(t *testing.T) {
+	if m, err := NewMortality(-2, 0.1, 0, nil); err != nil || m != DefaultMortality() {
+		t.Errorf("NewMortality with the default rules = %+v, %v, want %+v", m, err, DefaultMortality())
+	}
+	for _, rates := range [][2]float64{{-0.1, 0}, {1.1, 0}, {0, -0.1}, {0, 1.1}} {
+		if _, err := NewMortality(-2, rates[0], rates[1], nil); err == nil {
+			t.Errorf("NewMortality(starvation %v, pathogen %v) did not fail", rates[0], rates[1])
+		}
+	}
+
+	area := Region{bounds: ContiguousUS()}
+	if i, err := NewIntervention(area, "12-01:02-28", 0.5); err != nil || i.from != 1201 || i.to != 228 || i.rate != 0.5 {
+		t.Errorf("NewIntervention(12-01:02-28, 0.5) = %+v, %v", i, err)
+	}
+	if _, err := NewIntervention(area, "06-01:08-31", 1.5); err == nil {
+		t.Errorf("NewIntervention with a rate of 1.5 did not fail")
+	}
+	if _, err := NewIntervention(area, "summer", 0.5); err == nil {
+		t.Errorf("NewIntervention with a season of summer did not fail")
+	}
+}
+
+func TestDeathCauseString(t *testing.T) {
+	tests := map[DeathCause]string{
+		CauseNone:       "none",
+		CauseOldAge:     "old age",
+		CauseCold:       "cold",
+		CauseBackground: "background",
+		DeathCause(-1):  "cause -1",
+		DeathCause(99):  "cause 99",
+	}
+	for cause, want := range tests {
+		if s := cause.String(); s != want {
+			t.Errorf("DeathCause(%d).String() = %q, want %q", int(cause), s, want)
+		}
+	}
+}
+
+// TestUpdateFlyDeath checks that a fly that dies records its cause and day, and that dead flies are left as they died.
+func TestUpdateFlyDeath(t *testing.T) {
+	position := OrderedPair{-79, 41}
+	hosts := NewTreePoints([]Tree{{position: position}})
+	frost := Weather{Quadrants: []Quadrant{{x: -80, y: 40, width: 2, height: 2, id: 0, temp: 5, minTemp: -5}}}.On(date(2022, 10, 20))
+
+	fly := UpdateFly(Fly{position: position, stage: 5, energy: 700, isAlive: true}, frost, hosts, BoundaryPolicy{}, Country{}.Movement(), DefaultMortality())
+	if fly.isAlive || fly.cause != CauseCold || !fly.died.Equal(date(2022, 10, 20)) {
+		t.Errorf("UpdateFly on a frost = alive %v, cause %v on %v, want dead of cold on 2022-10-20", fly.isAlive, fly.cause, fly.died)
+	}
+	if again := UpdateFly(fly, frost.On(date(2022, 10, 21)), hosts, BoundaryPolicy{}, Country{}.Movement(), DefaultMortality()); again != fly {
+		t.Errorf("UpdateFly changed a dead fly to %+v", again)
+	}
+
+	country := Country{deaths: [numCauses]int{CauseCold: 3, CauseOldAge: 1}}
+	if census := TakeCensus(country); census.deaths[CauseCold] != 3 || census.deaths[CauseOldAge] != 1 {
+		t.Errorf("TakeCensus deaths = %v, want 3 of cold and 1 of old age", census.deaths)
+	}
+
+	// with the default rules a warm day on a host kills only by chance
+	warm := Weather{Quadrants: []Quadrant{{x: -80, y: 40, width: 2, height: 2, id: 0, temp: 30, minTemp: 18}}}.On(date(2022, 7, 1))
+	deaths := 0
+	for i := 0; i < 1000; i++ {
+		if ComputeMortality(&Fly{position: position, stage: 3, energy: 400, isAlive: true}, warm, 14, 1, DefaultMortality()) != CauseNone {
+			deaths++
+		}
+	}
+	if want := 1000 * BackgroundHazard(3, 14); math.Abs(float64(deaths)-want) > 5*math.Sqrt(want)+5 {
+		t.Errorf("%d of 1000 instar 3 nymphs died on a warm day, want about %.0f", deaths, want)
+	}
+}
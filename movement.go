@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+)
+
+// Movement kernels, selectable with -movement by name or with the kernel field of a JSON file (see LoadMovement).
+const (
+	KernelUniform     = "uniform"     // the original random movement: a new heading every day and a step of 10 or 90 degrees, only on request
+	KernelCRW         = "crw"         // correlated random walk: exponential steps, turning angles from a von Mises distribution; the default
+	KernelLevy        = "levy"        // Lévy flight: power-law steps
+	KernelExponential = "exponential" // exponential dispersal kernel
+	Kernel2Dt         = "2dt"         // 2Dt dispersal kernel of Clark et al. (1999)
+	KernelLogNormal   = "lognormal"   // log-normal dispersal distances
+)
+
+// MovementKernel moves a fly on the days it moves at random.
+// Move returns the new position of a fly at position that last moved in the direction heading (radians counterclockwise from east),
+// and the direction of this move, which the fly keeps until the next one.
+type MovementKernel interface {
+	Move(position OrderedPair, heading float64) (OrderedPair, float64)
+}
+
+// UniformMovement is the original random movement: a fresh uniform heading every day and, in longitude/latitude degrees,
+// a step of far with probability farShare and of near otherwise.
+type UniformMovement struct {
+	near, far float64
+	farShare  float64
+}
+
+// Move steps in a new uniform direction.
+func (u UniformMovement) Move(position OrderedPair, heading float64) (OrderedPair, float64) {
+	step := u.near
+	if rand.Float64() < u.farShare {
+		step = u.far
+	}
+	angle := rand.Float64() * 2 * math.Pi
+	return OrderedPair{x: position.x + step*math.Cos(angle), y: position.y + step*math.Sin(angle)}, angle
+}
+
+// DispersalKernel draws the distance of a move in km from a distribution, and its direction either uniformly or, if kappa is positive,
+// by turning from the previous heading by an angle from a von Mises distribution with concentration kappa.
+// A larger kappa gives straighter paths; 0 gives a new uniform heading every day.
+type DispersalKernel struct {
+	name     string
+	distance func() float64
+	kappa    float64
+}
+
+// Move steps from the position by a drawn distance and direction.
+func (k DispersalKernel) Move(position OrderedPair, heading float64) (OrderedPair, float64) {
+	if k.kappa > 0 {
+		heading = math.Mod(heading+vonMises(k.kappa), 2*math.Pi)
+	} else {
+		heading = rand.Float64() * 2 * math.Pi
+	}
+	return offsetKm(position, k.distance(), heading), heading
+}
+
+// StageMovement holds the movement rules of each stage. Eggs do not move. Nymphs (instars 1 to 4) walk a few metres a day,
+// climbing the host the host layer points them to if it is within reach and searching in a random direction otherwise.
+// Adults fly or glide with the adult kernel, or head for a host (see ComputeMovement). They fly in the daylight hours warmer than
+// flightTemp, so the kernel's move stands for a day warm enough to fly from dawn to dusk and is shortened to the share of the
+// daylight hours the adult could fly (see FlightShare); on days whose maximum stays below flightTemp adults stay put.
+// If the weather has wind, a flying adult also drifts downwind for airtime seconds, scaled the same way (see WindDrift).
+type StageMovement struct {
+	adult      MovementKernel // random movement of adults
+	nymphWalk  float64        // farthest a nymph walks in a day, km
+	flightTemp float64        // lowest temperature at which adults fly, °C
+	airtime    float64        // seconds a flying adult is carried by the wind each day
+}
+
+// DefaultMovement returns the movement rules used when a country has none: the crw kernel with its default parameters for adults
+// (see ParseMovement), nymph walks of up to 10 m and flight on days of at least 15 °C.
+func DefaultMovement() StageMovement {
+	c := defaultMovement(KernelCRW)
+	return StageMovement{adult: crwKernel(c.Step, c.Kappa), nymphWalk: 0.01, flightTemp: 15}
+}
+
+// NewStageMovement checks the movement rules of the stages.
+func NewStageMovement(adult MovementKernel, nymphWalk, flightTemp, airtime float64) (StageMovement, error) {
+	if adult == nil {
+		return StageMovement{}, fmt.Errorf("the adults need a movement kernel")
+	}
+	if nymphWalk < 0 {
+		return StageMovement{}, fmt.Errorf("the nymph walk cannot be negative")
+	}
+	if airtime < 0 {
+		return StageMovement{}, fmt.Errorf("the time adults are carried by the wind cannot be negative")
+	}
+	return StageMovement{adult: adult, nymphWalk: nymphWalk, flightTemp: flightTemp, airtime: airtime}, nil
+}
+
+// Move returns where a fly ends the day, given the host layer and the weather of the day. Dead flies stay where they are.
+func (m StageMovement) Move(fly *Fly, hosts HostLayer, weather Weather) OrderedPair {
+	switch {
+	case !fly.isAlive || fly.stage <= 0 || fly.stage > 5:
+		return fly.position
+	case fly.stage < 5:
+		return NymphWalk(fly, hosts, m.nymphWalk)
+	}
+	tmin, tmax := GetTemperature(fly.position, weather)
+	flight := FlightShare(tmin, tmax, m.flightTemp)
+	if flight <= 0 {
+		return fly.position
+	}
+	return ComputeMovement(fly, hosts, m.adult, weather, m.airtime, flight)
+}
+
+// FlightShare returns the share of the daylight hours of a day with the given minimum and maximum temperatures that are at or above
+// flightTemp. The temperature is taken to rise from tmin at dawn to tmax at midday and fall back to tmin at dusk as a half sine wave,
+// so the warm hours are those around midday. It is 1 if the minimum already reaches flightTemp, and 0 if the maximum does not.
+func FlightShare(tmin, tmax, flightTemp float64) float64 {
+	switch {
+	case tmax < flightTemp:
+		return 0
+	case tmin >= flightTemp || tmax <= tmin:
+		return 1
+	}
+	// sin(pi*s) >= a for the share of the day s from asin(a)/pi to 1-asin(a)/pi
+	a := (flightTemp - tmin) / (tmax - tmin)
+	return 1 - 2*math.Asin(a)/math.Pi
+}
+
+// distanceKm returns the distance in km between two positions, on a locally flat earth whose degree of longitude is measured at the
+// mean latitude of the two.
+func distanceKm(a, b OrderedPair) float64 {
+	kmPerDegree := math.Pi / 180 * earthRadius
+	dx := (b.x - a.x) * kmPerDegree * math.Cos((a.y+b.y)/2*math.Pi/180)
+	dy := (b.y - a.y) * kmPerDegree
+	return math.Sqrt(dx*dx + dy*dy)
+}
+
+// offsetKm returns the position distance km away from position in the direction heading, on the same flat earth as distanceKm,
+// so that distanceKm measures the move as exactly distance km and the displacement of a fly is what its kernel drew.
+func offsetKm(position OrderedPair, distance, heading float64) OrderedPair {
+	kmPerDegree := math.Pi / 180 * earthRadius
+	y := position.y + distance*math.Sin(heading)/kmPerDegree
+	cosLat := math.Max(math.Cos((position.y+y)/2*math.Pi/180), 0.01)
+	return OrderedPair{
+		x: position.x + distance*math.Cos(heading)/(kmPerDegree*cosLat),
+		y: y,
+	}
+}
+
+// vonMises draws an angle in radians from a von Mises distribution centred on 0 with concentration kappa,
+// by the rejection method of Best and Fisher (1979).
+func vonMises(kappa float64) float64 {
+	tau := 1 + math.Sqrt(1+4*kappa*kappa)
+	rho := (tau - math.Sqrt(2*tau)) / (2 * kappa)
+	r := (1 + rho*rho) / (2 * rho)
+	for {
+		z := math.Cos(math.Pi * rand.Float64())
+		f := (1 + r*z) / (r + z)
+		c := kappa * (r - f)
+		u := rand.Float64()
+		if c*(2-c)-u > 0 || math.Log(c/u)+1-c >= 0 {
+			if rand.Float64() < 0.5 {
+				return -math.Acos(f)
+			}
+			return math.Acos(f)
+		}
+	}
+}
+
+// movementConfig is the JSON form of a movement kernel. Distances are in km, except for the uniform kernel's degrees.
+type movementConfig struct {
+	Kernel   string  `json:"kernel"`
+	Near     float64 `json:"near"`      // uniform: short step, degrees
+	Far      float64 `json:"far"`       // uniform: long step, degrees
+	FarShare float64 `json:"far_share"` // uniform: probability of the long step
+	Step     float64 `json:"step"`      // crw: mean step length
+	Kappa    float64 `json:"kappa"`     // concentration of the turning angle, 0 for uniform headings
+	Min      float64 `json:"min"`       // levy: shortest step
+	Max      float64 `json:"max"`       // levy: longest step
+	Mu       float64 `json:"mu"`        // levy: exponent of the step length density, between 1 and 3
+	Scale    float64 `json:"scale"`     // exponential: scale of the kernel, half the mean distance
+	U        float64 `json:"u"`         // 2dt: scale, km²
+	P        float64 `json:"p"`         // 2dt: shape; smaller is fatter-tailed
+	Median   float64 `json:"median"`    // lognormal: median distance
+	Sigma    float64 `json:"sigma"`     // lognormal: standard deviation of the log distance
+}
+
+// defaultMovement returns the parameters of a kernel chosen by name alone.
+func defaultMovement(kernel string) movementConfig {
+	return movementConfig{
+		Kernel: kernel,
+		Near:   10, Far: 90, FarShare: 0.7,
+		Step: 0.1, Kappa: map[string]float64{KernelCRW: 2}[kernel],
+		Min: 0.01, Max: 50, Mu: 2,
+		Scale: 0.1,
+		U:     0.01, P: 1,
+		Median: 0.1, Sigma: 1,
+	}
+}
+
+// ParseMovement reads the movement kernel given with -movement: a JSON file (see LoadMovement), or the name of a kernel with its
+// default parameters: crw (mean step 0.1 km, kappa 2), levy (steps of 0.01 to 50 km, mu 2), exponential (scale 0.1 km),
+// 2dt (u 0.01 km², p 1), lognormal (median 0.1 km, sigma 1) or uniform (the original steps of 10 or 90 degrees, which carry most
+// flies out of any study region within days and are kept only to reproduce the original runs).
+func ParseMovement(spec string) (MovementKernel, error) {
+	if strings.HasSuffix(strings.ToLower(spec), ".json") {
+		return LoadMovement(spec)
+	}
+	return NewMovementKernel(defaultMovement(strings.ToLower(strings.TrimSpace(spec))))
+}
+
+// LoadMovement reads a movement kernel from a JSON file, such as
+//
+//	{"kernel": "crw", "step": 0.05, "kappa": 4}
+//	{"kernel": "2dt", "u": 0.02, "p": 0.8}
+//
+// Parameters left out take the kernel's defaults (see ParseMovement).
+func LoadMovement(filename string) (MovementKernel, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading movement kernel: %v", err)
+	}
+	var named struct {
+		Kernel string `json:"kernel"`
+	}
+	if err := json.Unmarshal(data, &named); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	config := defaultMovement(strings.ToLower(named.Kernel))
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	config.Kernel = strings.ToLower(config.Kernel)
+	kernel, err := NewMovementKernel(config)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return kernel, nil
+}
+
+// NewMovementKernel checks the parameters of a kernel and returns it.
+func NewMovementKernel(c movementConfig) (MovementKernel, error) {
+	if c.Kappa < 0 {
+		return nil, fmt.Errorf("kappa cannot be negative")
+	}
+	switch c.Kernel {
+	case KernelUniform:
+		if c.Near < 0 || c.Far < 0 || c.FarShare < 0 || c.FarShare > 1 {
+			return nil, fmt.Errorf("the uniform kernel needs steps of at least 0 and a far_share between 0 and 1")
+		}
+		return UniformMovement{near: c.Near, far: c.Far, farShare: c.FarShare}, nil
+	case KernelCRW:
+		if c.Step <= 0 {
+			return nil, fmt.Errorf("the crw kernel needs a positive step")
+		}
+		return crwKernel(c.Step, c.Kappa), nil
+	case KernelLevy:
+		if c.Min <= 0 || c.Max <= c.Min || c.Mu <= 1 || c.Mu > 3 {
+			return nil, fmt.Errorf("the levy kernel needs 0 < min < max and 1 < mu <= 3")
+		}
+		// inverse of the distribution function of a power law truncated at max
+		a, b := math.Pow(c.Min, 1-c.Mu), math.Pow(c.Max, 1-c.Mu)
+		return DispersalKernel{name: c.Kernel, kappa: c.Kappa, distance: func() float64 {
+			return math.Pow(a-rand.Float64()*(a-b), 1/(1-c.Mu))
+		}}, nil
+	case KernelExponential:
+		if c.Scale <= 0 {
+			return nil, fmt.Errorf("the exponential kernel needs a positive scale")
+		}
+		// the distance from the source of a 2D exponential kernel is gamma distributed with shape 2
+		return DispersalKernel{name: c.Kernel, kappa: c.Kappa, distance: func() float64 {
+			return c.Scale * (rand.ExpFloat64() + rand.ExpFloat64())
+		}}, nil
+	case Kernel2Dt:
+		if c.U <= 0 || c.P <= 0 {
+			return nil, fmt.Errorf("the 2dt kernel needs a positive u and p")
+		}
+		// inverse of the distance distribution function 1 - (1 + r²/u)^-p
+		return DispersalKernel{name: c.Kernel, kappa: c.Kappa, distance: func() float64 {
+			return math.Sqrt(c.U * (math.Pow(1-rand.Float64(), -1/c.P) - 1))
+		}}, nil
+	case KernelLogNormal:
+		if c.Median <= 0 || c.Sigma <= 0 {
+			return nil, fmt.Errorf("the lognormal kernel needs a positive median and sigma")
+		}
+		return DispersalKernel{name: c.Kernel, kappa: c.Kappa, distance: func() float64 {
+			return c.Median * math.Exp(c.Sigma*rand.NormFloat64())
+		}}, nil
+	}
+	return nil, fmt.Errorf("unknown movement kernel %q (want uniform, crw, levy, exponential, 2dt, lognormal or a .json file)", c.Kernel)
+}
+
+// crwKernel returns the correlated random walk with exponential steps of mean step km and turning angles of concentration kappa.
+func crwKernel(step, kappa float64) DispersalKernel {
+	return DispersalKernel{name: KernelCRW, kappa: kappa, distance: func() float64 {
+		return rand.ExpFloat64() * step
+	}}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"testing"
+)
+
+type ParseMovementTest struct {
+	spec   string
+	kernel string
+	fails  bool
+}
+
+type KernelDistanceTest struct {
+	config movementConfig
+	median float64 // median distance of a move, km
+	mean   float64 // mean distance of a move, km; 0 to skip
+}
+
+// besselRatio returns I1(kappa)/I0(kappa), the mean cosine of a von Mises angle, from the power series of the Bessel functions.
+func besselRatio(kappa float64) float64 {
+	i0, i1 := 0.0, 0.0
+	term := 1.0 // (kappa/2)^2k / (k!)^2
+	for k := 0; k < 100; k++ {
+		i0 += term
+		i1 += term * kappa / 2 / float64(k+1)
+		term *= kappa * kappa / 4 / float64((k+1)*(k+1))
+	}
+	return i1 / i0
+}
+
+func TestVonMises(t *testing.T) {
+	const n = 20000
+	for _, kappa := range []float64{0.1, 1, 2, 10, 100} {
+		sumCos, sumSin := 0.0, 0.0
+		for i := 0; i < n; i++ {
+			angle := vonMises(kappa)
+			if angle < -math.Pi || angle > math.Pi {
+				t.Fatalf("vonMises(%v) drew %v, outside -pi to pi", kappa, angle)
+			}
+			sumCos += math.Cos(angle)
+			sumSin += math.Sin(angle)
+		}
+		// the standard error of the mean cosine is at most 1/sqrt(n)
+		if mean, want := sumCos/n, besselRatio(kappa); math.Abs(mean-want) > 4/math.Sqrt(n) {
+			t.Errorf("vonMises(%v) mean cosine = %v, want %v", kappa, mean, want)
+		}
+		if math.Abs(sumSin/n) > 4/math.Sqrt(n) {
+			t.Errorf("vonMises(%v) mean sine = %v, want 0", kappa, sumSin/n)
+		}
+	}
+}
+
+func TestOffsetKm(t *testing.T) {
+	for _, start := range []OrderedPair{{-75, 40}, {0, 0}, {10, 70}} {
+		for _, heading := range []float64{0, 1, math.Pi / 2, 3, -2} {
+			end := offsetKm(start, 12.5, heading)
+			if d := distanceKm(start, end); !closeTo(d, 12.5) {
+				t.Errorf("distanceKm(%v, offsetKm(%v, 12.5, %v)) = %v, want 12.5", start, start, heading, d)
+			}
+			if angle := math.Atan2(end.y-start.y, (end.x-start.x)*math.Cos((start.y+end.y)/2*math.Pi/180)); math.Abs(math.Remainder(angle-heading, 2*math.Pi)) > 1e-6 {
+				t.Errorf("offsetKm(%v, 12.5, %v) moved towards %v", start, heading, angle)
+			}
+		}
+	}
+}
+
+func TestParseMovement(t *testing.T) {
+	tests := []ParseMovementTest{
+		{spec: "uniform", kernel: "uniform"},
+		{spec: " CRW ", kernel: KernelCRW},
+		{spec: "levy", kernel: KernelLevy},
+		{spec: "exponential", kernel: KernelExponential},
+		{spec: "2dt", kernel: Kernel2Dt},
+		{spec: "lognormal", kernel: KernelLogNormal},
+		{spec: "brownian", fails: true},
+		{spec: "missing.json", fails: true},
+	}
+	for _, test := range tests {
+		kernel, err := ParseMovement(test.spec)
+		if (err != nil) != test.fails {
+			t.Errorf("ParseMovement(%q) error = %v, want error %v", test.spec, err, test.fails)
+			continue
+		}
+		if test.fails {
+			continue
+		}
+		name := KernelUniform
+		if k, ok := kernel.(DispersalKernel); ok {
+			name = k.name
+		}
+		if name != test.kernel {
+			t.Errorf("ParseMovement(%q) = %s kernel, want %s", test.spec, name, test.kernel)
+		}
+	}
+	if k, _ := ParseMovement("crw"); k.(DispersalKernel).kappa != 2 {
+		t.Errorf("the crw kernel has kappa %v, want 2", k.(DispersalKernel).kappa)
+	}
+}
+
+func TestLoadMovement(t *testing.T) {
+	tests := map[string]bool{
+		`{"kernel": "crw", "step": 0.05, "kappa": 4}`: false,
+		`{"kernel": "2DT", "u": 0.02, "p": 0.8}`:      false,
+		`{"kernel": "uniform", "far_share": 0}`:       false,
+		`{"kernel": "crw", "step": 0}`:                true,
+		`{"kernel": "crw", "kappa": -1}`:              true,
+		`{"kernel": "levy", "min": 1, "max": 0.5}`:    true,
+		`{"kernel": "levy", "mu": 4}`:                 true,
+		`{"kernel": "exponential", "scale": -1}`:      true,
+		`{"kernel": "2dt", "p": 0}`:                   true,
+		`{"kernel": "lognormal", "sigma": 0}`:         true,
+		`{"kernel": "uniform", "far_share": 2}`:       true,
+		`{"step": 0.05}`:                              true,
+		`{"kernel": "crw", "step": "far"}`:            true,
+	}
+	for json, fails := range tests {
+		kernel, err := LoadMovement(writeTestFile(t, "movement.json", json))
+		if (err != nil) != fails {
+			t.Errorf("LoadMovement(%s) error = %v, want error %v", json, err, fails)
+			continue
+		}
+		if json == `{"kernel": "crw", "step": 0.05, "kappa": 4}` && kernel.(DispersalKernel).kappa != 4 {
+			t.Errorf("LoadMovement(%s) kappa = %v, want 4", json, kernel.(DispersalKernel).kappa)
+		}
+	}
+}
+
+// TestDefaultMovement checks that a country without movement rules moves its adults in km, not the original degree steps.
+func TestDefaultMovement(t *testing.T) {
+	m := Country{}.Movement()
+	k, ok := m.adult.(DispersalKernel)
+	if !ok || k.name != KernelCRW || k.kappa != 2 || m.nymphWalk != 0.01 || m.flightTemp != 15 {
+		t.Fatalf("Country{}.Movement() = %+v, want the crw kernel, 10 m nymph walks and flight from 15 °C", m)
+	}
+	start := OrderedPair{-75, 40}
+	far := 0
+	for i := 0; i < 1000; i++ {
+		if end, _ := k.Move(start, 0); distanceKm(start, end) > 2 {
+			far++
+		}
+	}
+	// exponential steps of mean 0.1 km are longer than 2 km once in 485 million draws
+	if far > 0 {
+		t.Errorf("the default kernel moved %d of 1000 adults more than 2 km", far)
+	}
+}
+
+// TestKernelDistances compares the distances the kernels draw with the medians and means of their distributions.
+func TestKernelDistances(t *testing.T) {
+	config := func(kernel string, change func(*movementConfig)) movementConfig {
+		c := defaultMovement(kernel)
+		change(&c)
+		return c
+	}
+	tests := []KernelDistanceTest{
+		{config: config(KernelCRW, func(c *movementConfig) { c.Step = 2 }), median: 2 * math.Ln2, mean: 2},
+		// the median of a gamma distribution of shape 2 and scale 1 is about 1.678
+		{config: config(KernelExponential, func(c *movementConfig) { c.Scale = 0.5 }), median: 0.5 * 1.6783, mean: 1},
+		{config: config(Kernel2Dt, func(c *movementConfig) { c.U, c.P = 4, 2 }), median: math.Sqrt(4 * (math.Sqrt2 - 1))},
+		{config: config(KernelLogNormal, func(c *movementConfig) { c.Median, c.Sigma = 3, 0.5 }), median: 3, mean: 3 * math.Exp(0.125)},
+		// a power law of exponent 2 between 1 and 100 has the distribution function (1 - 1/r) / 0.99
+		{config: config(KernelLevy, func(c *movementConfig) { c.Min, c.Max, c.Mu = 1, 100, 2 }), median: 1 / (1 - 0.495)},
+	}
+
+	const n = 20000
+	for _, test := range tests {
+		kernel, err := NewMovementKernel(test.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := OrderedPair{-75, 40}
+		distances := make([]float64, n)
+		sum := 0.0
+		for i := range distances {
+			end, _ := kernel.Move(start, 0)
+			distances[i] = distanceKm(start, end)
+			sum += distances[i]
+			if test.config.Kernel == KernelLevy && (distances[i] < 1-1e-9 || distances[i] > 100+1e-9) {
+				t.Fatalf("levy kernel moved %v km, outside 1 to 100", distances[i])
+			}
+		}
+		sort.Float64s(distances)
+		if median := distances[n/2]; math.Abs(median-test.median) > 0.05*test.median {
+			t.Errorf("%s kernel median distance = %v, want %v", test.config.Kernel, median, test.median)
+		}
+		if mean := sum / n; test.mean > 0 && math.Abs(mean-test.mean) > 0.05*test.mean {
+			t.Errorf("%s kernel mean distance = %v, want %v", test.config.Kernel, mean, test.mean)
+		}
+	}
+}
+
+// TestKernelHeadings checks that a positive kappa keeps a fly near its previous heading, and that kappa 0 forgets it.
+func TestKernelHeadings(t *testing.T) {
+	for _, kappa := range []float64{0, 5} {
+		c := defaultMovement(KernelCRW)
+		c.Kappa = kappa
+		kernel, err := NewMovementKernel(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		const n = 5000
+		sumCos := 0.0
+		for i := 0; i < n; i++ {
+			_, heading := kernel.Move(OrderedPair{-75, 40}, 1)
+			sumCos += math.Cos(heading - 1)
+		}
+		if mean, want := sumCos/n, map[float64]float64{0: 0, 5: besselRatio(5)}[kappa]; math.Abs(mean-want) > 0.05 {
+			t.Errorf("kappa %v: mean cosine of the turn = %v, want %v", kappa, mean, want)
+		}
+	}
+
+	// the uniform kernel steps near or far in degrees
+	u := UniformMovement{near: 10, far: 90, farShare: 0.7}
+	far := 0
+	for i := 0; i < 1000; i++ {
+		end, heading := u.Move(OrderedPair{0, 0}, 0)
+		step := math.Hypot(end.x, end.y)
+		if math.Abs(step-10) > 1e-9 && math.Abs(step-90) > 1e-9 || heading < 0 || heading >= 2*math.Pi {
+			t.Fatalf("uniform kernel stepped %v in the direction %v", step, heading)
+		}
+		if step > 50 {
+			far++
+		}
+	}
+	if far < 600 || far > 800 {
+		t.Errorf("uniform kernel made %d far steps of 1000, want about 700", far)
+	}
+}
+
+type FlightShareTest struct {
+	tmin, tmax, flightTemp float64
+	result                 float64
+}
+
+// eastKernel moves every fly a fixed distance east.
+type eastKernel float64
+
+func (k eastKernel) Move(position OrderedPair, heading float64) (OrderedPair, float64) {
+	return offsetKm(position, float64(k), 0), 0
+}
+
+func TestFlightShare(t *testing.T) {
+	tests := []FlightShareTest{
+		{tmin: 5, tmax: 14, flightTemp: 15, result: 0},
+		{tmin: 16, tmax: 30, flightTemp: 15, result: 1},
+		{tmin: 15, tmax: 15, flightTemp: 15, result: 1},
+		{tmin: 10, tmax: 30, flightTemp: 20, result: 2.0 / 3}, // warm enough for the middle two thirds of the daylight
+		{tmin: 10, tmax: 30, flightTemp: 10 + 20*math.Sqrt(0.5), result: 0.5},
+		{tmin: 10, tmax: 20, flightTemp: 20, result: 0}, // only at the peak of midday
+		{tmin: 10, tmax: 20, flightTemp: 10, result: 1},
+	}
+	for _, test := range tests {
+		if result := FlightShare(test.tmin, test.tmax, test.flightTemp); math.Abs(result-test.result) > 1e-9 {
+			t.Errorf("FlightShare(%v, %v, %v) = %v, want %v", test.tmin, test.tmax, test.flightTemp, result, test.result)
+		}
+	}
+}
+
+// TestStageMovement checks what each stage does in a day, and that adults fly only the warm share of the daylight hours.
+func TestStageMovement(t *testing.T) {
+	start := OrderedPair{-79, 41}
+	weather := func(tmin, tmax float64) Weather {
+		return Weather{Quadrants: []Quadrant{{x: -80, y: 40, width: 2, height: 2, id: 0, temp: tmax, minTemp: tmin}}}
+	}
+	m, err := NewStageMovement(eastKernel(9), 0.01, 20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the host is where the flies are, so directed moves stay put
+	hosts := NewTreePoints([]Tree{{position: start}})
+
+	for stage, want := range map[int]float64{0: 0, 6: 0} {
+		if end := m.Move(&Fly{position: start, stage: stage, isAlive: true}, hosts, weather(10, 30)); distanceKm(start, end) != want {
+			t.Errorf("stage %d moved to %v", stage, end)
+		}
+	}
+	if end := m.Move(&Fly{position: start, stage: 5}, hosts, weather(10, 30)); end != start {
+		t.Errorf("a dead adult moved to %v", end)
+	}
+
+	// a nymph climbs a host within reach, and searches otherwise
+	near := offsetKm(start, 0.005, 1)
+	if end := m.Move(&Fly{position: start, stage: 2, isAlive: true}, NewTreePoints([]Tree{{position: near}}), weather(10, 30)); end != near {
+		t.Errorf("a nymph 5 m from a host moved to %v, want the host at %v", end, near)
+	}
+	far := offsetKm(start, 1, 1)
+	if end := m.Move(&Fly{position: start, stage: 2, isAlive: true}, NewTreePoints([]Tree{{position: far}}), weather(10, 30)); math.Abs(distanceKm(start, end)-0.01) > 1e-6 {
+		t.Errorf("a nymph 1 km from a host walked %v km, want 0.01", distanceKm(start, end))
+	}
+
+	// adults stay put on a day too cold to fly, and fly two thirds of the kernel's move when two thirds of the daylight is warm enough
+	if end := m.Move(&Fly{position: start, stage: 5, isAlive: true}, hosts, weather(5, 19)); end != start {
+		t.Errorf("an adult flew to %v on a day below the flight temperature", end)
+	}
+	flights := 0
+	for i := 0; i < 100; i++ {
+		end := m.Move(&Fly{position: start, stage: 5, isAlive: true}, hosts, weather(10, 30))
+		switch d := distanceKm(start, end); {
+		case d == 0:
+		case math.Abs(d-6) < 0.01:
+			flights++
+		default:
+			t.Fatalf("an adult moved %v km, want 0 or 6", d)
+		}
+	}
+	if flights == 0 {
+		t.Errorf("no adult flew in 100 warm days")
+	}
+
+	if _, err := NewStageMovement(nil, 0.01, 15, 0); err == nil {
+		t.Errorf("NewStageMovement without an adult kernel did not fail")
+	}
+	if _, err := NewStageMovement(eastKernel(1), -1, 15, 0); err == nil {
+		t.Errorf("NewStageMovement with a negative nymph walk did not fail")
+	}
+	if _, err := NewStageMovement(eastKernel(1), 0.01, 15, -5); err == nil {
+		t.Errorf("NewStageMovement with a negative airtime did not fail")
+	}
+}
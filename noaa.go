@@ -0,0 +1,352 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ClimateSeries is one block of a NOAA Climate at a Glance CSV file: a title line, metadata lines
+// (units, base period, missing-value sentinel), a Date,Value,Anomaly header and one row per date.
+// Values are converted to metric units when the file is read (see unitConversion), so units is always °C or mm.
+type ClimateSeries struct {
+	title      string
+	region     string // state or station, e.g. "Pennsylvania"
+	period     string // months the values cover, e.g. "May-June"
+	variable   string // e.g. "Maximum Temperature"
+	units      string // units after conversion: "degC" or "mm"
+	fileUnits  string // units as written in the file, e.g. "Degrees Fahrenheit"
+	baseStart  int    // first year of the base period of the anomalies
+	baseEnd    int    // last year of the base period of the anomalies
+	missing    float64
+	values     []ClimateValue
+	lineNumber int // line of the title, for error messages
+}
+
+// ClimateValue is one row of a climate series. Dates are the first day of the month in the Date column.
+type ClimateValue struct {
+	date    time.Time
+	value   float64 // NaN if the file holds the missing-value sentinel
+	anomaly float64 // NaN if missing or not given
+}
+
+// climateVariables are the variable names recognised at the end of a title line.
+var climateVariables = []string{
+	"Average Temperature", "Maximum Temperature", "Minimum Temperature",
+	"Precipitation", "Heating Degree Days", "Cooling Degree Days",
+}
+
+// ReadClimateFile reads every series of a NOAA Climate at a Glance CSV file.
+func ReadClimateFile(filePath string) ([]ClimateSeries, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening climate file: %v", err)
+	}
+	defer file.Close()
+
+	series, err := ParseClimateCSV(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filePath, err)
+	}
+	return series, nil
+}
+
+// ParseClimateCSV parses NOAA Climate at a Glance CSV data. A file can hold several blocks separated by empty rows,
+// and every block can hold any number of dates. Both title styles are understood:
+// "Pennsylvania May-June Maximum Temperature" and "Pennsylvania, Maximum Temperature, May-June".
+func ParseClimateCSV(r io.Reader) ([]ClimateSeries, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var all []ClimateSeries
+	var current *ClimateSeries
+	inData := false
+
+	finish := func() error {
+		if current == nil {
+			return nil
+		}
+		if current.fileUnits == "" {
+			return fmt.Errorf("line %d: series %q has no Units line", current.lineNumber, current.title)
+		}
+		if !inData {
+			return fmt.Errorf("line %d: series %q has no Date,Value header", current.lineNumber, current.title)
+		}
+		all = append(all, *current)
+		current = nil
+		inData = false
+		return nil
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		first := strings.TrimSpace(record[0])
+		if isEmptyRecord(record) {
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// a block that is not followed by an empty row ends at the next title
+		if inData && (first == "" || first[0] < '0' || first[0] > '9') {
+			if err := finish(); err != nil {
+				return nil, err
+			}
+		}
+
+		switch {
+		case current == nil:
+			// a new block starts with its title
+			series, err := parseClimateTitle(record)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			series.lineNumber = line
+			series.missing = math.NaN()
+			current = &series
+
+		case !inData && strings.Contains(first, ":"):
+			if err := current.parseMetadata(first); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+
+		case !inData && strings.EqualFold(first, "Date"):
+			if len(record) < 2 || !strings.EqualFold(strings.TrimSpace(record[1]), "Value") {
+				return nil, fmt.Errorf("line %d: expected a Date,Value header, got %q", line, strings.Join(record, ","))
+			}
+			inData = true
+
+		case inData:
+			value, err := current.parseRow(record)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			current.values = append(current.values, value)
+
+		default:
+			return nil, fmt.Errorf("line %d: unexpected line %q in the header of series %q", line, strings.Join(record, ","), current.title)
+		}
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+
+	if len(all) == 0 {
+		return nil, fmt.Errorf("no climate series found")
+	}
+	return all, nil
+}
+
+// isEmptyRecord reports whether every field of a CSV record is blank, as in the ",," rows between blocks.
+func isEmptyRecord(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// parseClimateTitle splits a title line into region, period and variable.
+func parseClimateTitle(record []string) (ClimateSeries, error) {
+	var parts []string
+	for _, field := range record {
+		if field = strings.TrimSpace(field); field != "" {
+			parts = append(parts, field)
+		}
+	}
+	title := strings.Join(parts, ", ")
+	series := ClimateSeries{title: title}
+
+	// "Region, Variable, Period" with the title spread over several fields or quoted
+	if len(parts) == 1 && strings.Contains(parts[0], ", ") {
+		parts = strings.Split(parts[0], ", ")
+	}
+	if len(parts) >= 3 {
+		series.region, series.variable, series.period = parts[0], parts[1], parts[2]
+		return series, nil
+	}
+
+	// "Region Period Variable"
+	for _, variable := range climateVariables {
+		if !strings.HasSuffix(title, " "+variable) {
+			continue
+		}
+		series.variable = variable
+		words := strings.Fields(strings.TrimSuffix(title, " "+variable))
+		if len(words) == 0 {
+			return ClimateSeries{}, fmt.Errorf("title %q does not name a region", title)
+		}
+		if last := words[len(words)-1]; len(words) > 1 && isClimatePeriod(last) {
+			series.period = last
+			words = words[:len(words)-1]
+		}
+		series.region = strings.Join(words, " ")
+		return series, nil
+	}
+
+	return ClimateSeries{}, fmt.Errorf("cannot recognise the variable in title %q (expected one of %s)", title, strings.Join(climateVariables, ", "))
+}
+
+// isClimatePeriod reports whether a word names a month or a range of months, such as "May-June" or "12-Month".
+func isClimatePeriod(word string) bool {
+	for _, part := range strings.Split(word, "-") {
+		if _, err := strconv.Atoi(part); err == nil || part == "Month" {
+			continue
+		}
+		if _, err := time.Parse("January", part); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// parseMetadata reads a "Key: value" line of the block header.
+func (s *ClimateSeries) parseMetadata(line string) error {
+	key, value, _ := strings.Cut(line, ":")
+	key = strings.ToLower(strings.TrimSpace(key))
+	value = strings.TrimSpace(value)
+
+	switch key {
+	case "units":
+		s.fileUnits = value
+		units, _, err := unitConversion(value)
+		if err != nil {
+			return err
+		}
+		s.units = units
+	case "base period":
+		start, end, found := strings.Cut(value, "-")
+		var err1, err2 error
+		s.baseStart, err1 = strconv.Atoi(strings.TrimSpace(start))
+		s.baseEnd, err2 = strconv.Atoi(strings.TrimSpace(end))
+		if !found || err1 != nil || err2 != nil {
+			return fmt.Errorf("invalid base period %q, expected YYYY-YYYY", value)
+		}
+	case "missing":
+		missing, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid missing-value sentinel %q", value)
+		}
+		s.missing = missing
+	}
+	// other metadata lines are ignored
+	return nil
+}
+
+// parseRow reads a Date,Value[,Anomaly] row, applying the missing-value sentinel and converting units.
+func (s *ClimateSeries) parseRow(record []string) (ClimateValue, error) {
+	if s.fileUnits == "" {
+		return ClimateValue{}, fmt.Errorf("series %q has no Units line", s.title)
+	}
+	if len(record) < 2 {
+		return ClimateValue{}, fmt.Errorf("expected Date,Value, got %q", strings.Join(record, ","))
+	}
+
+	date, err := parseClimateDate(strings.TrimSpace(record[0]))
+	if err != nil {
+		return ClimateValue{}, err
+	}
+	_, convert, _ := unitConversion(s.fileUnits)
+
+	row := ClimateValue{date: date, value: math.NaN(), anomaly: math.NaN()}
+	value, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+	if err != nil {
+		return ClimateValue{}, fmt.Errorf("invalid value %q for %s", record[1], date.Format("2006-01"))
+	}
+	if value == s.missing {
+		return row, nil
+	}
+	row.value = convert(value, false)
+
+	if len(record) > 2 && strings.TrimSpace(record[2]) != "" {
+		anomaly, err := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+		if err != nil {
+			return ClimateValue{}, fmt.Errorf("invalid anomaly %q for %s", record[2], date.Format("2006-01"))
+		}
+		if anomaly != s.missing {
+			row.anomaly = convert(anomaly, true)
+		}
+	}
+	return row, nil
+}
+
+// parseClimateDate parses the Date column: YYYYMM, or YYYY for annual values.
+func parseClimateDate(field string) (time.Time, error) {
+	switch len(field) {
+	case 6:
+		date, err := time.Parse("200601", field)
+		if err == nil {
+			return date, nil
+		}
+	case 4:
+		date, err := time.Parse("2006", field)
+		if err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected YYYYMM", field)
+}
+
+// unitConversion returns the metric units for a units line and the function converting values to them.
+// Anomalies are differences, so temperature anomalies are only scaled, not shifted.
+func unitConversion(units string) (string, func(v float64, difference bool) float64, error) {
+	switch strings.ToLower(units) {
+	case "degrees fahrenheit", "fahrenheit", "degf", "°f":
+		return "degC", func(v float64, difference bool) float64 {
+			if difference {
+				return v * 5 / 9
+			}
+			return FareinheitToCelsius(v)
+		}, nil
+	case "degrees celsius", "celsius", "degc", "°c":
+		return "degC", func(v float64, difference bool) float64 { return v }, nil
+	case "inches", "inch", "in":
+		return "mm", func(v float64, difference bool) float64 { return v * 25.4 }, nil
+	case "millimeters", "millimetres", "mm":
+		return "mm", func(v float64, difference bool) float64 { return v }, nil
+	case "fahrenheit degree-days":
+		return "degC days", func(v float64, difference bool) float64 { return v * 5 / 9 }, nil
+	}
+	return "", nil, fmt.Errorf("unsupported units %q", units)
+}
+
+// Latest returns the value with the latest date that is not missing, and false if every value is missing.
+func (s ClimateSeries) Latest() (ClimateValue, bool) {
+	var latest ClimateValue
+	found := false
+	for _, v := range s.values {
+		if math.IsNaN(v.value) {
+			continue
+		}
+		if !found || v.date.After(latest.date) {
+			latest, found = v, true
+		}
+	}
+	return latest, found
+}
+
+// findSeries returns the first series for the variable.
+func findSeries(series []ClimateSeries, variable string) (ClimateSeries, bool) {
+	for _, s := range series {
+		if strings.EqualFold(s.variable, variable) {
+			return s, true
+		}
+	}
+	return ClimateSeries{}, false
+}
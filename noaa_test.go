@@ -0,0 +1,141 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+type ParseClimateCSVTest struct {
+	name   string
+	input  string
+	series []ClimateSeries // only the fields checked by the test are set
+	fails  bool
+}
+
+type ParseClimateTitleTest struct {
+	title    string
+	region   string
+	period   string
+	variable string
+	fails    bool
+}
+
+// closeTo compares two values, treating two NaNs as equal.
+func closeTo(a, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestParseClimateCSV(t *testing.T) {
+	nan := math.NaN()
+	tests := []ParseClimateCSVTest{
+		{
+			name: "one block in Fahrenheit",
+			input: "Pennsylvania May-June Maximum Temperature\nUnits: Degrees Fahrenheit\nBase Period: 1901-2000\nMissing: -99\n" +
+				"Date,Value,Anomaly\n202305,77.0,1.8\n202306,-99,-99\n",
+			series: []ClimateSeries{{region: "Pennsylvania", period: "May-June", variable: "Maximum Temperature", units: "degC", baseStart: 1901, baseEnd: 2000,
+				values: []ClimateValue{{date: date(2023, 5, 1), value: 25, anomaly: 1}, {date: date(2023, 6, 1), value: nan, anomaly: nan}}}},
+		},
+		{
+			name: "two blocks separated by an empty row, comma title",
+			input: "\"Ohio, Minimum Temperature, July\",,\nUnits: Degrees Celsius,,\nDate,Value,Anomaly\n202307,15.5,\n,,\n" +
+				"Ohio July Precipitation\nUnits: Inches\nDate,Value\n2023,2\n",
+			series: []ClimateSeries{
+				{region: "Ohio", period: "July", variable: "Minimum Temperature", units: "degC", values: []ClimateValue{{date: date(2023, 7, 1), value: 15.5, anomaly: nan}}},
+				{region: "Ohio", period: "July", variable: "Precipitation", units: "mm", values: []ClimateValue{{date: date(2023, 1, 1), value: 50.8, anomaly: nan}}},
+			},
+		},
+		{
+			name: "a block ending at the next title",
+			input: "New York Average Temperature\nUnits: Degrees Celsius\nDate,Value\n202301,-2\n" +
+				"New York 12-Month Average Temperature\nUnits: Degrees Celsius\nDate,Value\n202301,9\n",
+			series: []ClimateSeries{
+				{region: "New York", variable: "Average Temperature", units: "degC", values: []ClimateValue{{date: date(2023, 1, 1), value: -2, anomaly: nan}}},
+				{region: "New York", period: "12-Month", variable: "Average Temperature", units: "degC", values: []ClimateValue{{date: date(2023, 1, 1), value: 9, anomaly: nan}}},
+			},
+		},
+		{name: "empty file", input: "", fails: true},
+		{name: "no units", input: "Ohio July Precipitation\nDate,Value\n2023,2\n", fails: true},
+		{name: "no header", input: "Ohio July Precipitation\nUnits: Inches\n", fails: true},
+		{name: "unknown units", input: "Ohio July Precipitation\nUnits: Furlongs\nDate,Value\n", fails: true},
+		{name: "unknown variable", input: "Ohio July Snowfall\nUnits: Inches\nDate,Value\n", fails: true},
+		{name: "bad date", input: "Ohio July Precipitation\nUnits: Inches\nDate,Value\n2023-07,2\n", fails: true},
+		{name: "bad value", input: "Ohio July Precipitation\nUnits: Inches\nDate,Value\n202307,wet\n", fails: true},
+		{name: "bad base period", input: "Ohio July Precipitation\nUnits: Inches\nBase Period: 1901\nDate,Value\n", fails: true},
+		{name: "Value header missing", input: "Ohio July Precipitation\nUnits: Inches\nDate,Amount\n", fails: true},
+	}
+
+	for _, test := range tests {
+		series, err := ParseClimateCSV(strings.NewReader(test.input))
+		if (err != nil) != test.fails {
+			t.Errorf("ParseClimateCSV(%s) error = %v, want error %v", test.name, err, test.fails)
+			continue
+		}
+		if len(series) != len(test.series) {
+			t.Errorf("ParseClimateCSV(%s) read %d series, want %d", test.name, len(series), len(test.series))
+			continue
+		}
+		for i, want := range test.series {
+			got := series[i]
+			if got.region != want.region || got.period != want.period || got.variable != want.variable || got.units != want.units ||
+				got.baseStart != want.baseStart || got.baseEnd != want.baseEnd {
+				t.Errorf("ParseClimateCSV(%s) series %d = %q %q %q in %s (%d-%d), want %q %q %q in %s (%d-%d)", test.name, i,
+					got.region, got.period, got.variable, got.units, got.baseStart, got.baseEnd,
+					want.region, want.period, want.variable, want.units, want.baseStart, want.baseEnd)
+			}
+			if len(got.values) != len(want.values) {
+				t.Errorf("ParseClimateCSV(%s) series %d has %d values, want %d", test.name, i, len(got.values), len(want.values))
+				continue
+			}
+			for j, v := range want.values {
+				g := got.values[j]
+				if !g.date.Equal(v.date) || !closeTo(g.value, v.value) || !closeTo(g.anomaly, v.anomaly) {
+					t.Errorf("ParseClimateCSV(%s) series %d value %d = %v %v %v, want %v %v %v", test.name, i, j,
+						g.date.Format("2006-01"), g.value, g.anomaly, v.date.Format("2006-01"), v.value, v.anomaly)
+				}
+			}
+		}
+	}
+}
+
+func TestParseClimateTitle(t *testing.T) {
+	tests := []ParseClimateTitleTest{
+		{title: "Pennsylvania May-June Maximum Temperature", region: "Pennsylvania", period: "May-June", variable: "Maximum Temperature"},
+		{title: "New Jersey Average Temperature", region: "New Jersey", variable: "Average Temperature"},
+		{title: "Allentown, PA 3-Month Precipitation", region: "Allentown, PA", period: "3-Month", variable: "Precipitation"},
+		{title: "Virginia, Minimum Temperature, October", region: "Virginia", period: "October", variable: "Minimum Temperature"},
+		{title: "May Cooling Degree Days", region: "May", variable: "Cooling Degree Days"},
+		{title: "Maximum Temperature", fails: true},
+		{title: "Virginia Humidity", fails: true},
+	}
+
+	for _, test := range tests {
+		series, err := parseClimateTitle([]string{test.title})
+		if (err != nil) != test.fails {
+			t.Errorf("parseClimateTitle(%q) error = %v, want error %v", test.title, err, test.fails)
+			continue
+		}
+		if series.region != test.region || series.period != test.period || series.variable != test.variable {
+			t.Errorf("parseClimateTitle(%q) = %q, %q, %q, want %q, %q, %q", test.title,
+				series.region, series.period, series.variable, test.region, test.period, test.variable)
+		}
+	}
+}
+
+func TestClimateSeriesLatest(t *testing.T) {
+	series := ClimateSeries{values: []ClimateValue{
+		{date: date(2021, 5, 1), value: 20},
+		{date: date(2023, 5, 1), value: math.NaN()},
+		{date: date(2022, 5, 1), value: 22},
+	}}
+	latest, ok := series.Latest()
+	if !ok || latest.value != 22 {
+		t.Errorf("Latest() = %v, %v, want 22, true", latest.value, ok)
+	}
+	if _, ok := (ClimateSeries{values: []ClimateValue{{value: math.NaN()}}}).Latest(); ok {
+		t.Errorf("Latest() of a series of missing values is ok")
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Bounds is a longitude/latitude bounding box in degrees.
+type Bounds struct {
+	minLon, minLat float64
+	maxLon, maxLat float64
+}
+
+// Projection maps a longitude/latitude position onto a pixel position of a canvas.
+// Pixel y grows downwards, so north is at the top of the canvas.
+type Projection interface {
+	Project(lon, lat float64) (float64, float64)
+}
+
+// MapProjection is a map projection fitted to a bounding box and a canvas.
+// forward projects longitude/latitude (in degrees) onto the plane, and scale/offset place the projected bounding box in the middle of the canvas.
+type MapProjection struct {
+	name    string
+	forward func(lon, lat float64) (float64, float64)
+	scale   float64
+	offsetX float64
+	offsetY float64
+}
+
+// ContiguousUS returns the default bounding box of the simulation.
+func ContiguousUS() Bounds {
+	return Bounds{minLon: minLon, minLat: minLat, maxLon: maxLon, maxLat: maxLat}
+}
+
+// Contains reports whether a position lies inside the bounds, edges included.
+func (b Bounds) Contains(position OrderedPair) bool {
+	return position.x >= b.minLon && position.x <= b.maxLon && position.y >= b.minLat && position.y <= b.maxLat
+}
+
+// NewProjection creates the named projection ("equirectangular" or "albers") and fits the bounding box into a canvas of the given size.
+// The box keeps its aspect ratio and is centred, leaving a margin of margin pixels on every side.
+func NewProjection(name string, bounds Bounds, canvasWidth, canvasHeight int, margin float64) (MapProjection, error) {
+	centerLon := (bounds.minLon + bounds.maxLon) / 2
+	centerLat := (bounds.minLat + bounds.maxLat) / 2
+
+	p := MapProjection{name: strings.ToLower(name)}
+	switch p.name {
+	case "equirectangular", "":
+		p.name = "equirectangular"
+		p.forward = equirectangular(centerLat)
+	case "albers":
+		span := bounds.maxLat - bounds.minLat
+		p.forward = albers(centerLon, bounds.minLat, bounds.minLat+span/6, bounds.maxLat-span/6)
+	default:
+		return MapProjection{}, fmt.Errorf("unknown projection %q (want equirectangular or albers)", name)
+	}
+
+	// project points along the edges of the box, since the edges are curved in conic projections
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	const samples = 50
+	for i := 0; i <= samples; i++ {
+		t := float64(i) / samples
+		lon := bounds.minLon + t*(bounds.maxLon-bounds.minLon)
+		lat := bounds.minLat + t*(bounds.maxLat-bounds.minLat)
+		for _, pt := range [][2]float64{{lon, bounds.minLat}, {lon, bounds.maxLat}, {bounds.minLon, lat}, {bounds.maxLon, lat}} {
+			x, y := p.forward(pt[0], pt[1])
+			minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+			minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+		}
+	}
+
+	usableWidth := float64(canvasWidth) - 2*margin
+	usableHeight := float64(canvasHeight) - 2*margin
+	p.scale = math.Min(usableWidth/(maxX-minX), usableHeight/(maxY-minY))
+	p.offsetX = (float64(canvasWidth)-(maxX-minX)*p.scale)/2 - minX*p.scale
+	p.offsetY = (float64(canvasHeight)+(maxY-minY)*p.scale)/2 + minY*p.scale
+
+	return p, nil
+}
+
+// Project maps a longitude/latitude position onto a pixel position.
+func (p MapProjection) Project(lon, lat float64) (float64, float64) {
+	x, y := p.forward(lon, lat)
+	return p.offsetX + x*p.scale, p.offsetY - y*p.scale
+}
+
+// equirectangular returns a plate carrée projection whose horizontal scale is correct at the standard latitude lat0.
+func equirectangular(lat0 float64) func(lon, lat float64) (float64, float64) {
+	k := math.Cos(lat0 * math.Pi / 180)
+	return func(lon, lat float64) (float64, float64) {
+		return lon * k, lat
+	}
+}
+
+// albers returns a spherical Albers equal-area conic projection centred on lon0/lat0 with standard parallels lat1 and lat2.
+func albers(lon0, lat0, lat1, lat2 float64) func(lon, lat float64) (float64, float64) {
+	rad := math.Pi / 180
+	phi1, phi2 := lat1*rad, lat2*rad
+	n := (math.Sin(phi1) + math.Sin(phi2)) / 2
+	c := math.Cos(phi1)*math.Cos(phi1) + 2*n*math.Sin(phi1)
+	rho0 := math.Sqrt(c-2*n*math.Sin(lat0*rad)) / n
+
+	return func(lon, lat float64) (float64, float64) {
+		rho := math.Sqrt(c-2*n*math.Sin(lat*rad)) / n
+		theta := n * (lon - lon0) * rad
+		return rho * math.Sin(theta), rho0 - rho*math.Cos(theta)
+	}
+}
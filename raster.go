@@ -0,0 +1,119 @@
+package main
+
+import "math"
+
+// Raster is a regular longitude/latitude grid of values covering a bounding box.
+// Cells are cellSize degrees square; row 0 is the northernmost row and column 0 the westernmost column.
+// Cells without a value hold noData.
+type Raster struct {
+	bounds   Bounds
+	cellSize float64
+	cols     int
+	rows     int
+	values   []float64
+	noData   float64
+}
+
+// NewRaster creates a raster of cellSize degree cells covering bounds, with every cell set to fill.
+// The raster is extended east and south to a whole number of cells.
+func NewRaster(bounds Bounds, cellSize float64, fill float64) Raster {
+	cols := int(math.Ceil((bounds.maxLon - bounds.minLon) / cellSize))
+	rows := int(math.Ceil((bounds.maxLat - bounds.minLat) / cellSize))
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+
+	r := Raster{
+		bounds:   Bounds{minLon: bounds.minLon, maxLat: bounds.maxLat, maxLon: bounds.minLon + float64(cols)*cellSize, minLat: bounds.maxLat - float64(rows)*cellSize},
+		cellSize: cellSize,
+		cols:     cols,
+		rows:     rows,
+		values:   make([]float64, cols*rows),
+		noData:   math.NaN(),
+	}
+	for i := range r.values {
+		r.values[i] = fill
+	}
+	return r
+}
+
+// Cell returns the column and row of the cell containing the position, and false if the position is outside the raster.
+func (r Raster) Cell(lon, lat float64) (int, int, bool) {
+	col := int(math.Floor((lon - r.bounds.minLon) / r.cellSize))
+	row := int(math.Floor((r.bounds.maxLat - lat) / r.cellSize))
+	if col < 0 || col >= r.cols || row < 0 || row >= r.rows {
+		return 0, 0, false
+	}
+	return col, row, true
+}
+
+// At returns the value of a cell.
+func (r Raster) At(col, row int) float64 {
+	return r.values[row*r.cols+col]
+}
+
+// Set changes the value of a cell.
+func (r Raster) Set(col, row int, value float64) {
+	r.values[row*r.cols+col] = value
+}
+
+// ValueAt returns the value of the cell containing the position, and false if the position is outside the raster or the cell has no data.
+func (r Raster) ValueAt(lon, lat float64) (float64, bool) {
+	col, row, ok := r.Cell(lon, lat)
+	if !ok {
+		return r.noData, false
+	}
+	v := r.At(col, row)
+	if r.IsNoData(v) {
+		return v, false
+	}
+	return v, true
+}
+
+// IsNoData reports whether v is the raster's no-data value.
+func (r Raster) IsNoData(v float64) bool {
+	if math.IsNaN(r.noData) {
+		return math.IsNaN(v)
+	}
+	return v == r.noData
+}
+
+// CellCenter returns the longitude and latitude of the centre of a cell.
+func (r Raster) CellCenter(col, row int) (float64, float64) {
+	return r.bounds.minLon + (float64(col)+0.5)*r.cellSize, r.bounds.maxLat - (float64(row)+0.5)*r.cellSize
+}
+
+// CellBounds returns the bounding box of a cell.
+func (r Raster) CellBounds(col, row int) Bounds {
+	west := r.bounds.minLon + float64(col)*r.cellSize
+	north := r.bounds.maxLat - float64(row)*r.cellSize
+	return Bounds{minLon: west, minLat: north - r.cellSize, maxLon: west + r.cellSize, maxLat: north}
+}
+
+// Range returns the smallest and largest values of the raster, ignoring cells without data.
+// Both are NaN if no cell has data.
+func (r Raster) Range() (float64, float64) {
+	lo, hi := math.NaN(), math.NaN()
+	for _, v := range r.values {
+		if r.IsNoData(v) {
+			continue
+		}
+		if math.IsNaN(lo) || v < lo {
+			lo = v
+		}
+		if math.IsNaN(hi) || v > hi {
+			hi = v
+		}
+	}
+	return lo, hi
+}
+
+// CellArea returns the area of a cell of the given row in km², treating the earth as a sphere.
+func (r Raster) CellArea(row int) float64 {
+	_, lat := r.CellCenter(0, row)
+	kmPerDegree := math.Pi / 180 * earthRadius
+	return r.cellSize * kmPerDegree * r.cellSize * kmPerDegree * math.Cos(lat*math.Pi/180)
+}
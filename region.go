@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Region is the study region of a run: a bounding box, optionally narrowed to the inside of some polygons.
+// Trees, detections, surveys and seeded flies outside it are left out, and the weather quadrants and rasters cover its bounding box.
+type Region struct {
+	name     string
+	bounds   Bounds
+	polygons [][][]OrderedPair // polygons of an outer ring and any holes, in longitude/latitude; none for the whole bounding box
+}
+
+// ContiguousUSRegion returns the default study region, the bounding box of the contiguous US.
+func ContiguousUSRegion() Region {
+	return Region{name: "contiguous US", bounds: ContiguousUS()}
+}
+
+// Contains reports whether a position lies in the region.
+func (r Region) Contains(position OrderedPair) bool {
+	if !r.bounds.Contains(position) {
+		return false
+	}
+	if len(r.polygons) == 0 {
+		return true
+	}
+	for _, polygon := range r.polygons {
+		if inPolygon(position, polygon) {
+			return true
+		}
+	}
+	return false
+}
+
+// inPolygon reports whether a position lies inside a polygon given as an outer ring and holes, by the even-odd rule.
+func inPolygon(position OrderedPair, rings [][]OrderedPair) bool {
+	inside := false
+	for _, ring := range rings {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			a, b := ring[i], ring[j]
+			if (a.y > position.y) != (b.y > position.y) && position.x < (b.x-a.x)*(position.y-a.y)/(b.y-a.y)+a.x {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// String describes the region for printing.
+func (r Region) String() string {
+	return fmt.Sprintf("%s (%.2f to %.2f longitude, %.2f to %.2f latitude)", r.name, r.bounds.minLon, r.bounds.maxLon, r.bounds.minLat, r.bounds.maxLat)
+}
+
+// ParseRegion reads the study region given with -region: "us" (or empty) for the contiguous US, a JSON file (see LoadRegion),
+// a bounding box "minLon,minLat,maxLon,maxLat", or a comma-separated list of states looked up by name or postal code in boundaryFile.
+func ParseRegion(spec, boundaryFile string) (Region, error) {
+	spec = strings.TrimSpace(spec)
+	switch {
+	case spec == "" || strings.EqualFold(spec, "us"):
+		return ContiguousUSRegion(), nil
+	case strings.HasSuffix(strings.ToLower(spec), ".json"):
+		return LoadRegion(spec, boundaryFile)
+	}
+
+	fields := splitList(spec)
+	if len(fields) == 4 {
+		box := make([]float64, 4)
+		numbers := true
+		for i, field := range fields {
+			var err error
+			if box[i], err = strconv.ParseFloat(field, 64); err != nil {
+				numbers = false
+				break
+			}
+		}
+		if numbers {
+			return boxRegion(spec, box)
+		}
+	}
+
+	boundaries, err := LoadBoundaries(boundaryFile)
+	if err != nil {
+		return Region{}, err
+	}
+	return areaRegion(strings.Join(fields, ", "), boundaries, fields)
+}
+
+// LoadRegion reads a study region from a JSON file with a name and one of a bounding box, a polygon, or lists of states and counties:
+//
+//	{"name": "PA and NJ", "states": ["PA", "NJ"]}
+//	{"name": "southeast PA", "bbox": [-77, 39.7, -74.7, 41]}
+//	{"name": "Lehigh valley", "polygon": [[-75.9, 40.4], [-75.1, 40.4], [-75.1, 40.9], [-75.9, 40.9]]}
+//	{"name": "core counties", "counties": ["Berks, PA", "Lehigh, PA"], "county_boundaries": "Data/us_counties.geojson"}
+//
+// bbox is [minLon, minLat, maxLon, maxLat] and a polygon is a list of [longitude, latitude] points. States are looked up by name or
+// postal code in "boundaries" (default boundaryFile). Counties are looked up in "county_boundaries", a GeoJSON file such as a Census
+// county file, by name, or by "name, state" where counties of the same name exist in several states; a bare state code selects
+// all its counties.
+func LoadRegion(filename, boundaryFile string) (Region, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return Region{}, fmt.Errorf("error reading region: %v", err)
+	}
+	var config struct {
+		Name             string       `json:"name"`
+		BBox             []float64    `json:"bbox"`
+		Polygon          [][2]float64 `json:"polygon"`
+		States           []string     `json:"states"`
+		Counties         []string     `json:"counties"`
+		Boundaries       string       `json:"boundaries"`
+		CountyBoundaries string       `json:"county_boundaries"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Region{}, fmt.Errorf("%s: %v", filename, err)
+	}
+	if config.Name == "" {
+		config.Name = filename
+	}
+
+	kinds := 0
+	if config.BBox != nil {
+		kinds++
+	}
+	if config.Polygon != nil {
+		kinds++
+	}
+	if len(config.States)+len(config.Counties) > 0 {
+		kinds++
+	}
+	if kinds != 1 {
+		return Region{}, fmt.Errorf("%s: a region needs exactly one of bbox, polygon, or states and counties", filename)
+	}
+
+	switch {
+	case config.BBox != nil:
+		region, err := boxRegion(config.Name, config.BBox)
+		if err != nil {
+			return Region{}, fmt.Errorf("%s: %v", filename, err)
+		}
+		return region, nil
+	case config.Polygon != nil:
+		if len(config.Polygon) < 3 {
+			return Region{}, fmt.Errorf("%s: a polygon needs at least three points", filename)
+		}
+		region := Region{name: config.Name, polygons: [][][]OrderedPair{toRings([][][2]float64{config.Polygon})}}
+		region.bounds = polygonBounds(region.polygons)
+		return region, nil
+	}
+
+	region := Region{name: config.Name}
+	add := func(boundaryFile string, names []string) error {
+		boundaries, err := LoadBoundaries(boundaryFile)
+		if err != nil {
+			return err
+		}
+		areas, err := areaRegion(config.Name, boundaries, names)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		region.polygons = append(region.polygons, areas.polygons...)
+		return nil
+	}
+	if len(config.States) > 0 {
+		if config.Boundaries == "" {
+			config.Boundaries = boundaryFile
+		}
+		if err := add(config.Boundaries, config.States); err != nil {
+			return Region{}, err
+		}
+	}
+	if len(config.Counties) > 0 {
+		if config.CountyBoundaries == "" {
+			return Region{}, fmt.Errorf("%s: counties need a county_boundaries file", filename)
+		}
+		if err := add(config.CountyBoundaries, config.Counties); err != nil {
+			return Region{}, err
+		}
+	}
+	region.bounds = polygonBounds(region.polygons)
+	return region, nil
+}
+
+// boxRegion returns the region of a bounding box [minLon, minLat, maxLon, maxLat].
+func boxRegion(name string, box []float64) (Region, error) {
+	if len(box) != 4 {
+		return Region{}, fmt.Errorf("a bounding box needs four numbers, minLon, minLat, maxLon, maxLat")
+	}
+	b := Bounds{minLon: box[0], minLat: box[1], maxLon: box[2], maxLat: box[3]}
+	if b.minLon >= b.maxLon || b.minLat >= b.maxLat || b.minLon < -180 || b.maxLon > 180 || b.minLat < -90 || b.maxLat > 90 {
+		return Region{}, fmt.Errorf("invalid bounding box %v, expected minLon, minLat, maxLon, maxLat", box)
+	}
+	return Region{name: name, bounds: b}, nil
+}
+
+// areaRegion returns the region of the boundaries matching each of the names (see matchBoundaries). It is an error if a name matches none.
+func areaRegion(name string, boundaries []Boundary, names []string) (Region, error) {
+	region := Region{name: name}
+	for _, key := range names {
+		matches := matchBoundaries(boundaries, key)
+		if len(matches) == 0 {
+			return Region{}, fmt.Errorf("no area named %q in the boundary file", key)
+		}
+		for _, b := range matches {
+			region.polygons = append(region.polygons, b.polygons...)
+		}
+	}
+	region.bounds = polygonBounds(region.polygons)
+	return region, nil
+}
+
+// matchBoundaries returns the boundaries whose name or postal code is key, ignoring case.
+// A key "name, state" matches the boundaries with that name in the state, such as a county, and "Berks County" also matches "Berks".
+func matchBoundaries(boundaries []Boundary, key string) []Boundary {
+	name, state, hasState := strings.Cut(key, ",")
+	name, state = strings.TrimSpace(name), strings.TrimSpace(state)
+	nameMatches := func(b Boundary) bool {
+		return strings.EqualFold(b.name, name) || strings.EqualFold(b.name+" County", name)
+	}
+
+	var matches []Boundary
+	for _, b := range boundaries {
+		if hasState && nameMatches(b) && strings.EqualFold(b.postal, state) ||
+			!hasState && (nameMatches(b) || strings.EqualFold(b.postal, name)) {
+			matches = append(matches, b)
+		}
+	}
+	return matches
+}
+
+// polygonBounds returns the bounding box of the outer rings of the polygons.
+func polygonBounds(polygons [][][]OrderedPair) Bounds {
+	first := true
+	var b Bounds
+	for _, polygon := range polygons {
+		if len(polygon) == 0 {
+			continue
+		}
+		for _, p := range polygon[0] {
+			if first {
+				b = Bounds{minLon: p.x, minLat: p.y, maxLon: p.x, maxLat: p.y}
+				first = false
+				continue
+			}
+			if p.x < b.minLon {
+				b.minLon = p.x
+			}
+			if p.x > b.maxLon {
+				b.maxLon = p.x
+			}
+			if p.y < b.minLat {
+				b.minLat = p.y
+			}
+			if p.y > b.maxLat {
+				b.maxLat = p.y
+			}
+		}
+	}
+	return b
+}
@@ -0,0 +1,177 @@
+package main
+
+import "testing"
+
+type ContainsTest struct {
+	position OrderedPair
+	result   bool
+}
+
+type ParseRegionTest struct {
+	spec   string
+	name   string
+	bounds Bounds
+	fails  bool
+}
+
+type LoadRegionTest struct {
+	name    string
+	json    string
+	bounds  Bounds
+	inside  []OrderedPair
+	outside []OrderedPair
+	fails   bool
+}
+
+// testBoundaries is a boundary file of two states, one with a lake and one of two islands.
+const testBoundaries = `{"type": "FeatureCollection", "features": [
+	{"type": "Feature", "properties": {"NAME": "Lakeland", "STUSPS": "LK"}, "geometry": {"type": "Polygon",
+		"coordinates": [[[-80, 40], [-76, 40], [-76, 44], [-80, 44], [-80, 40]], [[-79, 41], [-77, 41], [-77, 43], [-79, 43], [-79, 41]]]}},
+	{"type": "Feature", "properties": {"NAME": "Islands", "STUSPS": "IS"}, "geometry": {"type": "MultiPolygon",
+		"coordinates": [[[[-70, 40], [-69, 40], [-69, 41], [-70, 41], [-70, 40]]], [[[-68, 40], [-67, 40], [-67, 41], [-68, 41], [-68, 40]]]]}}]}`
+
+// testCounties is a county file with a county of the same name in both states.
+const testCounties = `{"type": "FeatureCollection", "features": [
+	{"type": "Feature", "properties": {"name": "Berks", "postal": "LK"}, "geometry": {"type": "Polygon",
+		"coordinates": [[[-80, 40], [-79, 40], [-79, 41], [-80, 41], [-80, 40]]]}},
+	{"type": "Feature", "properties": {"name": "Berks", "postal": "IS"}, "geometry": {"type": "Polygon",
+		"coordinates": [[[-70, 40], [-69.5, 40], [-69.5, 40.5], [-70, 40.5], [-70, 40]]]}}]}`
+
+// TestRegionContains checks a polygon with a hole, a polygon of several parts and a bounding box.
+func TestRegionContains(t *testing.T) {
+	regions := map[string]Region{
+		"hole": {bounds: Bounds{minLon: 0, minLat: 0, maxLon: 4, maxLat: 4},
+			polygons: [][][]OrderedPair{{square(0, 0, 4), square(1, 1, 2)}}},
+		"islands": {bounds: Bounds{minLon: 0, minLat: 0, maxLon: 4, maxLat: 4},
+			polygons: [][][]OrderedPair{{square(0, 0, 1)}, {square(3, 3, 1)}}},
+		// an island inside the hole of another polygon
+		"island in a lake": {bounds: Bounds{minLon: 0, minLat: 0, maxLon: 4, maxLat: 4},
+			polygons: [][][]OrderedPair{{square(0, 0, 4), square(1, 1, 2)}, {square(1.5, 1.5, 1)}}},
+		"box": {bounds: Bounds{minLon: 0, minLat: 0, maxLon: 4, maxLat: 4}},
+	}
+	tests := map[string][]ContainsTest{
+		"hole": {
+			{position: OrderedPair{0.5, 0.5}, result: true},
+			{position: OrderedPair{3.5, 2}, result: true},
+			{position: OrderedPair{2, 2}, result: false},
+			{position: OrderedPair{1.5, 2.5}, result: false},
+			{position: OrderedPair{5, 2}, result: false},
+		},
+		"islands": {
+			{position: OrderedPair{0.5, 0.5}, result: true},
+			{position: OrderedPair{3.5, 3.5}, result: true},
+			{position: OrderedPair{2, 2}, result: false},
+		},
+		"island in a lake": {
+			{position: OrderedPair{0.5, 0.5}, result: true},
+			{position: OrderedPair{1.2, 1.2}, result: false},
+			{position: OrderedPair{2, 2}, result: true},
+		},
+		"box": {
+			{position: OrderedPair{2, 2}, result: true},
+			{position: OrderedPair{0, 4}, result: true},
+			{position: OrderedPair{-0.1, 2}, result: false},
+		},
+	}
+
+	for name, cases := range tests {
+		for _, test := range cases {
+			if result := regions[name].Contains(test.position); result != test.result {
+				t.Errorf("Contains(%s, %v) = %v, want %v", name, test.position, result, test.result)
+			}
+		}
+	}
+}
+
+func TestParseRegion(t *testing.T) {
+	boundaryFile := writeTestFile(t, "states.geojson", testBoundaries)
+	tests := []ParseRegionTest{
+		{spec: "", name: "contiguous US", bounds: ContiguousUS()},
+		{spec: "US", name: "contiguous US", bounds: ContiguousUS()},
+		{spec: "-80, 39.5, -74.5, 42.5", name: "-80, 39.5, -74.5, 42.5", bounds: Bounds{minLon: -80, minLat: 39.5, maxLon: -74.5, maxLat: 42.5}},
+		{spec: "lk,Islands", name: "lk, Islands", bounds: Bounds{minLon: -80, minLat: 40, maxLon: -67, maxLat: 44}},
+		{spec: "-74.5, 39.5, -80, 42.5", fails: true},
+		{spec: "-80, 39.5, -74.5, 95", fails: true},
+		{spec: "Atlantis", fails: true},
+		{spec: "missing.json", fails: true},
+	}
+
+	for _, test := range tests {
+		region, err := ParseRegion(test.spec, boundaryFile)
+		if (err != nil) != test.fails {
+			t.Errorf("ParseRegion(%q) error = %v, want error %v", test.spec, err, test.fails)
+			continue
+		}
+		if region.name != test.name || region.bounds != test.bounds {
+			t.Errorf("ParseRegion(%q) = %q over %+v, want %q over %+v", test.spec, region.name, region.bounds, test.name, test.bounds)
+		}
+	}
+}
+
+func TestLoadRegion(t *testing.T) {
+	boundaryFile := writeTestFile(t, "states.geojson", testBoundaries)
+	countyFile := writeTestFile(t, "counties.geojson", testCounties)
+	tests := []LoadRegionTest{
+		{
+			name:   "bounding box",
+			json:   `{"name": "box", "bbox": [-77, 39.7, -74.7, 41]}`,
+			bounds: Bounds{minLon: -77, minLat: 39.7, maxLon: -74.7, maxLat: 41},
+			inside: []OrderedPair{{-76, 40}}, outside: []OrderedPair{{-78, 40}},
+		},
+		{
+			name:   "polygon, closed for it",
+			json:   `{"name": "triangle", "polygon": [[-76, 40], [-74, 40], [-76, 42]]}`,
+			bounds: Bounds{minLon: -76, minLat: 40, maxLon: -74, maxLat: 42},
+			inside: []OrderedPair{{-75.5, 40.5}}, outside: []OrderedPair{{-74.5, 41.5}},
+		},
+		{
+			name:   "state with a lake",
+			json:   `{"name": "lakeland", "states": ["Lakeland"]}`,
+			bounds: Bounds{minLon: -80, minLat: 40, maxLon: -76, maxLat: 44},
+			inside: []OrderedPair{{-79.5, 40.5}, {-76.5, 43.5}}, outside: []OrderedPair{{-78, 42}, {-69.5, 40.5}},
+		},
+		{
+			name:   "counties",
+			json:   `{"name": "berks", "counties": ["Berks County, LK"], "county_boundaries": "` + countyFile + `"}`,
+			bounds: Bounds{minLon: -80, minLat: 40, maxLon: -79, maxLat: 41},
+			inside: []OrderedPair{{-79.5, 40.5}}, outside: []OrderedPair{{-69.8, 40.2}},
+		},
+		{
+			name:   "states and counties",
+			json:   `{"states": ["IS"], "counties": ["LK"], "county_boundaries": "` + countyFile + `"}`,
+			bounds: Bounds{minLon: -80, minLat: 40, maxLon: -67, maxLat: 41},
+			inside: []OrderedPair{{-79.5, 40.5}, {-67.5, 40.5}}, outside: []OrderedPair{{-78, 40.5}},
+		},
+		{name: "two kinds", json: `{"bbox": [-77, 39.7, -74.7, 41], "states": ["IS"]}`, fails: true},
+		{name: "no kind", json: `{"name": "nothing"}`, fails: true},
+		{name: "two points", json: `{"polygon": [[-76, 40], [-74, 40]]}`, fails: true},
+		{name: "bad box", json: `{"bbox": [-77, 39.7, -74.7]}`, fails: true},
+		{name: "unknown state", json: `{"states": ["Atlantis"]}`, fails: true},
+		{name: "counties without a file", json: `{"counties": ["Berks, LK"]}`, fails: true},
+		{name: "not JSON", json: `states: PA`, fails: true},
+	}
+
+	for _, test := range tests {
+		region, err := LoadRegion(writeTestFile(t, "region.json", test.json), boundaryFile)
+		if (err != nil) != test.fails {
+			t.Errorf("LoadRegion(%s) error = %v, want error %v", test.name, err, test.fails)
+			continue
+		}
+		if test.fails {
+			continue
+		}
+		if region.bounds != test.bounds {
+			t.Errorf("LoadRegion(%s) bounds = %+v, want %+v", test.name, region.bounds, test.bounds)
+		}
+		for _, p := range test.inside {
+			if !region.Contains(p) {
+				t.Errorf("LoadRegion(%s) does not contain %v", test.name, p)
+			}
+		}
+		for _, p := range test.outside {
+			if region.Contains(p) {
+				t.Errorf("LoadRegion(%s) contains %v", test.name, p)
+			}
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"canvas"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Render modes selectable with -render.
+const (
+	RenderPoints     = "points"     // every fly drawn as a dot in its stage colour
+	RenderHeatmap    = "heatmap"    // kernel density of living flies
+	RenderChoropleth = "choropleth" // living flies per quadrant
+	RenderOccupancy  = "occupancy"  // share of the time points so far in which each quadrant held living flies
+	RenderArrival    = "arrival"    // year in which living flies first reached each raster cell
+)
+
+// ColorRamp is a sequence of colour stops spread evenly between 0 and 1.
+type ColorRamp []color.RGBA
+
+// colorRamps are the ramps selectable with -ramp. Viridis and magma are sampled from the matplotlib colour maps.
+var colorRamps = map[string]ColorRamp{
+	"viridis": {{68, 1, 84, 255}, {59, 82, 139, 255}, {33, 145, 140, 255}, {94, 201, 98, 255}, {253, 231, 37, 255}},
+	"magma":   {{0, 0, 4, 255}, {81, 18, 124, 255}, {183, 55, 121, 255}, {252, 137, 97, 255}, {252, 253, 191, 255}},
+	"heat":    {{255, 255, 178, 255}, {254, 204, 92, 255}, {253, 141, 60, 255}, {240, 59, 32, 255}, {189, 0, 38, 255}},
+	"greys":   {{60, 60, 60, 255}, {255, 255, 255, 255}},
+}
+
+// RenderSettings selects how the flies are drawn on each frame.
+type RenderSettings struct {
+	mode      string
+	ramp      ColorRamp
+	cellSize  float64 // raster cell size in degrees for the heatmap and arrival modes
+	bandwidth float64 // kernel bandwidth in km for the heatmap mode
+}
+
+// NewRenderSettings checks the render mode and looks up the colour ramp by name.
+func NewRenderSettings(mode, rampName string, cellSize, bandwidth float64) (RenderSettings, error) {
+	mode = strings.ToLower(mode)
+	switch mode {
+	case RenderPoints, RenderHeatmap, RenderChoropleth, RenderOccupancy, RenderArrival:
+	default:
+		return RenderSettings{}, fmt.Errorf("unknown render mode %q (want points, heatmap, choropleth, occupancy or arrival)", mode)
+	}
+
+	ramp, ok := colorRamps[strings.ToLower(rampName)]
+	if !ok {
+		return RenderSettings{}, fmt.Errorf("unknown colour ramp %q (want viridis, magma, heat or greys)", rampName)
+	}
+	if cellSize <= 0 || bandwidth <= 0 {
+		return RenderSettings{}, fmt.Errorf("cell size and bandwidth must be positive")
+	}
+
+	return RenderSettings{mode: mode, ramp: ramp, cellSize: cellSize, bandwidth: bandwidth}, nil
+}
+
+// Color returns the colour of the ramp at t, where t is clamped to [0, 1].
+func (ramp ColorRamp) Color(t float64) color.Color {
+	if len(ramp) == 1 || math.IsNaN(t) {
+		return ramp[0]
+	}
+	t = math.Max(0, math.Min(1, t))
+
+	position := t * float64(len(ramp)-1)
+	i := int(math.Floor(position))
+	if i >= len(ramp)-1 {
+		return ramp[len(ramp)-1]
+	}
+	f := position - float64(i)
+	a, b := ramp[i], ramp[i+1]
+	mix := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x) + f*(float64(y)-float64(x))))
+	}
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
+
+// RenderHistory accumulates what the occupancy and arrival modes need to know about earlier time points.
+// Add must be called for every time point in order, including the ones that are not drawn.
+type RenderHistory struct {
+	settings  RenderSettings
+	quadrants []Quadrant
+	timeSteps int
+	occupied  map[int]int // quadrant id -> number of time points with living flies
+	arrival   Raster      // first year with living flies in each cell, NaN if never reached
+	firstYear int
+	lastYear  int
+}
+
+// NewRenderHistory creates an empty history for the given render settings.
+func NewRenderHistory(settings RenderSettings, quadrants []Quadrant, bounds Bounds) *RenderHistory {
+	return &RenderHistory{
+		settings:  settings,
+		quadrants: quadrants,
+		occupied:  make(map[int]int),
+		arrival:   NewRaster(bounds, settings.cellSize, math.NaN()),
+	}
+}
+
+// Add records one time point.
+func (h *RenderHistory) Add(country Country) {
+	year := country.date.Year()
+	if h.timeSteps == 0 {
+		h.firstYear = year
+	}
+	h.lastYear = year
+	h.timeSteps++
+
+	switch h.settings.mode {
+	case RenderOccupancy:
+		for id := range QuadrantCounts(country, h.quadrants) {
+			h.occupied[id]++
+		}
+	case RenderArrival:
+		for i := range country.flies {
+			fly := &country.flies[i]
+			if !fly.isAlive {
+				continue
+			}
+			col, row, ok := h.arrival.Cell(fly.position.x, fly.position.y)
+			if ok && math.IsNaN(h.arrival.At(col, row)) {
+				h.arrival.Set(col, row, float64(year))
+			}
+		}
+	}
+}
+
+// QuadrantCounts returns the number of living flies in each quadrant, keyed by quadrant id. Quadrants without flies are left out.
+func QuadrantCounts(country Country, quadrants []Quadrant) map[int]int {
+	counts := make(map[int]int)
+	for i := range country.flies {
+		fly := &country.flies[i]
+		if !fly.isAlive {
+			continue
+		}
+		if id := GetQuadrant(fly, quadrants); id != -1 {
+			counts[id]++
+		}
+	}
+	return counts
+}
+
+// KernelDensity estimates the density of living flies on a raster with a Gaussian kernel of the given bandwidth in km.
+// The kernel is cut off at three bandwidths. Values are flies per square kilometre.
+func KernelDensity(country Country, bounds Bounds, cellSize, bandwidth float64) Raster {
+	density := NewRaster(bounds, cellSize, 0)
+	for i := range country.flies {
+		if country.flies[i].isAlive {
+			addGaussianKernel(density, country.flies[i].position, 1, bandwidth)
+		}
+	}
+	return density
+}
+
+// addGaussianKernel adds the density of a point of the given weight, spread by a Gaussian kernel of the given bandwidth in km,
+// to the cells of a raster. The kernel is cut off at three bandwidths. Points outside the raster are left out.
+func addGaussianKernel(density Raster, position OrderedPair, weight, bandwidth float64) {
+	col, row, ok := density.Cell(position.x, position.y)
+	if !ok {
+		return
+	}
+
+	kmPerDegreeLat := math.Pi / 180 * earthRadius
+	reach := int(math.Ceil(3*bandwidth/(kmPerDegreeLat*density.cellSize))) + 1
+	norm := weight / (2 * math.Pi * bandwidth * bandwidth)
+
+	kmPerDegreeLon := kmPerDegreeLat * math.Cos(position.y*math.Pi/180)
+	colReach := reach
+	if kmPerDegreeLon > 0 {
+		colReach = int(math.Ceil(3*bandwidth/(kmPerDegreeLon*density.cellSize))) + 1
+	}
+
+	for r := row - reach; r <= row+reach; r++ {
+		if r < 0 || r >= density.rows {
+			continue
+		}
+		for c := col - colReach; c <= col+colReach; c++ {
+			if c < 0 || c >= density.cols {
+				continue
+			}
+			lon, lat := density.CellCenter(c, r)
+			dx := (lon - position.x) * kmPerDegreeLon
+			dy := (lat - position.y) * kmPerDegreeLat
+			d2 := (dx*dx + dy*dy) / (bandwidth * bandwidth)
+			if d2 > 9 {
+				continue
+			}
+			density.Set(c, r, density.At(c, r)+norm*math.Exp(-d2/2))
+		}
+	}
+}
+
+// DrawRenderLayer draws the flies in the mode chosen by the render settings and returns the labels of the colour ramp legend
+// (empty for the points mode, which uses the stage legend).
+func DrawRenderLayer(c canvas.Drawer, country Country, basemap Basemap, settings RenderSettings, history *RenderHistory, projection Projection) (string, string) {
+	switch settings.mode {
+	case RenderHeatmap:
+		density := KernelDensity(country, basemap.bounds, settings.cellSize, settings.bandwidth)
+		_, hi := density.Range()
+		drawRaster(c, density, settings.ramp, 0, hi, true, projection)
+		return "0", strconv.FormatFloat(hi, 'g', 3, 64) + " flies/km²"
+
+	case RenderChoropleth:
+		counts := QuadrantCounts(country, basemap.quadrants)
+		hi := 0
+		for _, n := range counts {
+			if n > hi {
+				hi = n
+			}
+		}
+		for _, q := range basemap.quadrants {
+			if counts[q.id] == 0 {
+				continue
+			}
+			fillQuadrant(c, q, settings.ramp.Color(float64(counts[q.id])/float64(hi)), projection)
+		}
+		return "1", strconv.Itoa(hi) + " flies"
+
+	case RenderOccupancy:
+		for _, q := range basemap.quadrants {
+			if history.occupied[q.id] == 0 {
+				continue
+			}
+			fillQuadrant(c, q, settings.ramp.Color(float64(history.occupied[q.id])/float64(history.timeSteps)), projection)
+		}
+		return "0", "1 (always occupied)"
+
+	case RenderArrival:
+		drawRaster(c, history.arrival, settings.ramp, float64(history.firstYear), float64(history.lastYear), false, projection)
+		return strconv.Itoa(history.firstYear), strconv.Itoa(history.lastYear)
+	}
+
+	return "", ""
+}
+
+// drawRaster fills every cell of the raster that has data with its colour on the ramp between lo and hi.
+// If skipZero is set, cells with a value of zero are left empty.
+func drawRaster(c canvas.Drawer, r Raster, ramp ColorRamp, lo, hi float64, skipZero bool, projection Projection) {
+	for row := 0; row < r.rows; row++ {
+		for col := 0; col < r.cols; col++ {
+			v := r.At(col, row)
+			if r.IsNoData(v) || (skipZero && v == 0) {
+				continue
+			}
+			t := 1.0
+			if hi > lo {
+				t = (v - lo) / (hi - lo)
+			}
+			b := r.CellBounds(col, row)
+			fillBounds(c, b, ramp.Color(t), projection)
+		}
+	}
+}
+
+// fillQuadrant fills a quadrant with a colour.
+func fillQuadrant(c canvas.Drawer, q Quadrant, col color.Color, projection Projection) {
+	fillBounds(c, Bounds{minLon: q.x, minLat: q.y, maxLon: q.x + q.width, maxLat: q.y + q.height}, col, projection)
+}
+
+// fillBounds fills the projected outline of a bounding box with a colour.
+func fillBounds(c canvas.Drawer, b Bounds, col color.Color, projection Projection) {
+	ring := []OrderedPair{{b.minLon, b.minLat}, {b.maxLon, b.minLat}, {b.maxLon, b.maxLat}, {b.minLon, b.maxLat}}
+	c.SetFillColor(col)
+	tracePath(c, ring, projection)
+	c.Fill()
+}
+
+// DrawRampLegend draws the colour ramp as a vertical gradient with its top right corner at (right, top), labelled with lo and hi.
+func DrawRampLegend(c canvas.Drawer, ramp ColorRamp, lo, hi string, right, top, fontSize float64) {
+	const steps = 50
+	width := fontSize
+	height := fontSize * 10
+	left := right - width
+
+	for i := 0; i < steps; i++ {
+		// highest values at the top
+		c.SetFillColor(ramp.Color(1 - float64(i)/(steps-1)))
+		c.Rect(left, top+height*float64(i)/steps, width, height/steps+1)
+		c.Fill()
+	}
+
+	c.SetFillColor(canvas.MakeColor(255, 255, 255))
+	w, _ := c.TextSize(hi)
+	c.FillText(hi, left-w-fontSize/2, top+fontSize*0.8)
+	w, _ = c.TextSize(lo)
+	c.FillText(lo, left-w-fontSize/2, top+height)
+}
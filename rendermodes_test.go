@@ -0,0 +1,161 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+type NewRenderSettingsTest struct {
+	mode      string
+	ramp      string
+	cellSize  float64
+	bandwidth float64
+	fails     bool
+}
+
+type ColorRampTest struct {
+	t      float64
+	result color.Color
+}
+
+type RasterCellTest struct {
+	lon, lat float64
+	col, row int
+	ok       bool
+}
+
+func TestNewRenderSettings(t *testing.T) {
+	tests := []NewRenderSettingsTest{
+		{mode: "points", ramp: "viridis", cellSize: 0.25, bandwidth: 25},
+		{mode: "HEATMAP", ramp: "Magma", cellSize: 0.25, bandwidth: 25},
+		{mode: "arrival", ramp: "greys", cellSize: 1, bandwidth: 1},
+		{mode: "contour", ramp: "viridis", cellSize: 0.25, bandwidth: 25, fails: true},
+		{mode: "points", ramp: "rainbow", cellSize: 0.25, bandwidth: 25, fails: true},
+		{mode: "heatmap", ramp: "viridis", cellSize: 0, bandwidth: 25, fails: true},
+		{mode: "heatmap", ramp: "viridis", cellSize: 0.25, bandwidth: -1, fails: true},
+	}
+
+	for _, test := range tests {
+		_, err := NewRenderSettings(test.mode, test.ramp, test.cellSize, test.bandwidth)
+		if (err != nil) != test.fails {
+			t.Errorf("NewRenderSettings(%q, %q, %v, %v) error = %v, want error %v", test.mode, test.ramp, test.cellSize, test.bandwidth, err, test.fails)
+		}
+	}
+}
+
+func TestColorRamp(t *testing.T) {
+	ramp := ColorRamp{{0, 0, 0, 255}, {100, 200, 50, 255}, {200, 0, 250, 255}}
+	tests := []ColorRampTest{
+		{t: 0, result: color.RGBA{0, 0, 0, 255}},
+		{t: 0.25, result: color.RGBA{50, 100, 25, 255}},
+		{t: 0.5, result: color.RGBA{100, 200, 50, 255}},
+		{t: 1, result: color.RGBA{200, 0, 250, 255}},
+		{t: -3, result: color.RGBA{0, 0, 0, 255}},
+		{t: 7, result: color.RGBA{200, 0, 250, 255}},
+		{t: math.NaN(), result: color.RGBA{0, 0, 0, 255}},
+	}
+
+	for _, test := range tests {
+		if result := ramp.Color(test.t); result != test.result {
+			t.Errorf("ColorRamp.Color(%v) = %v, want %v", test.t, result, test.result)
+		}
+	}
+}
+
+func TestRasterCell(t *testing.T) {
+	// 3 x 2 cells of one degree, extended south from 40.5 to 40
+	r := NewRaster(Bounds{minLon: -80, minLat: 40.5, maxLon: -77, maxLat: 42}, 1, 0)
+	if r.cols != 3 || r.rows != 2 || r.bounds.minLat != 40 {
+		t.Fatalf("NewRaster has %d x %d cells down to %v, want 3 x 2 down to 40", r.cols, r.rows, r.bounds.minLat)
+	}
+
+	tests := []RasterCellTest{
+		{lon: -80, lat: 42, col: 0, row: 0, ok: true},
+		{lon: -79.5, lat: 41.5, col: 0, row: 0, ok: true},
+		{lon: -77.01, lat: 40.01, col: 2, row: 1, ok: true},
+		{lon: -77, lat: 41, ok: false},
+		{lon: -80.01, lat: 41, ok: false},
+		{lon: -79, lat: 42.01, ok: false},
+		{lon: -79, lat: 39.99, ok: false},
+	}
+	for _, test := range tests {
+		col, row, ok := r.Cell(test.lon, test.lat)
+		if ok != test.ok || (ok && (col != test.col || row != test.row)) {
+			t.Errorf("Raster.Cell(%v, %v) = %d, %d, %v, want %d, %d, %v", test.lon, test.lat, col, row, ok, test.col, test.row, test.ok)
+		}
+		if ok {
+			lon, lat := r.CellCenter(col, row)
+			if c, rr, _ := r.Cell(lon, lat); c != col || rr != row {
+				t.Errorf("the centre of cell %d, %d is in cell %d, %d", col, row, c, rr)
+			}
+		}
+	}
+}
+
+func TestRasterRange(t *testing.T) {
+	r := NewRaster(Bounds{minLon: 0, minLat: 0, maxLon: 3, maxLat: 1}, 1, math.NaN())
+	if lo, hi := r.Range(); !math.IsNaN(lo) || !math.IsNaN(hi) {
+		t.Errorf("Range of an empty raster = %v, %v, want NaN, NaN", lo, hi)
+	}
+	r.Set(0, 0, 5)
+	r.Set(2, 0, -1)
+	if lo, hi := r.Range(); lo != -1 || hi != 5 {
+		t.Errorf("Range = %v, %v, want -1, 5", lo, hi)
+	}
+	if _, ok := r.ValueAt(1.5, 0.5); ok {
+		t.Errorf("ValueAt of a cell without data is ok")
+	}
+}
+
+// TestKernelDensity checks that the density of the flies adds up to the number of living flies, as long as they are more than three
+// bandwidths from the edges.
+func TestKernelDensity(t *testing.T) {
+	country := Country{flies: []Fly{
+		{position: OrderedPair{-78, 41}, isAlive: true},
+		{position: OrderedPair{-78.2, 41.3}, isAlive: true},
+		{position: OrderedPair{-77.5, 40.5}, isAlive: true},
+		{position: OrderedPair{-78, 41}, isAlive: false},
+	}}
+	bounds := Bounds{minLon: -81, minLat: 38, maxLon: -75, maxLat: 44}
+
+	for _, bandwidth := range []float64{10, 25, 50} {
+		density := KernelDensity(country, bounds, 0.05, bandwidth)
+		total := 0.0
+		for row := 0; row < density.rows; row++ {
+			for col := 0; col < density.cols; col++ {
+				total += density.At(col, row) * density.CellArea(row)
+			}
+		}
+		if math.Abs(total-3) > 0.05 {
+			t.Errorf("KernelDensity with a %v km bandwidth adds up to %.3f flies, want 3", bandwidth, total)
+		}
+	}
+}
+
+func TestRenderHistory(t *testing.T) {
+	quadrants := []Quadrant{{x: 0, y: 0, width: 1, height: 1, id: 0}, {x: 1, y: 0, width: 1, height: 1, id: 1}}
+	bounds := Bounds{minLon: 0, minLat: 0, maxLon: 2, maxLat: 1}
+	timePoints := []Country{
+		{date: date(2021, 8, 1), flies: []Fly{{position: OrderedPair{0.5, 0.5}, isAlive: true}}},
+		{date: date(2022, 8, 1), flies: []Fly{{position: OrderedPair{0.5, 0.5}, isAlive: true}, {position: OrderedPair{1.5, 0.5}, isAlive: true}}},
+		{date: date(2023, 8, 1), flies: []Fly{{position: OrderedPair{1.5, 0.5}, isAlive: false}}},
+	}
+
+	occupancy := NewRenderHistory(RenderSettings{mode: RenderOccupancy, cellSize: 1}, quadrants, bounds)
+	arrival := NewRenderHistory(RenderSettings{mode: RenderArrival, cellSize: 1}, quadrants, bounds)
+	for _, country := range timePoints {
+		occupancy.Add(country)
+		arrival.Add(country)
+	}
+
+	if occupancy.timeSteps != 3 || occupancy.occupied[0] != 2 || occupancy.occupied[1] != 1 {
+		t.Errorf("occupancy after 3 time points: %d steps, occupied %v, want 3 steps, map[0:2 1:1]", occupancy.timeSteps, occupancy.occupied)
+	}
+	if arrival.firstYear != 2021 || arrival.lastYear != 2023 {
+		t.Errorf("arrival years %d to %d, want 2021 to 2023", arrival.firstYear, arrival.lastYear)
+	}
+	if a, b := arrival.arrival.At(0, 0), arrival.arrival.At(1, 0); a != 2021 || b != 2022 {
+		t.Errorf("arrival years of the cells = %v, %v, want 2021, 2022", a, b)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scenario perturbs the weather of a run to represent a climate future.
+// Every daily minimum and maximum temperature is multiplied by the scale of its month and then shifted by the delta of its month,
+// whichever source it came from. A scenario can also replace the temperature grids with downscaled projections (see GridTemperatures).
+type Scenario struct {
+	name  string
+	add   [12]float64 // °C added in each month, January first
+	scale [12]float64 // factor applied in each month before the delta is added
+	grid  string      // folder of projected daily grids, empty to keep the weather's own temperatures
+}
+
+// Baseline returns the scenario that leaves the weather unchanged.
+func Baseline() Scenario {
+	s := Scenario{name: "baseline"}
+	for m := range s.scale {
+		s.scale[m] = 1
+	}
+	return s
+}
+
+// Apply returns the perturbed value of a temperature on a date.
+func (s Scenario) Apply(temperature float64, date time.Time) float64 {
+	m := date.Month() - 1
+	return temperature*s.scale[m] + s.add[m]
+}
+
+// Weather returns the weather of a run under the scenario. If the scenario has projected grids, they replace the weather's provider.
+func (s Scenario) Weather(weather Weather) (Weather, error) {
+	if s.grid != "" {
+		grid, err := NewGridTemperatures(s.grid)
+		if err != nil {
+			return Weather{}, fmt.Errorf("scenario %s: %v", s.name, err)
+		}
+		weather.provider = grid
+	}
+	weather.scenario = &s
+	return weather, nil
+}
+
+// ParseScenarios reads scenario definitions. spec is either a JSON file (see LoadScenarios) or a comma-separated list of shorthands:
+// "baseline", a signed number of °C added all year ("+2", "-1.5"), or a factor applied all year ("x1.1").
+// An empty spec gives the baseline alone.
+func ParseScenarios(spec string) ([]Scenario, error) {
+	if strings.HasSuffix(strings.ToLower(spec), ".json") {
+		return LoadScenarios(spec)
+	}
+
+	var scenarios []Scenario
+	names := make(map[string]bool)
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if names[strings.ToLower(field)] {
+			return nil, fmt.Errorf("scenario %s is given twice", field)
+		}
+		names[strings.ToLower(field)] = true
+		s := Baseline()
+		s.name = field
+		switch {
+		case strings.EqualFold(field, "baseline"):
+			s.name = "baseline"
+		case field[0] == '+' || field[0] == '-':
+			delta, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid scenario %q, expected e.g. +2", field)
+			}
+			for m := range s.add {
+				s.add[m] = delta
+			}
+		case field[0] == 'x' || field[0] == '*':
+			factor, err := strconv.ParseFloat(field[1:], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid scenario %q, expected e.g. x1.1", field)
+			}
+			for m := range s.scale {
+				s.scale[m] = factor
+			}
+		default:
+			return nil, fmt.Errorf("unknown scenario %q (want baseline, +N, -N, xN or a .json file)", field)
+		}
+		scenarios = append(scenarios, s)
+	}
+	if len(scenarios) == 0 {
+		scenarios = []Scenario{Baseline()}
+	}
+	return scenarios, nil
+}
+
+// monthlyValues is a JSON value that is either one number for every month or a list of twelve, January first.
+type monthlyValues []float64
+
+// UnmarshalJSON accepts a number or a list of twelve numbers.
+func (v *monthlyValues) UnmarshalJSON(data []byte) error {
+	var single float64
+	if err := json.Unmarshal(data, &single); err == nil {
+		*v = make(monthlyValues, 12)
+		for m := range *v {
+			(*v)[m] = single
+		}
+		return nil
+	}
+	var months []float64
+	if err := json.Unmarshal(data, &months); err != nil || len(months) != 12 {
+		return fmt.Errorf("expected a number or a list of 12 monthly values, got %s", data)
+	}
+	*v = months
+	return nil
+}
+
+// LoadScenarios reads a JSON file with a list of scenarios, such as
+//
+//	[{"name": "baseline"},
+//	 {"name": "+2C", "add": 2},
+//	 {"name": "warmer summers", "add": [0, 0, 0, 0, 1, 2, 2, 2, 1, 0, 0, 0]},
+//	 {"name": "RCP8.5 2050", "grid": "Data/LOCA_rcp85_2050"}]
+//
+// add is in °C and scale a factor, each either one number or twelve monthly values; grid is a folder of projected daily grids.
+func LoadScenarios(filename string) ([]Scenario, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading scenarios: %v", err)
+	}
+
+	var definitions []struct {
+		Name  string        `json:"name"`
+		Add   monthlyValues `json:"add"`
+		Scale monthlyValues `json:"scale"`
+		Grid  string        `json:"grid"`
+	}
+	if err := json.Unmarshal(data, &definitions); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if len(definitions) == 0 {
+		return nil, fmt.Errorf("%s defines no scenarios", filename)
+	}
+
+	scenarios := make([]Scenario, len(definitions))
+	names := make(map[string]bool)
+	for i, d := range definitions {
+		if d.Name == "" {
+			return nil, fmt.Errorf("%s: scenario %d has no name", filename, i+1)
+		}
+		if names[d.Name] {
+			return nil, fmt.Errorf("%s: scenario %q is defined twice", filename, d.Name)
+		}
+		names[d.Name] = true
+
+		s := Baseline()
+		s.name, s.grid = d.Name, d.Grid
+		copy(s.add[:], d.Add)
+		if d.Scale != nil {
+			copy(s.scale[:], d.Scale)
+		}
+		scenarios[i] = s
+	}
+	return scenarios, nil
+}
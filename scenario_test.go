@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type ParseScenariosTest struct {
+	spec  string
+	names []string
+	// temperature 10 °C under each scenario in January and in July
+	january, july []float64
+	fails         bool
+}
+
+type LoadScenariosTest struct {
+	json          string
+	names         []string
+	january, july []float64
+	grid          string // grid folder of the last scenario
+	fails         bool
+}
+
+func checkScenarios(t *testing.T, call string, scenarios []Scenario, names []string, january, july []float64) {
+	t.Helper()
+	if len(scenarios) != len(names) {
+		t.Errorf("%s gave %d scenarios, want %d", call, len(scenarios), len(names))
+		return
+	}
+	for i, s := range scenarios {
+		jan := s.Apply(10, date(2030, time.January, 15))
+		jul := s.Apply(10, date(2030, time.July, 15))
+		if s.name != names[i] || !closeTo(jan, january[i]) || !closeTo(jul, july[i]) {
+			t.Errorf("%s scenario %d is %q giving 10 °C as %v in January and %v in July, want %q, %v and %v",
+				call, i, s.name, jan, jul, names[i], january[i], july[i])
+		}
+	}
+}
+
+func TestParseScenarios(t *testing.T) {
+	tests := []ParseScenariosTest{
+		{spec: "", names: []string{"baseline"}, january: []float64{10}, july: []float64{10}},
+		{spec: "Baseline, +2, -1.5, x1.1", names: []string{"baseline", "+2", "-1.5", "x1.1"}, january: []float64{10, 12, 8.5, 11}, july: []float64{10, 12, 8.5, 11}},
+		{spec: "*2", names: []string{"*2"}, january: []float64{20}, july: []float64{20}},
+		{spec: "+2,+2", fails: true},
+		{spec: "+two", fails: true},
+		{spec: "xbig", fails: true},
+		{spec: "rcp85", fails: true},
+	}
+
+	for _, test := range tests {
+		scenarios, err := ParseScenarios(test.spec)
+		if (err != nil) != test.fails {
+			t.Errorf("ParseScenarios(%q) error = %v, want error %v", test.spec, err, test.fails)
+			continue
+		}
+		checkScenarios(t, "ParseScenarios("+test.spec+")", scenarios, test.names, test.january, test.july)
+	}
+}
+
+func TestLoadScenarios(t *testing.T) {
+	tests := []LoadScenariosTest{
+		{
+			json:    `[{"name": "baseline"}, {"name": "+2C", "add": 2}, {"name": "warmer summers", "add": [0, 0, 0, 0, 1, 2, 2.5, 2, 1, 0, 0, 0], "scale": 0.5}]`,
+			names:   []string{"baseline", "+2C", "warmer summers"},
+			january: []float64{10, 12, 5}, july: []float64{10, 12, 7.5},
+		},
+		{json: `[{"name": "RCP8.5 2050", "grid": "Data/LOCA_rcp85_2050"}]`, names: []string{"RCP8.5 2050"}, january: []float64{10}, july: []float64{10}, grid: "Data/LOCA_rcp85_2050"},
+		{json: `[]`, fails: true},
+		{json: `[{"add": 1}]`, fails: true},
+		{json: `[{"name": "a"}, {"name": "a"}]`, fails: true},
+		{json: `[{"name": "a", "add": [1, 2, 3]}]`, fails: true},
+		{json: `[{"name": "a", "add": "warm"}]`, fails: true},
+		{json: `{"name": "a"}`, fails: true},
+	}
+
+	for i, test := range tests {
+		path := writeTestFile(t, "scenarios.json", test.json)
+		scenarios, err := ParseScenarios(path)
+		if (err != nil) != test.fails {
+			t.Errorf("LoadScenarios(test %d) error = %v, want error %v", i, err, test.fails)
+			continue
+		}
+		checkScenarios(t, "LoadScenarios("+test.json+")", scenarios, test.names, test.january, test.july)
+		if len(scenarios) > 0 && scenarios[len(scenarios)-1].grid != test.grid {
+			t.Errorf("LoadScenarios(test %d) grid = %q, want %q", i, scenarios[len(scenarios)-1].grid, test.grid)
+		}
+	}
+
+	if _, err := LoadScenarios(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("LoadScenarios of a missing file did not fail")
+	}
+}
+
+// TestScenarioWeather checks that a scenario is applied to every source of temperatures, and that its grids replace the weather's.
+func TestScenarioWeather(t *testing.T) {
+	weather := Weather{Quadrants: []Quadrant{{x: 0, y: 0, width: 1, height: 1, temp: 20, minTemp: 5}}}
+	scenarios, err := ParseScenarios("+3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := scenarios[0].Weather(weather)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmin, tmax := GetTemperature(OrderedPair{0.5, 0.5}, w.On(date(2030, 7, 1))); tmin != 8 || tmax != 23 {
+		t.Errorf("quadrant temperatures under +3 = %v to %v, want 8 to 23", tmin, tmax)
+	}
+	if weather.scenario != nil {
+		t.Errorf("Scenario.Weather changed the baseline weather")
+	}
+
+	dir := t.TempDir()
+	grid := "ncols 1\nnrows 1\nxllcorner 0\nyllcorner 0\ncellsize 1\n"
+	for name, value := range map[string]string{"tmin_20300701.asc": "14", "tmax_20300701.asc": "31"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(grid+value+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	projected := Baseline()
+	projected.name, projected.grid = "projected", dir
+	w, err = projected.Weather(weather)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmin, tmax := GetTemperature(OrderedPair{0.5, 0.5}, w.On(date(2030, 7, 1))); tmin != 14 || tmax != 31 {
+		t.Errorf("projected grid temperatures = %v to %v, want 14 to 31", tmin, tmax)
+	}
+
+	projected.grid = filepath.Join(dir, "missing")
+	if _, err := projected.Weather(weather); err == nil {
+		t.Errorf("a scenario with a missing grid folder did not fail")
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+)
+
+// Seeding strategies, selectable with -seed. Each places a number of egg masses per detection record (see Seeding.Seed).
+const (
+	SeedSample       = "sample"       // one egg for a random share of the records
+	SeedIntroduction = "introduction" // egg masses at a single introduction point, no records used
+	SeedDensity      = "density"      // egg masses per record by density class
+	SeedEstablished  = "established"  // egg masses per record of an established population
+	SeedCheckpoint   = "checkpoint"   // the flies and eggs of a prior run's checkpoint
+)
+
+// BerksCounty is the introduction point of the spotted lanternfly in North America: Pike Township, Berks County,
+// Pennsylvania, where it was first found in September 2014.
+var BerksCounty = OrderedPair{x: -75.68, y: 40.43}
+
+// densityEggMasses is the number of egg masses the density strategy places per record of each density class of the
+// detection dataset. Records with another or no class get one egg mass.
+var densityEggMasses = map[string]int{
+	"unpopulated": 0,
+	"low":         1,
+	"medium":      3,
+	"high":        10,
+}
+
+// Seeding decides which flies the simulation starts with.
+type Seeding struct {
+	strategy   string
+	fraction   float64     // sample: share of records that get an egg
+	eggMasses  int         // established: egg masses per record; introduction: egg masses in all
+	point      OrderedPair // introduction: where the egg masses are placed
+	checkpoint string      // checkpoint: file written by SaveCheckpoint
+}
+
+// NewSeeding checks the options of a seeding strategy. An eggMasses of 0 takes the strategy's default:
+// one per record for established and ten for an introduction.
+func NewSeeding(strategy string, fraction float64, eggMasses int, point OrderedPair, checkpoint string) (Seeding, error) {
+	s := Seeding{strategy: strings.ToLower(strategy), fraction: fraction, eggMasses: eggMasses, point: point, checkpoint: checkpoint}
+	if eggMasses < 0 {
+		return Seeding{}, fmt.Errorf("the number of egg masses cannot be negative")
+	}
+
+	switch s.strategy {
+	case SeedSample:
+		if fraction <= 0 || fraction > 1 {
+			return Seeding{}, fmt.Errorf("seed fraction %v is not between 0 and 1", fraction)
+		}
+	case SeedIntroduction:
+		if s.eggMasses == 0 {
+			s.eggMasses = 10
+		}
+	case SeedEstablished:
+		if s.eggMasses == 0 {
+			s.eggMasses = 1
+		}
+	case SeedDensity:
+	case SeedCheckpoint:
+		if checkpoint == "" {
+			return Seeding{}, fmt.Errorf("the checkpoint strategy needs a checkpoint file")
+		}
+	default:
+		return Seeding{}, fmt.Errorf("unknown seeding strategy %q (want sample, introduction, density, established or checkpoint)", strategy)
+	}
+	return s, nil
+}
+
+// UsesDetections reports whether the strategy places flies at detection records.
+func (s Seeding) UsesDetections() bool {
+	return s.strategy != SeedIntroduction && s.strategy != SeedCheckpoint
+}
+
+// Describe states what the strategy places, for printing before a run.
+func (s Seeding) Describe() string {
+	switch s.strategy {
+	case SeedSample:
+		return fmt.Sprintf("one egg for each of a random %.0f%% of the detection records", s.fraction*100)
+	case SeedIntroduction:
+		return fmt.Sprintf("%d egg masses at longitude %.2f, latitude %.2f; no detection records used", s.eggMasses, s.point.x, s.point.y)
+	case SeedDensity:
+		return "egg masses per detection record by density class: unpopulated 0, low 1, medium 3, high 10, other 1"
+	case SeedEstablished:
+		return fmt.Sprintf("%d egg masses per record of an established population", s.eggMasses)
+	case SeedCheckpoint:
+		return "the flies and pending eggs saved in " + s.checkpoint + "; no egg masses added"
+	}
+	return s.strategy
+}
+
+// Seed returns the flies and the pending eggs the simulation starts with, and the date of the checkpoint they come from
+// (the zero time for the other strategies, or a checkpoint without a date).
+// New eggs are placed at the rounded coordinates of their record, or at the introduction point, and start with an energy drawn
+// from the temperature range of their quadrant. An egg mass holds 30 to 59 eggs, like those laid during the simulation.
+func (s Seeding) Seed(detections []SampleData, weather Weather) ([]Fly, []Fly, time.Time, error) {
+	var flies []Fly
+	addEggMasses := func(position OrderedPair, masses int) {
+		for m := 0; m < masses; m++ {
+			numEggs := rand.Intn(30) + 30
+			for i := 0; i < numEggs; i++ {
+				flies = append(flies, seedEgg(position, weather))
+			}
+		}
+	}
+
+	switch s.strategy {
+	case SeedSample:
+		for _, record := range detections {
+			if rand.Float64() < s.fraction {
+				flies = append(flies, seedEgg(OrderedPair{x: record.RoundedLongitude, y: record.RoundedLatitude}, weather))
+			}
+		}
+	case SeedIntroduction:
+		addEggMasses(s.point, s.eggMasses)
+	case SeedDensity:
+		for _, record := range detections {
+			masses, ok := densityEggMasses[strings.ToLower(record.LydeDensity)]
+			if !ok {
+				masses = 1
+			}
+			addEggMasses(OrderedPair{x: record.RoundedLongitude, y: record.RoundedLatitude}, masses)
+		}
+	case SeedEstablished:
+		for _, record := range detections {
+			if record.LydeEstablished {
+				addEggMasses(OrderedPair{x: record.RoundedLongitude, y: record.RoundedLatitude}, s.eggMasses)
+			}
+		}
+	case SeedCheckpoint:
+		country, err := LoadCheckpoint(s.checkpoint)
+		if err != nil {
+			return nil, nil, time.Time{}, err
+		}
+		return country.flies, country.eggs, country.date, nil
+	}
+	return flies, nil, time.Time{}, nil
+}
+
+// StartDate returns the first day of a run from its initial country: the date of the checkpoint the country was seeded from, if it
+// has one, and start otherwise. A start given explicitly on the command line must then be the checkpoint's date, so a resumed run
+// cannot silently replay its flies on another day of the year.
+func StartDate(country Country, start time.Time, explicit bool) (time.Time, error) {
+	if country.date.IsZero() {
+		return start, nil
+	}
+	if explicit && !start.Equal(country.date) {
+		return time.Time{}, fmt.Errorf("the start date %s disagrees with the checkpoint, which was saved on %s", start.Format(dateLayout), country.date.Format(dateLayout))
+	}
+	return country.date, nil
+}
+
+// seedEgg creates a living egg of a random sex at a position, with an energy drawn from its quadrant's temperature range.
+func seedEgg(position OrderedPair, weather Weather) Fly {
+	egg := Fly{position: position, stage: 0, isAlive: true, female: drawSex(), heading: rand.Float64() * 2 * math.Pi}
+	egg.locationID = GetQuadrant(&egg, weather.Quadrants)
+	for _, q := range weather.Quadrants {
+		if q.id == egg.locationID {
+			egg.energy += randomInRange(q.temp, q.minTemp)
+		}
+	}
+	return egg
+}
+
+// checkpointFly is the JSON form of a fly in a checkpoint.
+type checkpointFly struct {
+	Longitude float64 `json:"lon"`
+	Latitude  float64 `json:"lat"`
+	Stage     int     `json:"stage"`
+	Energy    float64 `json:"energy"`
+	Alive     bool    `json:"alive"`
+	Age       int     `json:"age"`
+	EggMasses int     `json:"eggMasses"`
+	AdultDays int     `json:"adultDays"`
+	Heading   float64 `json:"heading"`
+	Sex       string  `json:"sex"` // female or male
+	Mated     bool    `json:"mated"`
+}
+
+// checkpointFile is the JSON form of a checkpoint.
+type checkpointFile struct {
+	Date  string          `json:"date"`
+	Flies []checkpointFly `json:"flies"`
+	Eggs  []checkpointFly `json:"eggs"`
+}
+
+// SaveCheckpoint writes the living flies and the pending eggs of a country to a JSON file, so a later run can start from them.
+func SaveCheckpoint(filename string, country Country) error {
+	toJSON := func(flies []Fly) []checkpointFly {
+		saved := make([]checkpointFly, 0, len(flies))
+		for _, fly := range flies {
+			if !fly.isAlive {
+				continue
+			}
+			saved = append(saved, checkpointFly{
+				Longitude: fly.position.x,
+				Latitude:  fly.position.y,
+				Stage:     fly.stage,
+				Energy:    fly.energy,
+				Alive:     fly.isAlive,
+				Age:       fly.age,
+				EggMasses: fly.eggMasses,
+				AdultDays: fly.adultDays,
+				Heading:   fly.heading,
+				Sex:       map[bool]string{true: "female", false: "male"}[fly.female],
+				Mated:     fly.mated,
+			})
+		}
+		return saved
+	}
+	return writeJSONFile(filename, checkpointFile{
+		Date:  country.date.Format(dateLayout),
+		Flies: toJSON(country.flies),
+		Eggs:  toJSON(country.eggs),
+	})
+}
+
+// LoadCheckpoint reads a checkpoint written by SaveCheckpoint. The country has the flies, pending eggs and date of the checkpoint.
+// Every fly must have its sex, female or male.
+func LoadCheckpoint(filename string) (Country, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return Country{}, fmt.Errorf("error reading checkpoint: %v", err)
+	}
+	var saved checkpointFile
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return Country{}, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	var country Country
+	if saved.Date != "" {
+		if country.date, err = time.Parse(dateLayout, saved.Date); err != nil {
+			return Country{}, fmt.Errorf("%s: invalid date %q", filename, saved.Date)
+		}
+	}
+	fromJSON := func(saved []checkpointFly, kind string) ([]Fly, error) {
+		flies := make([]Fly, len(saved))
+		for i, f := range saved {
+			flies[i] = Fly{
+				position:  OrderedPair{x: f.Longitude, y: f.Latitude},
+				stage:     f.Stage,
+				energy:    f.Energy,
+				isAlive:   f.Alive,
+				age:       f.Age,
+				eggMasses: f.EggMasses,
+				adultDays: f.AdultDays,
+				heading:   f.Heading,
+				mated:     f.Mated,
+			}
+			switch f.Sex {
+			case "female":
+				flies[i].female = true
+			case "male":
+			default:
+				return nil, fmt.Errorf("%s: %s %d has sex %q (want female or male)", filename, kind, i+1, f.Sex)
+			}
+		}
+		return flies, nil
+	}
+	if country.flies, err = fromJSON(saved.Flies, "fly"); err != nil {
+		return Country{}, err
+	}
+	if country.eggs, err = fromJSON(saved.Eggs, "egg"); err != nil {
+		return Country{}, err
+	}
+	return country, nil
+}
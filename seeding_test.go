@@ -0,0 +1,197 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type NewSeedingTest struct {
+	strategy  string
+	fraction  float64
+	eggMasses int
+	result    int // egg masses after the defaults
+	fails     bool
+}
+
+type SeedTest struct {
+	strategy           string
+	fraction           float64
+	eggMasses          int
+	minEggs, maxEggs   int
+	positions          []OrderedPair // where eggs may be placed
+	usesDetectionsWant bool
+}
+
+type StartDateTest struct {
+	checkpoint time.Time
+	start      time.Time
+	explicit   bool
+	result     time.Time
+	fails      bool
+}
+
+func TestNewSeeding(t *testing.T) {
+	tests := []NewSeedingTest{
+		{strategy: "sample", fraction: 0.5},
+		{strategy: "Introduction", result: 10},
+		{strategy: "introduction", eggMasses: 3, result: 3},
+		{strategy: "established", result: 1},
+		{strategy: "density"},
+		{strategy: "sample", fraction: 0, fails: true},
+		{strategy: "sample", fraction: 1.5, fails: true},
+		{strategy: "established", eggMasses: -1, fails: true},
+		{strategy: "checkpoint", fails: true}, // no file
+		{strategy: "everywhere", fails: true},
+	}
+
+	for _, test := range tests {
+		s, err := NewSeeding(test.strategy, test.fraction, test.eggMasses, BerksCounty, "")
+		if (err != nil) != test.fails {
+			t.Errorf("NewSeeding(%q, %v, %d) error = %v, want error %v", test.strategy, test.fraction, test.eggMasses, err, test.fails)
+			continue
+		}
+		if s.eggMasses != test.result {
+			t.Errorf("NewSeeding(%q, %v, %d) egg masses = %d, want %d", test.strategy, test.fraction, test.eggMasses, s.eggMasses, test.result)
+		}
+	}
+}
+
+// TestDescribe checks that the introduction point is printed longitude first, as it is given with -seed-point.
+func TestDescribe(t *testing.T) {
+	s, err := NewSeeding(SeedIntroduction, 0, 0, BerksCounty, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if description := s.Describe(); !strings.Contains(description, "10 egg masses at longitude -75.68, latitude 40.43") {
+		t.Errorf("Describe() = %q, want the egg masses at longitude -75.68, latitude 40.43", description)
+	}
+}
+
+func TestSeed(t *testing.T) {
+	weather := Weather{Quadrants: []Quadrant{{x: -80, y: 38, width: 6, height: 4, id: 0, temp: 30, minTemp: 10}}}
+	detections := []SampleData{
+		{RoundedLongitude: -79, RoundedLatitude: 40, LydeDensity: "High", LydeEstablished: true},
+		{RoundedLongitude: -78, RoundedLatitude: 41, LydeDensity: "unpopulated"},
+		{RoundedLongitude: -77, RoundedLatitude: 39, LydeDensity: "NA"},
+	}
+	all := []OrderedPair{{-79, 40}, {-78, 41}, {-77, 39}}
+	tests := []SeedTest{
+		{strategy: SeedSample, fraction: 1, minEggs: 3, maxEggs: 3, positions: all, usesDetectionsWant: true},
+		{strategy: SeedIntroduction, eggMasses: 2, minEggs: 60, maxEggs: 118, positions: []OrderedPair{BerksCounty}},
+		// high 10 masses, unpopulated none and an unknown class 1
+		{strategy: SeedDensity, minEggs: 11 * 30, maxEggs: 11 * 59, positions: []OrderedPair{{-79, 40}, {-77, 39}}, usesDetectionsWant: true},
+		{strategy: SeedEstablished, eggMasses: 2, minEggs: 60, maxEggs: 118, positions: []OrderedPair{{-79, 40}}, usesDetectionsWant: true},
+	}
+
+	for _, test := range tests {
+		s, err := NewSeeding(test.strategy, test.fraction, test.eggMasses, BerksCounty, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.UsesDetections() != test.usesDetectionsWant {
+			t.Errorf("UsesDetections(%s) = %v, want %v", test.strategy, s.UsesDetections(), test.usesDetectionsWant)
+		}
+		flies, eggs, start, err := s.Seed(detections, weather)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(flies) < test.minEggs || len(flies) > test.maxEggs || len(eggs) != 0 || !start.IsZero() {
+			t.Errorf("Seed(%s) = %d flies, %d eggs, start %v, want %d to %d flies", test.strategy, len(flies), len(eggs), start, test.minEggs, test.maxEggs)
+		}
+		for _, fly := range flies {
+			placed := false
+			for _, p := range test.positions {
+				placed = placed || fly.position == p
+			}
+			if !placed || fly.stage != 0 || !fly.isAlive || fly.energy < 10 || fly.energy > 30 {
+				t.Errorf("Seed(%s) placed %+v, want a living egg at one of %v with an energy of 10 to 30", test.strategy, fly, test.positions)
+				break
+			}
+		}
+	}
+}
+
+// TestCheckpointRoundTrip saves a country and seeds a run from it.
+func TestCheckpointRoundTrip(t *testing.T) {
+	country := Country{
+		date: date(2022, 9, 15),
+		flies: []Fly{
+			{position: OrderedPair{-75.5, 40.25}, stage: 5, energy: 1234.5, isAlive: true, age: 140, eggMasses: 2, adultDays: 40, heading: 1.25, female: true, mated: true},
+			{position: OrderedPair{-75, 40}, stage: 5, energy: 900, isAlive: true, age: 100, adultDays: 20, heading: -2},
+			{position: OrderedPair{-74, 41}, stage: 3, isAlive: false, cause: CauseCold},
+		},
+		eggs: []Fly{{position: OrderedPair{-75.5, 40.25}, isAlive: true, female: true, heading: 0.5}},
+	}
+	filename := filepath.Join(t.TempDir(), "checkpoint.json")
+	if err := SaveCheckpoint(filename, country); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewSeeding(SeedCheckpoint, 0, 0, OrderedPair{}, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flies, eggs, start, err := s.Seed(nil, Weather{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !start.Equal(country.date) {
+		t.Errorf("checkpoint date = %v, want %v", start, country.date)
+	}
+	// the dead fly is not saved
+	if len(flies) != 2 || len(eggs) != 1 {
+		t.Fatalf("checkpoint has %d flies and %d eggs, want 2 and 1", len(flies), len(eggs))
+	}
+	want := append(country.flies[:2:2], country.eggs...)
+	for i, got := range append(flies, eggs...) {
+		if got != want[i] {
+			t.Errorf("checkpoint fly %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+
+	// a checkpoint without a date starts on the -start date
+	undated := writeTestFile(t, "undated.json", `{"flies": [{"lon": -75, "lat": 40, "stage": 5, "alive": true, "sex": "male"}]}`)
+	loaded, err := LoadCheckpoint(undated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.date.IsZero() || len(loaded.flies) != 1 {
+		t.Errorf("undated checkpoint = %v with %d flies, want no date and 1 fly", loaded.date, len(loaded.flies))
+	}
+
+	for name, contents := range map[string]string{
+		"bad date": `{"date": "15/09/2022", "flies": []}`,
+		"not JSON": `flies`,
+	} {
+		if _, err := LoadCheckpoint(writeTestFile(t, "checkpoint.json", contents)); err == nil {
+			t.Errorf("LoadCheckpoint with %s did not fail", name)
+		}
+	}
+	if _, err := LoadCheckpoint(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("LoadCheckpoint of a missing file did not fail")
+	}
+}
+
+func TestStartDate(t *testing.T) {
+	saved, start := date(2022, 9, 15), date(2022, 5, 1)
+	tests := []StartDateTest{
+		{start: start, result: start},
+		{start: start, explicit: true, result: start},
+		{checkpoint: saved, start: start, result: saved},
+		{checkpoint: saved, start: saved, explicit: true, result: saved},
+		{checkpoint: saved, start: start, explicit: true, fails: true},
+	}
+
+	for _, test := range tests {
+		result, err := StartDate(Country{date: test.checkpoint}, test.start, test.explicit)
+		if (err != nil) != test.fails {
+			t.Errorf("StartDate(%v, %v, %v) error = %v, want error %v", test.checkpoint, test.start, test.explicit, err, test.fails)
+			continue
+		}
+		if !result.Equal(test.result) {
+			t.Errorf("StartDate(%v, %v, %v) = %v, want %v", test.checkpoint, test.start, test.explicit, result, test.result)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// viewerPage is the single page of the live viewer. It has no external dependencies so the viewer works offline.
+//
+//go:embed viewer.html
+var viewerPage []byte
+
+// Viewer runs a simulation one tick at a time and streams every new state to the connected browsers as server-sent events.
+// The browsers control the run through the /control endpoint: pause, play, single step and speed.
+type Viewer struct {
+	mu       sync.Mutex
+	country  Country
+	weather  Weather
+	bounds   Bounds // bounding box of the study region, sent to the browser
+	clock    *Clock
+	end      time.Time
+	paused   bool
+	speed    float64 // ticks per second while playing
+	steps    int     // single steps requested while paused
+	latest   []byte  // last frame sent, replayed to new browsers
+	clients  map[chan []byte]bool
+	finished bool
+}
+
+// ViewerFrame is the JSON message sent to the browser after every tick.
+// Flies are sent as [longitude, latitude, stage, alive] so that large populations stay compact.
+type ViewerFrame struct {
+	Date      string           `json:"date"`
+	Day       int              `json:"day"`
+	Paused    bool             `json:"paused"`
+	Finished  bool             `json:"finished"`
+	Speed     float64          `json:"speed"`
+	Bounds    [4]float64       `json:"bounds"` // minLon, minLat, maxLon, maxLat
+	Flies     [][4]float64     `json:"flies"`
+	Trees     [][2]float64     `json:"trees"`
+	Quadrants []ViewerQuadrant `json:"quadrants"`
+	Counts    [7]int           `json:"counts"`    // living flies per stage, index 6 counts the dead
+	Emigrated int              `json:"emigrated"` // flies that have left the study region
+}
+
+// ViewerQuadrant holds the details shown when hovering over a quadrant.
+type ViewerQuadrant struct {
+	ID     int     `json:"id"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	TMin   float64 `json:"tmin"` // daily minimum and maximum temperature at the centre of the quadrant, °C
+	TMax   float64 `json:"tmax"`
+	Counts [7]int  `json:"counts"`
+}
+
+// NewViewer creates a paused viewer for a run that starts from initialCountry and lasts numYears.
+func NewViewer(initialCountry Country, numYears int, weather Weather, bounds Bounds, clock *Clock) *Viewer {
+	country := CopyCountry(initialCountry)
+	country.date = clock.Date()
+
+	v := &Viewer{
+		country: country,
+		weather: weather,
+		bounds:  bounds,
+		clock:   clock,
+		end:     clock.Start().AddDate(numYears, 0, 0),
+		paused:  true,
+		speed:   5,
+		clients: make(map[chan []byte]bool),
+	}
+	v.latest = v.frame()
+	return v
+}
+
+// Serve runs the viewer's simulation loop and serves the viewer on addr until the HTTP server fails.
+func (v *Viewer) Serve(addr string) error {
+	go v.run()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", v.handlePage)
+	mux.HandleFunc("/events", v.handleEvents)
+	mux.HandleFunc("/control", v.handleControl)
+
+	fmt.Println("Viewer running at http://" + addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+// run advances the simulation while it is playing, or by one tick for every step request while it is paused.
+// It sleeps between ticks according to the current speed and broadcasts every new state.
+// The tick is computed on copies of the country and the clock without holding v.mu, so browsers can connect and send
+// controls while it runs; only run replaces v.country and v.clock.
+func (v *Viewer) run() {
+	for {
+		v.mu.Lock()
+		speed := v.speed
+		advance := !v.finished && (!v.paused || v.steps > 0)
+		if advance && v.steps > 0 {
+			v.steps--
+		}
+		country, clock := v.country, *v.clock
+		v.mu.Unlock()
+
+		if !advance {
+			time.Sleep(50 * time.Millisecond)
+			continue
+		}
+
+		country = StepSimulation(country, v.weather, &clock)
+
+		v.mu.Lock()
+		v.country = country
+		v.clock = &clock
+		if !clock.Date().Before(v.end) {
+			v.finished = true
+			v.paused = true
+		}
+		v.mu.Unlock()
+
+		v.broadcast()
+		time.Sleep(time.Duration(float64(time.Second) / speed))
+	}
+}
+
+// frame encodes the current state of the viewer as JSON. The caller must hold v.mu.
+func (v *Viewer) frame() []byte {
+	f := ViewerFrame{
+		Date:     v.country.date.Format(dateLayout),
+		Day:      v.clock.DaysElapsed(),
+		Paused:   v.paused,
+		Finished: v.finished,
+		Speed:    v.speed,
+		Bounds:   [4]float64{v.bounds.minLon, v.bounds.minLat, v.bounds.maxLon, v.bounds.maxLat},
+		Flies:    make([][4]float64, 0, len(v.country.flies)),
+		Trees:    make([][2]float64, 0, len(v.country.trees)),
+
+		Emigrated: v.country.emigrated,
+	}
+
+	// the temperatures of the day the next tick starts with, from the same providers the flies see
+	weather := v.weather.On(v.country.date)
+	quadrants := weather.Quadrants
+	index := make(map[int]int, len(quadrants))
+	for i, q := range quadrants {
+		index[q.id] = i
+		tmin, tmax := GetTemperature(OrderedPair{q.x + q.width/2, q.y + q.height/2}, weather)
+		f.Quadrants = append(f.Quadrants, ViewerQuadrant{ID: q.id, X: q.x, Y: q.y, Width: q.width, Height: q.height, TMin: tmin, TMax: tmax})
+	}
+
+	for i := range v.country.flies {
+		fly := &v.country.flies[i]
+		alive := 0.0
+		stage := fly.stage
+		if fly.isAlive {
+			alive = 1
+		} else {
+			stage = 6
+		}
+		if stage >= 0 && stage < len(f.Counts) {
+			f.Counts[stage]++
+			if j, ok := index[GetQuadrant(fly, quadrants)]; ok {
+				f.Quadrants[j].Counts[stage]++
+			}
+		}
+		f.Flies = append(f.Flies, [4]float64{fly.position.x, fly.position.y, float64(fly.stage), alive})
+	}
+
+	for _, tree := range v.country.trees {
+		f.Trees = append(f.Trees, [2]float64{tree.position.x, tree.position.y})
+	}
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		fmt.Println("Error encoding viewer frame:", err)
+		return nil
+	}
+	return data
+}
+
+// broadcast sends the current state to every connected browser.
+// A browser that has not yet read the previous frame skips this one rather than slowing the simulation down.
+func (v *Viewer) broadcast() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.latest = v.frame()
+	for client := range v.clients {
+		select {
+		case client <- v.latest:
+		default:
+		}
+	}
+}
+
+// handlePage serves the viewer page.
+func (v *Viewer) handlePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(viewerPage)
+}
+
+// handleEvents streams frames to one browser as server-sent events until the browser disconnects.
+func (v *Viewer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	client := make(chan []byte, 1)
+	v.mu.Lock()
+	v.clients[client] = true
+	client <- v.latest
+	v.mu.Unlock()
+
+	defer func() {
+		v.mu.Lock()
+		delete(v.clients, client)
+		v.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case data := <-client:
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
+
+// handleControl applies a control action sent by the browser.
+// Supported actions are play, pause, step and speed (with a value in ticks per second).
+func (v *Viewer) handleControl(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	v.mu.Lock()
+	switch r.FormValue("action") {
+	case "play":
+		v.paused = v.finished
+	case "pause":
+		v.paused = true
+	case "step":
+		v.paused = true
+		v.steps++
+	case "speed":
+		speed, err := strconv.ParseFloat(r.FormValue("value"), 64)
+		if err != nil || speed <= 0 {
+			v.mu.Unlock()
+			http.Error(w, "speed must be a positive number", http.StatusBadRequest)
+			return
+		}
+		v.speed = speed
+	default:
+		v.mu.Unlock()
+		http.Error(w, "unknown action", http.StatusBadRequest)
+		return
+	}
+	v.mu.Unlock()
+
+	// let the browsers see the new play state straight away
+	v.broadcast()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type HandleControlTest struct {
+	paused bool // play state before the request
+	method string
+	form   url.Values
+	status int
+	// play state after the request
+	resultPaused bool
+	resultSpeed  float64
+	resultSteps  int
+}
+
+func newTestViewer() *Viewer {
+	quadrants := []Quadrant{
+		{x: -80, y: 40, width: 1, height: 1, id: 0, temp: 25, minTemp: 12},
+		{x: -79, y: 40, width: 1, height: 1, id: 1, temp: 20, minTemp: 8},
+	}
+	country := Country{
+		flies: []Fly{
+			{position: OrderedPair{-79.5, 40.5}, stage: 1, isAlive: true},
+			{position: OrderedPair{-79.5, 40.2}, stage: 5, isAlive: true},
+			{position: OrderedPair{-78.5, 40.5}, stage: 5, isAlive: true},
+			{position: OrderedPair{-78.5, 40.5}, stage: 3, isAlive: false},
+		},
+		trees:     []Tree{{position: OrderedPair{-79.2, 40.8}}},
+		emigrated: 2,
+	}
+	weather := Weather{x: -80, y: 40, Quadrants: quadrants}
+	clock := NewClock(date(2021, 7, 1), Daily)
+	return NewViewer(country, 1, weather, Bounds{minLon: -80, minLat: 40, maxLon: -78, maxLat: 41}, clock)
+}
+
+func TestViewerFrame(t *testing.T) {
+	v := newTestViewer()
+
+	var f ViewerFrame
+	if err := json.Unmarshal(v.frame(), &f); err != nil {
+		t.Fatalf("frame is not valid JSON: %v", err)
+	}
+
+	if f.Date != "2021-07-01" || f.Day != 0 || !f.Paused || f.Finished {
+		t.Errorf("frame date %s, day %d, paused %v, finished %v, want 2021-07-01, 0, true, false", f.Date, f.Day, f.Paused, f.Finished)
+	}
+	if f.Bounds != [4]float64{-80, 40, -78, 41} {
+		t.Errorf("frame bounds = %v, want [-80 40 -78 41]", f.Bounds)
+	}
+	if len(f.Flies) != 4 || len(f.Trees) != 1 || f.Emigrated != 2 {
+		t.Errorf("frame has %d flies, %d trees and %d emigrated, want 4, 1 and 2", len(f.Flies), len(f.Trees), f.Emigrated)
+	}
+	if want := [7]int{0, 1, 0, 0, 0, 2, 1}; f.Counts != want {
+		t.Errorf("frame counts = %v, want %v", f.Counts, want)
+	}
+
+	want := []ViewerQuadrant{
+		{ID: 0, X: -80, Y: 40, Width: 1, Height: 1, TMin: 12, TMax: 25, Counts: [7]int{0, 1, 0, 0, 0, 1, 0}},
+		{ID: 1, X: -79, Y: 40, Width: 1, Height: 1, TMin: 8, TMax: 20, Counts: [7]int{0, 0, 0, 0, 0, 1, 1}},
+	}
+	if len(f.Quadrants) != len(want) {
+		t.Fatalf("frame has %d quadrants, want %d", len(f.Quadrants), len(want))
+	}
+	for i := range want {
+		if f.Quadrants[i] != want[i] {
+			t.Errorf("frame quadrant %d = %+v, want %+v", i, f.Quadrants[i], want[i])
+		}
+	}
+}
+
+func TestViewerFrameScenario(t *testing.T) {
+	v := newTestViewer()
+	scenarios, err := ParseScenarios("+2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.weather.scenario = &scenarios[0]
+
+	var f ViewerFrame
+	if err := json.Unmarshal(v.frame(), &f); err != nil {
+		t.Fatalf("frame is not valid JSON: %v", err)
+	}
+	// the hover shows the temperatures the flies see, scenario included
+	if q := f.Quadrants[0]; q.TMin != 14 || q.TMax != 27 {
+		t.Errorf("quadrant temperatures under +2 °C = %v to %v, want 14 to 27", q.TMin, q.TMax)
+	}
+}
+
+func TestHandleControl(t *testing.T) {
+	tests := []HandleControlTest{
+		{paused: true, method: http.MethodPost, form: url.Values{"action": {"play"}}, status: http.StatusNoContent, resultPaused: false, resultSpeed: 5},
+		{paused: false, method: http.MethodPost, form: url.Values{"action": {"pause"}}, status: http.StatusNoContent, resultPaused: true, resultSpeed: 5},
+		{paused: false, method: http.MethodPost, form: url.Values{"action": {"step"}}, status: http.StatusNoContent, resultPaused: true, resultSpeed: 5, resultSteps: 1},
+		{paused: true, method: http.MethodPost, form: url.Values{"action": {"speed"}, "value": {"12.5"}}, status: http.StatusNoContent, resultPaused: true, resultSpeed: 12.5},
+		{paused: true, method: http.MethodPost, form: url.Values{"action": {"speed"}, "value": {"0"}}, status: http.StatusBadRequest, resultPaused: true, resultSpeed: 5},
+		{paused: true, method: http.MethodPost, form: url.Values{"action": {"speed"}, "value": {"fast"}}, status: http.StatusBadRequest, resultPaused: true, resultSpeed: 5},
+		{paused: true, method: http.MethodPost, form: url.Values{"action": {"rewind"}}, status: http.StatusBadRequest, resultPaused: true, resultSpeed: 5},
+		{paused: true, method: http.MethodGet, form: url.Values{"action": {"play"}}, status: http.StatusMethodNotAllowed, resultPaused: true, resultSpeed: 5},
+	}
+
+	for _, test := range tests {
+		v := newTestViewer()
+		v.paused = test.paused
+
+		request := httptest.NewRequest(test.method, "/control", strings.NewReader(test.form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		response := httptest.NewRecorder()
+		v.handleControl(response, request)
+
+		if response.Code != test.status {
+			t.Errorf("%s /control %v: status %d, want %d", test.method, test.form, response.Code, test.status)
+		}
+		if v.paused != test.resultPaused || v.speed != test.resultSpeed || v.steps != test.resultSteps {
+			t.Errorf("%s /control %v: paused %v, speed %v, steps %d, want %v, %v, %d", test.method, test.form,
+				v.paused, v.speed, v.steps, test.resultPaused, test.resultSpeed, test.resultSteps)
+		}
+	}
+}
+
+func TestHandleControlPlayWhenFinished(t *testing.T) {
+	v := newTestViewer()
+	v.finished = true
+
+	request := httptest.NewRequest(http.MethodPost, "/control", strings.NewReader("action=play"))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	v.handleControl(httptest.NewRecorder(), request)
+	if !v.paused {
+		t.Errorf("a finished run started playing again")
+	}
+}
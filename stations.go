@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Station is the source of one climate file: a state-wide NOAA series placed at the centroid of its state.
+type Station struct {
+	id       string // postal code of the state, e.g. "PA"
+	name     string // region named in the file, e.g. "Pennsylvania"
+	file     string
+	location OrderedPair
+	maxTemp  float64 // latest maximum temperature, °C
+	minTemp  float64 // latest minimum temperature, °C
+}
+
+// Fill strategies for quadrants without a station, selectable with -fill.
+const (
+	FillNone    = "none"    // a quadrant without a station is an error
+	FillNearest = "nearest" // use the station closest to the centre of the quadrant
+	FillMean    = "mean"    // use the mean of all stations
+)
+
+// DiscoverStations reads every CSV file in a season folder (such as Data/Hatch_May-Jun) with ReadClimateFile.
+// Each file is matched to a state by the region named in its title, or failing that by its file name (PA.csv),
+// and the station is placed at the centroid of that state's outline in boundaries.
+// Any file that cannot be read or placed is an error, as are two files for the same state.
+func DiscoverStations(folder string, boundaries []Boundary) ([]Station, error) {
+	files, err := filepath.Glob(filepath.Join(folder, "*.csv"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		if _, err := os.Stat(folder); err != nil {
+			return nil, fmt.Errorf("error reading weather folder: %v", err)
+		}
+		return nil, fmt.Errorf("weather folder %s has no CSV files", folder)
+	}
+	sort.Strings(files)
+
+	var stations []Station
+	seen := make(map[string]string)
+	for _, file := range files {
+		series, err := ReadClimateFile(file)
+		if err != nil {
+			return nil, err
+		}
+		temps, err := temperatureRange(series)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+
+		region := series[0].region
+		stem := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		boundary, ok := findBoundary(boundaries, region, stem)
+		if !ok {
+			return nil, fmt.Errorf("%s: cannot find %q (or %q) in the boundary file to place its station", file, region, stem)
+		}
+		if other, dup := seen[boundary.postal]; dup {
+			return nil, fmt.Errorf("%s and %s are both for %s", other, file, boundary.name)
+		}
+		seen[boundary.postal] = file
+
+		stations = append(stations, Station{
+			id:       boundary.postal,
+			name:     region,
+			file:     file,
+			location: boundary.Centroid(),
+			maxTemp:  temps.x,
+			minTemp:  temps.y,
+		})
+	}
+	return stations, nil
+}
+
+// findBoundary looks a state up by name or postal code, ignoring case.
+func findBoundary(boundaries []Boundary, keys ...string) (Boundary, bool) {
+	for _, key := range keys {
+		for _, b := range boundaries {
+			if strings.EqualFold(b.name, key) || strings.EqualFold(b.postal, key) {
+				return b, true
+			}
+		}
+	}
+	return Boundary{}, false
+}
+
+// Centroid returns the area-weighted centroid of the outer rings of the boundary's polygons.
+func (b Boundary) Centroid() OrderedPair {
+	var cx, cy, total float64
+	for _, polygon := range b.polygons {
+		if len(polygon) == 0 {
+			continue
+		}
+		ring := polygon[0]
+		var a, x, y float64
+		for i := 0; i+1 < len(ring); i++ {
+			cross := ring[i].x*ring[i+1].y - ring[i+1].x*ring[i].y
+			a += cross
+			x += (ring[i].x + ring[i+1].x) * cross
+			y += (ring[i].y + ring[i+1].y) * cross
+		}
+		if a == 0 {
+			continue
+		}
+		if a < 0 { // clockwise ring
+			a, x, y = -a, -x, -y
+		}
+		// x/(3a) is the ring's centroid and a/2 its signed area, so the weighted sum only needs x/6 and y/6
+		cx += x / 6
+		cy += y / 6
+		total += a / 2
+	}
+	if total == 0 {
+		return OrderedPair{}
+	}
+	return OrderedPair{x: cx / total, y: cy / total}
+}
+
+// stationsIn returns the stations inside a quadrant.
+func stationsIn(q Quadrant, stations []Station) []Station {
+	var inside []Station
+	for _, s := range stations {
+		if s.location.x >= q.x && s.location.x <= q.x+q.width && s.location.y >= q.y && s.location.y <= q.y+q.height {
+			inside = append(inside, s)
+		}
+	}
+	return inside
+}
+
+// CoverageGaps returns the quadrants that have no station inside them.
+func CoverageGaps(quadrants []Quadrant, stations []Station) []Quadrant {
+	var gaps []Quadrant
+	for _, q := range quadrants {
+		if len(stationsIn(q, stations)) == 0 {
+			gaps = append(gaps, q)
+		}
+	}
+	return gaps
+}
+
+// describeQuadrant names a quadrant by id and extent for error messages.
+func describeQuadrant(q Quadrant) string {
+	return fmt.Sprintf("quadrant %d (lon %.2f to %.2f, lat %.2f to %.2f)", q.id, q.x, q.x+q.width, q.y, q.y+q.height)
+}
+
+// AssignStations sets the temperatures of every quadrant to the mean of the stations inside it.
+// Quadrants without a station are filled according to fill; with FillNone they make AssignStations fail with a list of the gaps.
+func AssignStations(quadrants []Quadrant, stations []Station, fill string) error {
+	if len(stations) == 0 {
+		return fmt.Errorf("no weather stations")
+	}
+
+	gaps := CoverageGaps(quadrants, stations)
+	switch fill {
+	case FillNone:
+		if len(gaps) > 0 {
+			described := make([]string, len(gaps))
+			for i, q := range gaps {
+				described[i] = describeQuadrant(q)
+			}
+			return fmt.Errorf("%d of %d quadrants have no weather station: %s; choose a fill strategy (-fill nearest or -fill mean) to fill them",
+				len(gaps), len(quadrants), strings.Join(described, "; "))
+		}
+	case FillNearest, FillMean:
+	default:
+		return fmt.Errorf("unknown fill strategy %q (want none, nearest or mean)", fill)
+	}
+
+	for i := range quadrants {
+		q := &quadrants[i]
+		inside := stationsIn(*q, stations)
+		if len(inside) == 0 {
+			if fill == FillMean {
+				inside = stations
+			} else {
+				inside = []Station{nearestStation(*q, stations)}
+			}
+		}
+
+		q.temp, q.minTemp = 0, 0
+		for _, s := range inside {
+			q.temp += s.maxTemp
+			q.minTemp += s.minTemp
+		}
+		q.temp /= float64(len(inside))
+		q.minTemp /= float64(len(inside))
+	}
+	return nil
+}
+
+// nearestStation returns the station closest to the centre of a quadrant.
+func nearestStation(q Quadrant, stations []Station) Station {
+	centre := OrderedPair{x: q.x + q.width/2, y: q.y + q.height/2}
+	nearest := stations[0]
+	best := math.Inf(1)
+	for _, s := range stations {
+		if d := distance(centre, s.location); d < best {
+			nearest, best = s, d
+		}
+	}
+	return nearest
+}
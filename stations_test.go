@@ -0,0 +1,154 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+type CentroidTest struct {
+	boundary Boundary
+	result   OrderedPair
+}
+
+type AssignStationsTest struct {
+	fill    string
+	maxTemp []float64 // per quadrant
+	minTemp []float64
+	fails   bool
+}
+
+// square returns a closed square ring with its lower left corner at (x, y).
+func square(x, y, size float64) []OrderedPair {
+	return []OrderedPair{{x, y}, {x + size, y}, {x + size, y + size}, {x, y + size}, {x, y}}
+}
+
+func TestCentroid(t *testing.T) {
+	clockwise := []OrderedPair{{0, 0}, {0, 2}, {2, 2}, {2, 0}, {0, 0}}
+	tests := []CentroidTest{
+		{boundary: Boundary{polygons: [][][]OrderedPair{{square(-80, 40, 2)}}}, result: OrderedPair{-79, 41}},
+		{boundary: Boundary{polygons: [][][]OrderedPair{{clockwise}}}, result: OrderedPair{1, 1}},
+		// two islands weighted by area: 4 at (1, 1) and 1 at (10.5, 0.5)
+		{boundary: Boundary{polygons: [][][]OrderedPair{{square(0, 0, 2)}, {square(10, 0, 1)}}}, result: OrderedPair{2.9, 0.9}},
+		// holes are ignored
+		{boundary: Boundary{polygons: [][][]OrderedPair{{square(0, 0, 4), square(0, 0, 1)}}}, result: OrderedPair{2, 2}},
+		{boundary: Boundary{}, result: OrderedPair{}},
+	}
+
+	for _, test := range tests {
+		result := test.boundary.Centroid()
+		if math.Abs(result.x-test.result.x) > 1e-9 || math.Abs(result.y-test.result.y) > 1e-9 {
+			t.Errorf("Centroid(%v) = %v, want %v", test.boundary.polygons, result, test.result)
+		}
+	}
+}
+
+func TestAssignStations(t *testing.T) {
+	stations := []Station{
+		{id: "A", location: OrderedPair{0.5, 0.5}, maxTemp: 30, minTemp: 10},
+		{id: "B", location: OrderedPair{0.8, 0.2}, maxTemp: 20, minTemp: 6},
+		{id: "C", location: OrderedPair{2.5, 0.5}, maxTemp: 10, minTemp: 0},
+	}
+	tests := []AssignStationsTest{
+		{fill: FillNearest, maxTemp: []float64{25, 20, 10}, minTemp: []float64{8, 6, 0}},
+		{fill: FillMean, maxTemp: []float64{25, 20, 10}, minTemp: []float64{8, 16.0 / 3, 0}},
+		{fill: FillNone, fails: true},
+		{fill: "average", fails: true},
+	}
+
+	for _, test := range tests {
+		quadrants := []Quadrant{
+			{x: 0, y: 0, width: 1, height: 1, id: 0},
+			{x: 1, y: 0, width: 1, height: 1, id: 1}, // no station: B is nearest to (1.5, 0.5)
+			{x: 2, y: 0, width: 1, height: 1, id: 2},
+		}
+		err := AssignStations(quadrants, stations, test.fill)
+		if (err != nil) != test.fails {
+			t.Errorf("AssignStations(%q) error = %v, want error %v", test.fill, err, test.fails)
+			continue
+		}
+		if test.fails {
+			continue
+		}
+		for i, q := range quadrants {
+			if math.Abs(q.temp-test.maxTemp[i]) > 1e-9 || math.Abs(q.minTemp-test.minTemp[i]) > 1e-9 {
+				t.Errorf("AssignStations(%q) quadrant %d = %v to %v, want %v to %v", test.fill, i, q.minTemp, q.temp, test.minTemp[i], test.maxTemp[i])
+			}
+		}
+	}
+
+	if err := AssignStations([]Quadrant{{width: 1, height: 1}}, nil, FillMean); err == nil {
+		t.Errorf("AssignStations without stations did not fail")
+	}
+	if gaps := CoverageGaps([]Quadrant{{x: 1, y: 0, width: 1, height: 1}}, stations); len(gaps) != 1 {
+		t.Errorf("CoverageGaps found %d gaps, want 1", len(gaps))
+	}
+}
+
+// climateFile returns a Climate at a Glance file with one maximum and one minimum temperature series for a region, in °F.
+func climateFile(region string, maxF, minF float64) string {
+	block := func(variable string, value float64) string {
+		return region + " May-June " + variable + "\nUnits: Degrees Fahrenheit\nMissing: -99\nDate,Value,Anomaly\n202306," +
+			strconv.FormatFloat(value, 'f', -1, 64) + ",0\n"
+	}
+	return block("Maximum Temperature", maxF) + ",,\n" + block("Minimum Temperature", minF)
+}
+
+func TestDiscoverStations(t *testing.T) {
+	boundaries := []Boundary{
+		{name: "Pennsylvania", postal: "PA", polygons: [][][]OrderedPair{{square(-80, 40, 2)}}},
+		{name: "New Jersey", postal: "NJ", polygons: [][][]OrderedPair{{square(-75, 39, 2)}}},
+	}
+
+	write := func(dir, name, contents string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// matched by the region in the title and by the file name
+	dir := t.TempDir()
+	write(dir, "pennsylvania.csv", climateFile("Pennsylvania", 86, 50))
+	write(dir, "NJ.csv", climateFile("Garden State", 77, 59))
+	write(dir, "notes.txt", "not a climate file")
+	stations, err := DiscoverStations(dir, boundaries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stations) != 2 {
+		t.Fatalf("DiscoverStations found %d stations, want 2", len(stations))
+	}
+	byID := make(map[string]Station)
+	for _, s := range stations {
+		byID[s.id] = s
+	}
+	if s := byID["PA"]; s.location != (OrderedPair{-79, 41}) || math.Abs(s.maxTemp-30) > 1e-9 || math.Abs(s.minTemp-10) > 1e-9 {
+		t.Errorf("PA station = %+v, want at -79, 41 with 10 to 30 °C", s)
+	}
+	if s := byID["NJ"]; s.location != (OrderedPair{-74, 40}) || math.Abs(s.maxTemp-25) > 1e-9 || math.Abs(s.minTemp-15) > 1e-9 {
+		t.Errorf("NJ station = %+v, want at -74, 40 with 15 to 25 °C", s)
+	}
+
+	// errors
+	cases := map[string]map[string]string{
+		"two files for one state": {"a.csv": climateFile("Pennsylvania", 86, 50), "b.csv": climateFile("PA", 80, 50)},
+		"unknown state":           {"a.csv": climateFile("Ohio", 86, 50)},
+		"no minimum":              {"a.csv": "Pennsylvania May-June Maximum Temperature\nUnits: Degrees Fahrenheit\nDate,Value\n202306,80\n"},
+		"precipitation":           {"a.csv": "Pennsylvania May-June Maximum Temperature\nUnits: Inches\nDate,Value\n202306,3\n"},
+		"no CSV files":            {"a.txt": ""},
+	}
+	for name, files := range cases {
+		dir := t.TempDir()
+		for file, contents := range files {
+			write(dir, file, contents)
+		}
+		if _, err := DiscoverStations(dir, boundaries); err == nil {
+			t.Errorf("DiscoverStations with %s did not fail", name)
+		}
+	}
+	if _, err := DiscoverStations(filepath.Join(t.TempDir(), "missing"), boundaries); err == nil {
+		t.Errorf("DiscoverStations of a missing folder did not fail")
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Host layers, selectable with -host-layer. Any other value is the path of an ESRI ASCII grid (see LoadSuitability).
+const (
+	HostPoints = "points" // flies head for the nearest host tree; every place is equally suitable
+	HostSmooth = "smooth" // the host trees smoothed into a suitability raster (see SmoothTrees)
+)
+
+// HostLayer tells the flies where their hosts are.
+// Directed movement heads towards the position returned by Toward, and survival and fecundity scale with the suitability.
+type HostLayer interface {
+	Suitability(position OrderedPair) float64 // from 0 (no hosts) to 1 (the best habitat of the layer)
+	Toward(position OrderedPair) OrderedPair  // where a fly at position is drawn to
+}
+
+// HostSpecies is implemented by host layers that know the species of their hosts.
+type HostSpecies interface {
+	SpeciesAt(position OrderedPair) string // species of the host a fly at position feeds on, "" if not known
+}
+
+// TreePoints is the host layer of discrete host trees: flies are drawn to the nearest tree and the suitability is 1 everywhere,
+// since the points only show where trees were reported.
+// The trees are sorted once into a grid of square cells, like those of DeduplicateTrees, so the nearest tree is found by searching
+// the cells around a position, nearest first, rather than every tree for every fly every day.
+type TreePoints struct {
+	trees                          []Tree
+	size                           float64            // cell size in degrees
+	cells                          map[hostCell][]int // indexes of the trees in each cell; the species of the cells is left empty
+	minCol, maxCol, minRow, maxRow int                // cells holding trees
+}
+
+// NewTreePoints sorts the trees into cells holding about four trees each on average.
+func NewTreePoints(trees []Tree) TreePoints {
+	t := TreePoints{trees: trees, cells: make(map[hostCell][]int)}
+	if len(trees) == 0 {
+		return t
+	}
+
+	bounds := Bounds{minLon: math.Inf(1), maxLon: math.Inf(-1), minLat: math.Inf(1), maxLat: math.Inf(-1)}
+	for _, tree := range trees {
+		bounds.minLon, bounds.maxLon = math.Min(bounds.minLon, tree.position.x), math.Max(bounds.maxLon, tree.position.x)
+		bounds.minLat, bounds.maxLat = math.Min(bounds.minLat, tree.position.y), math.Max(bounds.maxLat, tree.position.y)
+	}
+	width, height := bounds.maxLon-bounds.minLon, bounds.maxLat-bounds.minLat
+	t.size = 2 * math.Sqrt(width*height/float64(len(trees)))
+	if t.size == 0 {
+		// the trees lie on a line or a point
+		t.size = math.Max(width, height) / float64(len(trees))
+	}
+	if t.size == 0 {
+		t.size = 1
+	}
+
+	for i, tree := range trees {
+		cell := t.cellOf(tree.position)
+		if i == 0 {
+			t.minCol, t.maxCol, t.minRow, t.maxRow = cell.col, cell.col, cell.row, cell.row
+		}
+		t.minCol, t.maxCol = minInt(t.minCol, cell.col), maxInt(t.maxCol, cell.col)
+		t.minRow, t.maxRow = minInt(t.minRow, cell.row), maxInt(t.maxRow, cell.row)
+		t.cells[cell] = append(t.cells[cell], i)
+	}
+	return t
+}
+
+// cellOf returns the cell of the grid containing the position.
+func (t TreePoints) cellOf(position OrderedPair) hostCell {
+	return hostCell{col: int(math.Floor(position.x / t.size)), row: int(math.Floor(position.y / t.size))}
+}
+
+// nearest returns the index of the tree nearest to the position, by the same distance as FindNearestTree, or -1 if there are no trees.
+// The cells are searched in square rings around the position's cell. A tree in ring k+1 or further out is at least k cells away,
+// so the search stops once a tree closer than that has been found.
+func (t TreePoints) nearest(position OrderedPair) int {
+	if len(t.trees) == 0 {
+		return -1
+	}
+	center := t.cellOf(position)
+	// rings nearer than the cells holding trees are empty, and none beyond the farthest of them are needed
+	first := maxInt(maxInt(t.minCol-center.col, center.col-t.maxCol), maxInt(t.minRow-center.row, center.row-t.maxRow))
+	last := maxInt(maxInt(center.col-t.minCol, t.maxCol-center.col), maxInt(center.row-t.minRow, t.maxRow-center.row))
+
+	best, minDistance := -1, math.Inf(1)
+	search := func(col, row int) {
+		for _, i := range t.cells[hostCell{col: col, row: row}] {
+			if d := distance(position, t.trees[i].position); d < minDistance {
+				best, minDistance = i, d
+			}
+		}
+	}
+	for k := maxInt(first, 0); k <= last; k++ {
+		if k == 0 {
+			search(center.col, center.row)
+		}
+		for c := center.col - k; c <= center.col+k && k > 0; c++ {
+			search(c, center.row-k)
+			search(c, center.row+k)
+		}
+		for r := center.row - k + 1; r <= center.row+k-1; r++ {
+			search(center.col-k, r)
+			search(center.col+k, r)
+		}
+		if best >= 0 && minDistance <= float64(k)*t.size {
+			break
+		}
+	}
+	return best
+}
+
+// minInt returns the smaller of two ints.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// maxInt returns the larger of two ints.
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Suitability returns 1 wherever the position is.
+func (t TreePoints) Suitability(position OrderedPair) float64 {
+	return 1
+}
+
+// Toward returns the position of the nearest tree, or 0, 0 if there are no trees, like FindNearestTree.
+func (t TreePoints) Toward(position OrderedPair) OrderedPair {
+	i := t.nearest(position)
+	if i < 0 {
+		return OrderedPair{0, 0}
+	}
+	return t.trees[i].position
+}
+
+// SpeciesAt returns the species of the nearest tree.
+func (t TreePoints) SpeciesAt(position OrderedPair) string {
+	i := t.nearest(position)
+	if i < 0 {
+		return ""
+	}
+	return t.trees[i].species
+}
+
+// SuitabilityRaster is the host layer of a raster of suitability values from 0 to 1.
+// Outside the raster, and in cells without data, nothing is known about the hosts, so the suitability is 1 and flies are not drawn anywhere.
+type SuitabilityRaster struct {
+	raster Raster
+}
+
+// Suitability returns the value of the cell containing the position.
+func (s SuitabilityRaster) Suitability(position OrderedPair) float64 {
+	v, ok := s.raster.ValueAt(position.x, position.y)
+	if !ok {
+		return 1
+	}
+	return v
+}
+
+// Toward climbs the suitability gradient: it returns the centre of the most suitable of the eight cells around the position's cell,
+// or the position itself if none of them is more suitable than its own.
+func (s SuitabilityRaster) Toward(position OrderedPair) OrderedPair {
+	r := s.raster
+	col, row, ok := r.Cell(position.x, position.y)
+	if !ok {
+		return position
+	}
+	best := r.At(col, row)
+	if r.IsNoData(best) {
+		best = math.Inf(-1)
+	}
+	bestCol, bestRow := col, row
+	for dr := -1; dr <= 1; dr++ {
+		for dc := -1; dc <= 1; dc++ {
+			c, rw := col+dc, row+dr
+			if c < 0 || c >= r.cols || rw < 0 || rw >= r.rows {
+				continue
+			}
+			if v := r.At(c, rw); !r.IsNoData(v) && v > best {
+				best, bestCol, bestRow = v, c, rw
+			}
+		}
+	}
+	if bestCol == col && bestRow == row {
+		return position
+	}
+	lon, lat := r.CellCenter(bestCol, bestRow)
+	return OrderedPair{x: lon, y: lat}
+}
+
+// SmoothTrees estimates host abundance on a raster of cellSize degree cells covering bounds by smoothing the trees with a Gaussian
+// kernel of the given bandwidth in km, each weighted by its abundance. The result is scaled so cells at or above the 90th percentile of
+// the cells with hosts have a suitability of 1; scaling by the largest value would leave almost everywhere unsuitable next to a few dense clusters.
+func SmoothTrees(trees []Tree, bounds Bounds, cellSize, bandwidth float64) (SuitabilityRaster, error) {
+	if cellSize <= 0 || bandwidth <= 0 {
+		return SuitabilityRaster{}, fmt.Errorf("the cell size and bandwidth of the host raster must be positive")
+	}
+	if len(trees) == 0 {
+		return SuitabilityRaster{}, fmt.Errorf("there are no host trees to smooth")
+	}
+	r := NewRaster(bounds, cellSize, 0)
+	for _, tree := range trees {
+		addGaussianKernel(r, tree.position, tree.abundance, bandwidth)
+	}
+	return SuitabilityRaster{raster: scaleToQuantile(r, 0.9)}, nil
+}
+
+// LoadSuitability reads a host suitability raster from an ESRI ASCII grid in longitude/latitude, such as a land-cover map.
+// If classes is empty the values are used as they are, scaled so the largest is 1. Otherwise it is a comma-separated list of
+// class:suitability pairs, e.g. "41:1,43:0.8,21:0.5" for NLCD deciduous and mixed forest and open developed land; cells of other classes
+// get a suitability of 0.
+func LoadSuitability(filename, classes string) (SuitabilityRaster, error) {
+	r, err := ReadASCIIGrid(filename)
+	if err != nil {
+		return SuitabilityRaster{}, err
+	}
+	if classes == "" {
+		lo, hi := r.Range()
+		if math.IsNaN(hi) || lo < 0 || hi <= 0 {
+			return SuitabilityRaster{}, fmt.Errorf("%s: the values of a suitability raster must be non-negative, and not all 0", filename)
+		}
+		return SuitabilityRaster{raster: scaleToMax(r)}, nil
+	}
+
+	table := make(map[float64]float64)
+	for _, pair := range splitList(classes) {
+		class, value, found := strings.Cut(pair, ":")
+		c, err1 := strconv.ParseFloat(strings.TrimSpace(class), 64)
+		v, err2 := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !found || err1 != nil || err2 != nil || v < 0 || v > 1 {
+			return SuitabilityRaster{}, fmt.Errorf("invalid host class %q, expected class:suitability with a suitability from 0 to 1", pair)
+		}
+		table[c] = v
+	}
+	for i, v := range r.values {
+		if !r.IsNoData(v) {
+			r.values[i] = table[v]
+		}
+	}
+	return SuitabilityRaster{raster: r}, nil
+}
+
+// scaleToMax divides the values of a raster by its largest value.
+func scaleToMax(r Raster) Raster {
+	_, hi := r.Range()
+	if math.IsNaN(hi) || hi <= 0 {
+		return r
+	}
+	for i, v := range r.values {
+		if !r.IsNoData(v) {
+			r.values[i] = v / hi
+		}
+	}
+	return r
+}
+
+// scaleToQuantile divides the values of a raster by the given quantile of its positive values and caps them at 1.
+func scaleToQuantile(r Raster, q float64) Raster {
+	var positive []float64
+	for _, v := range r.values {
+		if !r.IsNoData(v) && v > 0 {
+			positive = append(positive, v)
+		}
+	}
+	if len(positive) == 0 {
+		return r
+	}
+	sort.Float64s(positive)
+	ref := positive[int(q*float64(len(positive)-1))]
+	for i, v := range r.values {
+		if !r.IsNoData(v) {
+			r.values[i] = math.Min(v/ref, 1)
+		}
+	}
+	return r
+}
+
+// NewHostLayer returns the host layer chosen with -host-layer: the trees as points, the trees smoothed onto a raster of
+// cellSize degree cells covering bounds, or the raster of an ESRI ASCII grid file with the given classes (see LoadSuitability).
+func NewHostLayer(layer string, trees []Tree, bounds Bounds, cellSize, bandwidth float64, classes string) (HostLayer, error) {
+	switch strings.ToLower(layer) {
+	case HostPoints, "":
+		return NewTreePoints(trees), nil
+	case HostSmooth:
+		return SmoothTrees(trees, bounds, cellSize, bandwidth)
+	}
+	return LoadSuitability(layer, classes)
+}
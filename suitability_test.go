@@ -0,0 +1,183 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+type TowardTest struct {
+	position OrderedPair
+	result   OrderedPair
+}
+
+type LoadSuitabilityTest struct {
+	classes string
+	values  []float64 // west to east
+	fails   bool
+}
+
+// TestTreePointsNearest compares the grid search with FindNearestTree for tree layouts that stress the grid.
+func TestTreePointsNearest(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := func(n int, minX, minY, width, height float64) []Tree {
+		trees := make([]Tree, n)
+		for i := range trees {
+			trees[i] = Tree{position: OrderedPair{minX + rng.Float64()*width, minY + rng.Float64()*height}}
+		}
+		return trees
+	}
+	layouts := map[string][]Tree{
+		"scattered":  random(500, -80, 38, 6, 4),
+		"clustered":  append(random(300, -75.7, 40.4, 0.1, 0.1), random(5, -90, 30, 20, 15)...),
+		"on a line":  random(50, -80, 40, 6, 0),
+		"one point":  {{position: OrderedPair{-75, 40}}, {position: OrderedPair{-75, 40}}},
+		"single":     {{position: OrderedPair{-75, 40}}},
+		"south west": random(100, -125, 25, 1, 1),
+	}
+
+	for name, trees := range layouts {
+		points := NewTreePoints(trees)
+		for i := 0; i < 500; i++ {
+			position := OrderedPair{-130 + rng.Float64()*70, 20 + rng.Float64()*35}
+			if i%2 == 0 && len(trees) > 1 {
+				// near the trees, where the search stops early
+				tree := trees[rng.Intn(len(trees))].position
+				position = OrderedPair{tree.x + rng.NormFloat64()*0.05, tree.y + rng.NormFloat64()*0.05}
+			}
+			got, want := points.Toward(position), FindNearestTree(position, trees)
+			if distance(position, got) != distance(position, want) {
+				t.Errorf("%s: Toward(%v) = %v at %v, want %v at %v", name, position, got, distance(position, got), want, distance(position, want))
+				break
+			}
+		}
+	}
+
+	empty := NewTreePoints(nil)
+	if toward := empty.Toward(OrderedPair{-75, 40}); toward != (OrderedPair{0, 0}) {
+		t.Errorf("Toward without trees = %v, want 0, 0 like FindNearestTree", toward)
+	}
+	if species := empty.SpeciesAt(OrderedPair{-75, 40}); species != "" {
+		t.Errorf("SpeciesAt without trees = %q, want \"\"", species)
+	}
+}
+
+func TestTreePointsSpeciesAt(t *testing.T) {
+	points := NewTreePoints([]Tree{
+		{position: OrderedPair{-80, 40}, species: "Ailanthus altissima"},
+		{position: OrderedPair{-75, 40}, species: "Acer rubrum"},
+		{position: OrderedPair{-75, 45}},
+	})
+	tests := map[OrderedPair]string{
+		{-79, 40.5}:   "Ailanthus altissima",
+		{-75.5, 40}:   "Acer rubrum",
+		{-75, 44}:     "",
+		{-100, 40}:    "Ailanthus altissima",
+		{-75.1, 39.9}: "Acer rubrum",
+	}
+	for position, want := range tests {
+		if species := points.SpeciesAt(position); species != want {
+			t.Errorf("SpeciesAt(%v) = %q, want %q", position, species, want)
+		}
+		if points.Suitability(position) != 1 {
+			t.Errorf("Suitability(%v) of tree points = %v, want 1", position, points.Suitability(position))
+		}
+	}
+}
+
+func TestSuitabilityRaster(t *testing.T) {
+	// a 3 x 3 raster from -80, 40 to -77, 43, most suitable in the north east corner
+	r := NewRaster(Bounds{minLon: -80, minLat: 40, maxLon: -77, maxLat: 43}, 1, 0)
+	copy(r.values, []float64{0.2, 0.5, 1, 0.1, 0.3, 0.5, 0, 0.1, 0.2})
+	r.Set(0, 2, math.NaN())
+	s := SuitabilityRaster{raster: r}
+
+	tests := []TowardTest{
+		{position: OrderedPair{-78.5, 41.5}, result: OrderedPair{-77.5, 42.5}}, // centre cell, climbs to the north east
+		{position: OrderedPair{-79.2, 40.2}, result: OrderedPair{-78.5, 41.5}}, // no-data cell, to its best neighbour
+		{position: OrderedPair{-77.3, 42.7}, result: OrderedPair{-77.3, 42.7}}, // already at the top
+		{position: OrderedPair{-85, 41}, result: OrderedPair{-85, 41}},         // outside
+		{position: OrderedPair{-79.5, 42.5}, result: OrderedPair{-78.5, 42.5}}, // on the edge
+	}
+	for _, test := range tests {
+		if result := s.Toward(test.position); result != test.result {
+			t.Errorf("Toward(%v) = %v, want %v", test.position, result, test.result)
+		}
+	}
+
+	for position, want := range map[OrderedPair]float64{{-78.5, 41.5}: 0.3, {-79.5, 40.5}: 1, {-85, 41}: 1, {-77.5, 42.5}: 1} {
+		if v := s.Suitability(position); v != want {
+			t.Errorf("Suitability(%v) = %v, want %v", position, v, want)
+		}
+	}
+}
+
+func TestSmoothTrees(t *testing.T) {
+	bounds := Bounds{minLon: -80, minLat: 40, maxLon: -76, maxLat: 42}
+	trees := []Tree{{position: OrderedPair{-79.5, 41.5}, abundance: 10}, {position: OrderedPair{-76.5, 40.5}, abundance: 1}}
+	s, err := SmoothTrees(trees, bounds, 0.25, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lo, hi := s.raster.Range()
+	if lo < 0 || hi != 1 {
+		t.Errorf("SmoothTrees values range from %v to %v, want 0 to 1", lo, hi)
+	}
+	if near, far := s.Suitability(OrderedPair{-79.5, 41.5}), s.Suitability(OrderedPair{-78, 41}); near != 1 || far >= near {
+		t.Errorf("suitability at the dense tree %v and between the trees %v, want 1 and less", near, far)
+	}
+	if toward := s.Toward(OrderedPair{-79.1, 41.1}); distance(toward, trees[0].position) >= distance(OrderedPair{-79.1, 41.1}, trees[0].position) {
+		t.Errorf("Toward(-79.1, 41.1) = %v, which is not nearer the dense tree", toward)
+	}
+
+	if _, err := SmoothTrees(nil, bounds, 0.25, 20); err == nil {
+		t.Errorf("SmoothTrees without trees did not fail")
+	}
+	if _, err := SmoothTrees(trees, bounds, 0, 20); err == nil {
+		t.Errorf("SmoothTrees with a cell size of 0 did not fail")
+	}
+	if _, err := SmoothTrees(trees, bounds, 0.25, -1); err == nil {
+		t.Errorf("SmoothTrees with a negative bandwidth did not fail")
+	}
+}
+
+func TestLoadSuitability(t *testing.T) {
+	grid := "ncols 4\nnrows 1\nxllcorner -80\nyllcorner 40\ncellsize 1\nNODATA_value -9999\n41 43 21 -9999\n"
+	tests := []LoadSuitabilityTest{
+		{classes: "", values: []float64{41.0 / 43, 1, 21.0 / 43, 1}},
+		{classes: "41:1, 43:0.8", values: []float64{1, 0.8, 0, 1}},
+		{classes: "41:1,21", fails: true},
+		{classes: "41:2", fails: true},
+		{classes: "forest:1", fails: true},
+	}
+
+	path := writeTestFile(t, "landcover.asc", grid)
+	for _, test := range tests {
+		s, err := LoadSuitability(path, test.classes)
+		if (err != nil) != test.fails {
+			t.Errorf("LoadSuitability(%q) error = %v, want error %v", test.classes, err, test.fails)
+			continue
+		}
+		if test.fails {
+			continue
+		}
+		for i, want := range test.values {
+			if v := s.Suitability(OrderedPair{-79.5 + float64(i), 40.5}); !closeTo(v, want) {
+				t.Errorf("LoadSuitability(%q) cell %d = %v, want %v", test.classes, i, v, want)
+			}
+		}
+	}
+
+	negative := writeTestFile(t, "negative.asc", "ncols 2\nnrows 1\nxllcorner 0\nyllcorner 0\ncellsize 1\n-1 2\n")
+	if _, err := LoadSuitability(negative, ""); err == nil {
+		t.Errorf("LoadSuitability of negative values without classes did not fail")
+	}
+	if _, err := NewHostLayer("missing.asc", nil, Bounds{}, 1, 1, ""); err == nil {
+		t.Errorf("NewHostLayer of a missing grid did not fail")
+	}
+	if layer, err := NewHostLayer("Points", nil, Bounds{}, 1, 1, ""); err != nil {
+		t.Errorf("NewHostLayer(Points) error = %v", err)
+	} else if _, ok := layer.(TreePoints); !ok {
+		t.Errorf("NewHostLayer(Points) = %T, want TreePoints", layer)
+	}
+}
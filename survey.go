@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// SurveyCell is the survey effort and outcome in one raster cell: how many surveys were made there and how many found lanternflies.
+type SurveyCell struct {
+	col, row   int
+	surveys    int
+	detections int
+}
+
+// SurveyGrid maps the presence and absence records of the detection dataset onto a raster of cellSize degree cells.
+// Cells without any survey are left out, so they are neither presences nor absences when a run is evaluated.
+type SurveyGrid struct {
+	bounds   Bounds
+	cellSize float64
+	cells    []SurveyCell // surveyed cells, north to south and west to east
+	outside  int          // records outside the raster
+}
+
+// NewSurveyGrid counts the presences and absences in each cell of a raster covering bounds.
+func NewSurveyGrid(presences, absences []SampleData, bounds Bounds, cellSize float64) SurveyGrid {
+	r := NewRaster(bounds, cellSize, 0)
+	g := SurveyGrid{bounds: r.bounds, cellSize: cellSize}
+
+	byCell := make(map[int]*SurveyCell)
+	add := func(record SampleData, found bool) {
+		col, row, ok := r.Cell(record.Longitude, record.Latitude)
+		if !ok {
+			g.outside++
+			return
+		}
+		cell, ok := byCell[row*r.cols+col]
+		if !ok {
+			cell = &SurveyCell{col: col, row: row}
+			byCell[row*r.cols+col] = cell
+		}
+		cell.surveys++
+		if found {
+			cell.detections++
+		}
+	}
+	for _, record := range presences {
+		add(record, true)
+	}
+	for _, record := range absences {
+		add(record, false)
+	}
+
+	keys := make([]int, 0, len(byCell))
+	for key := range byCell {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	for _, key := range keys {
+		g.cells = append(g.cells, *byCell[key])
+	}
+	return g
+}
+
+// Occupied returns the number of surveyed cells with at least one detection, and the number surveyed without any.
+func (g SurveyGrid) Occupied() (int, int) {
+	present := 0
+	for _, cell := range g.cells {
+		if cell.detections > 0 {
+			present++
+		}
+	}
+	return present, len(g.cells) - present
+}
+
+// Evaluation compares the cells a run predicts to be occupied with the surveyed cells.
+type Evaluation struct {
+	truePositives  int // predicted occupied, lanternflies found
+	falsePositives int // predicted occupied, surveyed without finding any
+	falseNegatives int // predicted empty, lanternflies found
+	trueNegatives  int // predicted empty, surveyed without finding any
+	logLikelihood  float64
+}
+
+// minOccupancy keeps the predicted occupancy of a cell away from 0 and 1, so one surprising survey does not make a run impossible.
+const minOccupancy = 1e-6
+
+// Evaluate scores a raster of predicted living flies per cell, on the grid of the surveys, against the surveys.
+// A cell with n flies is occupied with probability ψ = 1 - exp(-n), and each survey of an occupied cell finds lanternflies with
+// probability detectionProb, so a cell with y detections in k surveys has the likelihood
+// ψ·C(k,y)·p^y·(1-p)^(k-y), plus (1-ψ) if y is 0. The log-likelihood is the sum over the surveyed cells.
+func (g SurveyGrid) Evaluate(predicted Raster, detectionProb float64) (Evaluation, error) {
+	if predicted.cellSize != g.cellSize || predicted.bounds != g.bounds {
+		return Evaluation{}, fmt.Errorf("the predicted raster is not on the grid of the surveys")
+	}
+	if detectionProb <= 0 || detectionProb >= 1 {
+		return Evaluation{}, fmt.Errorf("detection probability %v is not between 0 and 1", detectionProb)
+	}
+
+	var e Evaluation
+	for _, cell := range g.cells {
+		flies := predicted.At(cell.col, cell.row)
+		present := flies > 0
+		found := cell.detections > 0
+		switch {
+		case present && found:
+			e.truePositives++
+		case present && !found:
+			e.falsePositives++
+		case !present && found:
+			e.falseNegatives++
+		default:
+			e.trueNegatives++
+		}
+
+		psi := math.Min(math.Max(1-math.Exp(-flies), minOccupancy), 1-minOccupancy)
+		k, y := float64(cell.surveys), float64(cell.detections)
+		likelihood := psi * math.Exp(logChoose(k, y)+y*math.Log(detectionProb)+(k-y)*math.Log1p(-detectionProb))
+		if cell.detections == 0 {
+			likelihood += 1 - psi
+		}
+		e.logLikelihood += math.Log(likelihood)
+	}
+	return e, nil
+}
+
+// logChoose returns the logarithm of the binomial coefficient C(n, k).
+func logChoose(n, k float64) float64 {
+	a, _ := math.Lgamma(n + 1)
+	b, _ := math.Lgamma(k + 1)
+	c, _ := math.Lgamma(n - k + 1)
+	return a - b - c
+}
+
+// Sensitivity returns the share of the cells where lanternflies were found that the run predicts to be occupied.
+func (e Evaluation) Sensitivity() float64 {
+	return float64(e.truePositives) / float64(e.truePositives+e.falseNegatives)
+}
+
+// Specificity returns the share of the cells surveyed without finding lanternflies that the run predicts to be empty.
+func (e Evaluation) Specificity() float64 {
+	return float64(e.trueNegatives) / float64(e.trueNegatives+e.falsePositives)
+}
+
+// TSS returns the true skill statistic, sensitivity + specificity - 1. It is NaN unless there are both presences and absences.
+func (e Evaluation) TSS() float64 {
+	return e.Sensitivity() + e.Specificity() - 1
+}
+
+// String formats the evaluation for printing.
+func (e Evaluation) String() string {
+	return fmt.Sprintf("%d true positives, %d false positives, %d false negatives, %d true negatives; "+
+		"sensitivity %.3f, specificity %.3f, TSS %.3f, log-likelihood %.1f",
+		e.truePositives, e.falsePositives, e.falseNegatives, e.trueNegatives,
+		e.Sensitivity(), e.Specificity(), e.TSS(), e.logLikelihood)
+}
+
+// Validation is what a run is evaluated against: the surveys of a range of bio years and the detection probability of one survey.
+type Validation struct {
+	grid          SurveyGrid
+	firstYear     int
+	lastYear      int
+	detectionProb float64
+}
+
+// bioYear returns the bio year of a date as used by the detection dataset: the year in which the season that starts on May 1 begins.
+func bioYear(date time.Time) int {
+	if date.Month() < time.May {
+		return date.Year() - 1
+	}
+	return date.Year()
+}
+
+// Includes reports whether a date falls in the bio years of the validation.
+func (v Validation) Includes(date time.Time) bool {
+	year := bioYear(date)
+	return year >= v.firstYear && year <= v.lastYear
+}
+
+// EvaluateRun scores a run against the surveys. The prediction for a cell is the peak number of living flies in it on the days of the
+// validation's bio years (see PeakCountRaster); it is an error if the run does not reach those years.
+func (v Validation) EvaluateRun(timePoints []Country) (Evaluation, error) {
+	var selected []Country
+	for _, country := range timePoints {
+		if v.Includes(country.date) {
+			selected = append(selected, country)
+		}
+	}
+	if len(selected) == 0 {
+		return Evaluation{}, fmt.Errorf("the run does not reach the bio years %d to %d of the surveys", v.firstYear, v.lastYear)
+	}
+	return v.grid.Evaluate(PeakCountRaster(selected, v.grid.bounds, v.grid.cellSize), v.detectionProb)
+}
+
+// NewValidation selects the presence and absence surveys of the given bio years ("2022" or "2022-2023") in the study region from those
+// read with ReadSampleDataFromFile, and maps them onto a raster of cellSize degree cells covering the region. It prints how many cells were surveyed.
+func NewValidation(surveys []SampleData, years string, region Region, cellSize, detectionProb float64) (*Validation, error) {
+	if detectionProb <= 0 || detectionProb >= 1 {
+		return nil, fmt.Errorf("detection probability %v is not between 0 and 1", detectionProb)
+	}
+	filter, err := ParseDetectionFilter(years, "", "", "")
+	if err != nil {
+		return nil, err
+	}
+	filter.region = &region
+	presences, absences := filter.Select(surveys, nil)
+	if len(presences)+len(absences) == 0 {
+		return nil, fmt.Errorf("there are no surveys in bio years %s in %s", years, region.name)
+	}
+
+	grid := NewSurveyGrid(presences, absences, region.bounds, cellSize)
+	present, absent := grid.Occupied()
+	fmt.Printf("Evaluating against %d presences and %d absences in %d cells (%d with lanternflies, %d without); %d surveys are outside the map.\n",
+		len(presences), len(absences), len(grid.cells), present, absent, grid.outside)
+	return &Validation{grid: grid, firstYear: filter.minYear, lastYear: filter.maxYear, detectionProb: detectionProb}, nil
+}
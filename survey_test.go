@@ -0,0 +1,164 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+type EvaluateTest struct {
+	name          string
+	flies         []float64 // predicted flies in the cells of the test grid, west to east
+	detectionProb float64
+	counts        [4]int // true positives, false positives, false negatives, true negatives
+	fails         bool
+}
+
+type BioYearTest struct {
+	date string
+	year int
+}
+
+func TestNewSurveyGrid(t *testing.T) {
+	bounds := Bounds{minLon: -80, minLat: 40, maxLon: -78, maxLat: 41}
+	presences := []SampleData{{Longitude: -79.5, Latitude: 40.5}, {Longitude: -79.2, Latitude: 40.9}, {Longitude: -90, Latitude: 40.5}}
+	absences := []SampleData{{Longitude: -79.7, Latitude: 40.1}, {Longitude: -78.5, Latitude: 40.5}}
+	g := NewSurveyGrid(presences, absences, bounds, 1)
+
+	want := []SurveyCell{{col: 0, row: 0, surveys: 3, detections: 2}, {col: 1, row: 0, surveys: 1, detections: 0}}
+	if len(g.cells) != len(want) {
+		t.Fatalf("NewSurveyGrid made %d cells, want %d", len(g.cells), len(want))
+	}
+	for i := range want {
+		if g.cells[i] != want[i] {
+			t.Errorf("NewSurveyGrid cell %d = %+v, want %+v", i, g.cells[i], want[i])
+		}
+	}
+	if g.outside != 1 {
+		t.Errorf("NewSurveyGrid counted %d surveys outside, want 1", g.outside)
+	}
+	if present, absent := g.Occupied(); present != 1 || absent != 1 {
+		t.Errorf("Occupied() = %d, %d, want 1, 1", present, absent)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	bounds := Bounds{minLon: 0, minLat: 0, maxLon: 4, maxLat: 1}
+	// cells 0 and 1 found lanternflies in one of two surveys; cells 2 and 3 were surveyed once without finding any
+	g := SurveyGrid{bounds: bounds, cellSize: 1, cells: []SurveyCell{
+		{col: 0, surveys: 2, detections: 1}, {col: 1, surveys: 2, detections: 1}, {col: 2, surveys: 1}, {col: 3, surveys: 1},
+	}}
+	tests := []EvaluateTest{
+		{name: "perfect", flies: []float64{5, 1, 0, 0}, detectionProb: 0.5, counts: [4]int{2, 0, 0, 2}},
+		{name: "everywhere", flies: []float64{1, 1, 1, 1}, detectionProb: 0.5, counts: [4]int{2, 2, 0, 0}},
+		{name: "nowhere", flies: []float64{0, 0, 0, 0}, detectionProb: 0.5, counts: [4]int{0, 0, 2, 2}},
+		{name: "detection probability 0", flies: []float64{0, 0, 0, 0}, detectionProb: 0, fails: true},
+		{name: "detection probability 1", flies: []float64{0, 0, 0, 0}, detectionProb: 1, fails: true},
+	}
+
+	for _, test := range tests {
+		predicted := NewRaster(bounds, 1, 0)
+		copy(predicted.values, test.flies)
+		e, err := g.Evaluate(predicted, test.detectionProb)
+		if (err != nil) != test.fails {
+			t.Errorf("Evaluate(%s) error = %v, want error %v", test.name, err, test.fails)
+			continue
+		}
+		if test.fails {
+			continue
+		}
+		if counts := [4]int{e.truePositives, e.falsePositives, e.falseNegatives, e.trueNegatives}; counts != test.counts {
+			t.Errorf("Evaluate(%s) counts = %v, want %v", test.name, counts, test.counts)
+		}
+
+		want := 0.0
+		p := test.detectionProb
+		for i, cell := range g.cells {
+			psi := math.Min(math.Max(1-math.Exp(-test.flies[i]), minOccupancy), 1-minOccupancy)
+			if cell.detections > 0 {
+				want += math.Log(psi * 2 * p * (1 - p))
+			} else {
+				want += math.Log(psi*(1-p) + 1 - psi)
+			}
+		}
+		if math.Abs(e.logLikelihood-want) > 1e-9 {
+			t.Errorf("Evaluate(%s) log-likelihood = %v, want %v", test.name, e.logLikelihood, want)
+		}
+	}
+
+	// one presence and one absence predicted occupied
+	predicted := NewRaster(bounds, 1, 0)
+	predicted.values[0], predicted.values[2] = 1, 1
+	e, _ := g.Evaluate(predicted, 0.5)
+	if e.Sensitivity() != 0.5 || e.Specificity() != 0.5 || e.TSS() != 0 {
+		t.Errorf("sensitivity, specificity and TSS = %v, %v, %v, want 0.5, 0.5, 0", e.Sensitivity(), e.Specificity(), e.TSS())
+	}
+	if _, err := g.Evaluate(NewRaster(bounds, 0.5, 0), 0.5); err == nil {
+		t.Errorf("Evaluate of a raster on another grid did not fail")
+	}
+}
+
+func TestBioYear(t *testing.T) {
+	tests := []BioYearTest{
+		{date: "2022-05-01", year: 2022},
+		{date: "2022-12-31", year: 2022},
+		{date: "2023-04-30", year: 2022},
+		{date: "2023-01-01", year: 2022},
+	}
+	for _, test := range tests {
+		day, err := ParseDate(test.date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if year := bioYear(day); year != test.year {
+			t.Errorf("bioYear(%s) = %d, want %d", test.date, year, test.year)
+		}
+	}
+}
+
+// TestNewValidation builds a validation from surveys read once for all bio years, and evaluates a run against it.
+func TestNewValidation(t *testing.T) {
+	region, err := boxRegion("test", []float64{-80, 40, -78, 41})
+	if err != nil {
+		t.Fatal(err)
+	}
+	surveys := []SampleData{
+		{BioYear: 2021, Longitude: -79.5, Latitude: 40.5, LydePresent: true},
+		{BioYear: 2022, Longitude: -79.5, Latitude: 40.5, LydePresent: true},
+		{BioYear: 2022, Longitude: -78.5, Latitude: 40.5, LydePresent: false},
+		{BioYear: 2022, Longitude: -70, Latitude: 40.5, LydePresent: true}, // outside the region
+	}
+
+	v, err := NewValidation(surveys, "2022", region, 1, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.grid.cells) != 2 || v.grid.outside != 0 || v.firstYear != 2022 || v.lastYear != 2022 {
+		t.Errorf("NewValidation = %d cells, %d outside, bio years %d to %d, want 2 cells, none outside, 2022 to 2022",
+			len(v.grid.cells), v.grid.outside, v.firstYear, v.lastYear)
+	}
+	if !v.Includes(date(2023, 4, 30)) || v.Includes(date(2023, 5, 1)) || v.Includes(date(2022, 4, 30)) {
+		t.Errorf("Includes does not follow the bio year 2022 from 2022-05-01 to 2023-04-30")
+	}
+
+	fly := Fly{position: OrderedPair{-79.5, 40.5}, stage: 5, isAlive: true}
+	e, err := v.EvaluateRun([]Country{{date: date(2021, 8, 1)}, {date: date(2022, 8, 1), flies: []Fly{fly}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.truePositives != 1 || e.trueNegatives != 1 || e.falsePositives != 0 || e.falseNegatives != 0 {
+		t.Errorf("EvaluateRun = %v, want one true positive and one true negative", e)
+	}
+	if _, err := v.EvaluateRun([]Country{{date: date(2021, 8, 1), flies: []Fly{fly}}}); err == nil {
+		t.Errorf("EvaluateRun of a run that does not reach 2022 did not fail")
+	}
+
+	if _, err := NewValidation(surveys, "2019", region, 1, 0.5); err == nil {
+		t.Errorf("NewValidation for a bio year without surveys did not fail")
+	}
+	if _, err := NewValidation(surveys, "2022", region, 1, 1.5); err == nil {
+		t.Errorf("NewValidation with a detection probability of 1.5 did not fail")
+	}
+	if _, err := NewValidation(surveys, "soon", region, 1, 0.5); err == nil {
+		t.Errorf("NewValidation with invalid years did not fail")
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import "time"
+
+// TemperatureProvider gives the daily minimum and maximum temperature, in °C, at a position on a date.
+// ok is false if the provider has no data for the position or the date.
+type TemperatureProvider interface {
+	DailyTemperature(position OrderedPair, date time.Time) (tmin, tmax float64, ok bool)
+}
+
+// QuadrantTemperatures provides the temperatures of the weather stations assigned to the quadrants (see AssignStations).
+// Every day of the year has the same temperatures: those of the quadrant containing the position.
+type QuadrantTemperatures []Quadrant
+
+// DailyTemperature returns the minimum and maximum temperature of the quadrant containing the position.
+func (quadrants QuadrantTemperatures) DailyTemperature(position OrderedPair, date time.Time) (float64, float64, bool) {
+	quadrantID := GetQuadrant(&Fly{position: position}, quadrants)
+	for _, q := range quadrants {
+		if q.id == quadrantID {
+			return q.minTemp, q.temp, true
+		}
+	}
+	return 0, 0, false
+}
+
+// SeasonalTemperatures provides the station temperatures of one season, such as those of Data/Egg laying_Sep-Nov,
+// on the days from the day from to the day to (both as month*100+day, see ParseSeason).
+type SeasonalTemperatures struct {
+	from, to  int
+	quadrants QuadrantTemperatures
+}
+
+// DailyTemperature returns the temperatures of the quadrant containing the position on the days of the season.
+func (s SeasonalTemperatures) DailyTemperature(position OrderedPair, date time.Time) (float64, float64, bool) {
+	if !inSeason(date, s.from, s.to) {
+		return 0, 0, false
+	}
+	return s.quadrants.DailyTemperature(position, date)
+}
+
+// On returns a copy of the weather for the given day.
+func (weather Weather) On(date time.Time) Weather {
+	weather.date = date
+	return weather
+}
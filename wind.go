@@ -0,0 +1,57 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"time"
+)
+
+// WindProvider gives the daily wind at a position on a date: its speed in m/s, and the direction it blows from in degrees
+// clockwise from north, as in weather records (270 is a westerly, blowing towards the east).
+// ok is false if the provider has no data for the position or the date.
+type WindProvider interface {
+	DailyWind(position OrderedPair, date time.Time) (speed, direction float64, ok bool)
+}
+
+// windFilePattern matches the variable and date in the name of a daily wind grid, e.g. wspd_20230801.asc or wdir_20230801.asc.
+var windFilePattern = regexp.MustCompile(`(?i)(wspd|wdir).*?(\d{8})`)
+
+// GridWind is a WindProvider reading daily wind speed (wspd, m/s) and direction (wdir, degrees the wind blows from) from a folder of
+// ESRI ASCII grids, one file per variable and day named with the variable and the date as YYYYMMDD, like GridTemperatures.
+// gridMET's vs and th, or daily means of a reanalysis, can be converted to these with gdal_translate -of AAIGrid.
+type GridWind struct {
+	*dailyGrids
+}
+
+// NewGridWind finds the daily wind grids in a folder. It fails if the folder has no day with both a wspd and a wdir grid.
+func NewGridWind(folder string) (*GridWind, error) {
+	g, err := newDailyGrids(folder, "wind", windFilePattern, "wspd", "wdir")
+	if err != nil {
+		return nil, err
+	}
+	return &GridWind{g}, nil
+}
+
+// DailyWind samples the grids of the day at the position.
+func (g *GridWind) DailyWind(position OrderedPair, date time.Time) (float64, float64, bool) {
+	values, ok := g.sample(position, date)
+	if !ok {
+		return 0, 0, false
+	}
+	return values[0], values[1], true
+}
+
+// WindDrift returns where an adult that flew from start to position ends up after the wind of the day at start carries it
+// downwind for airtime seconds. Without wind data the position is returned as it is.
+func WindDrift(start, position OrderedPair, weather Weather, airtime float64) OrderedPair {
+	if weather.wind == nil || airtime <= 0 {
+		return position
+	}
+	speed, direction, ok := weather.wind.DailyWind(start, weather.date)
+	if !ok || speed <= 0 {
+		return position
+	}
+	// the wind blows towards direction+180° clockwise from north, i.e. at 270°-direction counterclockwise from east
+	heading := (270 - direction) * math.Pi / 180
+	return offsetKm(position, speed*airtime/1000, heading)
+}
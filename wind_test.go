@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type WindDriftTest struct {
+	name      string
+	speed     float64 // m/s
+	direction float64 // degrees the wind blows from
+	airtime   float64 // seconds
+	east      float64 // km
+	north     float64 // km
+}
+
+// steadyWind blows the same everywhere, every day.
+type steadyWind struct {
+	speed, direction float64
+}
+
+func (w steadyWind) DailyWind(position OrderedPair, date time.Time) (float64, float64, bool) {
+	return w.speed, w.direction, true
+}
+
+func TestWindDrift(t *testing.T) {
+	start := OrderedPair{-79, 41}
+	tests := []WindDriftTest{
+		{name: "westerly", speed: 5, direction: 270, airtime: 60, east: 0.3},
+		{name: "northerly", speed: 5, direction: 0, airtime: 60, north: -0.3},
+		{name: "south westerly", speed: 10, direction: 225, airtime: 100, east: math.Sqrt(0.5), north: math.Sqrt(0.5)},
+		{name: "no airtime", speed: 5, direction: 270, airtime: 0},
+		{name: "calm", speed: 0, direction: 270, airtime: 60},
+	}
+
+	for _, test := range tests {
+		weather := Weather{wind: steadyWind{test.speed, test.direction}, date: date(2023, 8, 1)}
+		end := WindDrift(start, start, weather, test.airtime)
+		east := distanceKm(start, OrderedPair{end.x, start.y}) * math.Copysign(1, end.x-start.x)
+		north := distanceKm(start, OrderedPair{start.x, end.y}) * math.Copysign(1, end.y-start.y)
+		if math.Abs(east-test.east) > 1e-3 || math.Abs(north-test.north) > 1e-3 {
+			t.Errorf("WindDrift(%s) moved %.4f km east and %.4f km north, want %.4f and %.4f", test.name, east, north, test.east, test.north)
+		}
+	}
+
+	if end := WindDrift(start, start, Weather{date: date(2023, 8, 1)}, 60); end != start {
+		t.Errorf("WindDrift without wind data moved to %v", end)
+	}
+}
+
+// TestComputeMovementDrift checks that the wind carries an adult only for the share of the day it flies.
+func TestComputeMovementDrift(t *testing.T) {
+	start := OrderedPair{-79, 41}
+	hosts := NewTreePoints([]Tree{{position: start}})
+	weather := Weather{wind: steadyWind{5, 270}, date: date(2023, 8, 1)}
+	for _, flight := range []float64{1, 0.5} {
+		end := ComputeMovement(&Fly{position: start, stage: 5, isAlive: true}, hosts, eastKernel(0), weather, 60, flight)
+		if d := distanceKm(start, end); math.Abs(d-0.3*flight) > 1e-3 || end.x <= start.x {
+			t.Errorf("ComputeMovement(flight %v) drifted %v km to %v, want %v km east", flight, d, end, 0.3*flight)
+		}
+	}
+}
+
+func TestGridWind(t *testing.T) {
+	dir := t.TempDir()
+	grid := func(value string) string {
+		return "ncols 2\nnrows 1\nxllcorner -80\nyllcorner 40\ncellsize 1\nNODATA_value -9999\n" + value + " -9999\n"
+	}
+	files := map[string]string{
+		"wspd_20230801.asc": grid("4.5"),
+		"wdir_20230801.asc": grid("270"),
+		"wspd_20230802.asc": grid("3"), // no direction for this day
+		"tmin_20230801.asc": grid("10"),
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g, err := NewGridWind(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, first, _ := g.Days(); n != 1 || !first.Equal(date(2023, 8, 1)) {
+		t.Errorf("Days() = %d from %v, want 1 from 2023-08-01", n, first)
+	}
+	if speed, direction, ok := g.DailyWind(OrderedPair{-79.5, 40.5}, date(2023, 8, 1)); !ok || speed != 4.5 || direction != 270 {
+		t.Errorf("DailyWind(2023-08-01) = %v, %v, %v, want 4.5, 270, true", speed, direction, ok)
+	}
+	// a later year uses the latest grids of the day
+	if speed, _, ok := g.DailyWind(OrderedPair{-79.5, 40.5}, date(2025, 8, 1)); !ok || speed != 4.5 {
+		t.Errorf("DailyWind(2025-08-01) = %v, %v, want 4.5, true", speed, ok)
+	}
+	if _, _, ok := g.DailyWind(OrderedPair{-79.5, 40.5}, date(2023, 8, 2)); ok {
+		t.Errorf("DailyWind of a day without a direction grid is ok")
+	}
+	if _, _, ok := g.DailyWind(OrderedPair{-78.5, 40.5}, date(2023, 8, 1)); ok {
+		t.Errorf("DailyWind of a no-data cell is ok")
+	}
+
+	if _, err := NewGridWind(t.TempDir()); err == nil {
+		t.Errorf("NewGridWind of a folder without grids did not fail")
+	}
+	if _, err := NewGridWind(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("NewGridWind of a missing folder did not fail")
+	}
+}